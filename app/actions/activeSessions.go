@@ -0,0 +1,125 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/controllers"
+	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const SESSION_TIME_LAYOUT = "02.01.2006 15:04"
+
+type ActiveSessions struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// sessionsPage формирует список активных сессий с кнопками для их завершения
+func sessionsPage(sessions []models.Sessions, current *models.Sessions, lang string, sessionKey, pageOffset any) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	text := i18n.T(lang, "Активные сессии") + "\n"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup()
+
+	for i, session := range sessions {
+		source := fmt.Sprintf(i18n.T(lang, "%s, чат %d"), session.Source, session.ChatID)
+		if session.ID == current.ID {
+			source += i18n.T(lang, " (эта сессия)")
+		}
+
+		text += fmt.Sprintf(
+			i18n.T(lang, "\n%d. %s\nОткрыта: %s\nАктивность: %s\n"),
+			i+1,
+			source,
+			time.Unix(session.CreatedAt, 0).Format(SESSION_TIME_LAYOUT),
+			time.Unix(session.UpdatedAt, 0).Format(SESSION_TIME_LAYOUT),
+		)
+
+		revokeDataJSON, err := json.Marshal(map[string]any{
+			"a": "r",        // action: revoke session
+			"k": sessionKey, // session_key
+			"o": pageOffset, // offest
+			"i": session.ID, // session id
+		})
+		if err != nil {
+			return "", tgbotapi.InlineKeyboardMarkup{}, err
+		}
+
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{
+			{Text: fmt.Sprintf(i18n.T(lang, "Завершить сессию %d"), i+1), CallbackData: util.StringPtr(string(revokeDataJSON))},
+		})
+	}
+
+	backDataJSON, err := json.Marshal(map[string]any{
+		"a": "g", // action: settings
+		"k": sessionKey,
+		"o": pageOffset,
+	})
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{
+		{Text: i18n.T(lang, "Назад"), CallbackData: util.StringPtr(string(backDataJSON))},
+	})
+
+	return text, keyboard, nil
+}
+
+func (a ActiveSessions) Run(ctx *handlers.Context) error {
+	update := ctx.Update
+	session := ctx.Session
+	data := ctx.CallbackData
+
+	controllers.ClearNextStepForUser(update, a.Client, true)
+	updateSession(a.Repos.Sessions, session)
+
+	settings, err := controllers.GetUserSettings(a.Repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+
+	if revokeID, ok := data["i"].(float64); ok {
+		// Завершение текущей сессии ничем не отличается от блокировки хранилища
+		if int64(revokeID) == session.ID {
+			return Lock{Name: "lock-from-sessions-page", Client: a.Client, Repos: a.Repos}.Run(ctx)
+		}
+
+		err = controllers.RevokeSession(a.Client, a.Repos, update.CallbackQuery.From.ID, int64(revokeID))
+		if err != nil {
+			return err
+		}
+
+		a.Client.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, i18n.T(settings.Language, "Сессия завершена")))
+	}
+
+	sessions, err := a.Repos.Sessions.ListByUser(update.CallbackQuery.From.ID, session.IsDuress)
+	if err != nil {
+		return err
+	}
+
+	text, keyboard, err := sessionsPage(sessions, session, settings.Language, data["k"], data["o"])
+	if err != nil {
+		return err
+	}
+
+	_, err = a.Client.Request(tgbotapi.NewEditMessageTextAndMarkup(
+		update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID,
+		text,
+		keyboard,
+	))
+
+	return err
+}
+
+func (a ActiveSessions) GetName() string {
+	return a.Name
+}
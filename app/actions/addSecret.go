@@ -3,12 +3,15 @@ package actions
 import (
 	"encoding/json"
 	"log"
+	"main/telegram"
 
 	// "log"
 	"main/controllers"
 	"main/crypto"
-	"main/database"
 	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
 	"main/util"
 	"maps"
 	"time"
@@ -18,21 +21,27 @@ import (
 
 type AddSecret struct {
 	Name   string
-	Client tgbotapi.BotAPI
+	Client telegram.Messenger
+	Repos  repository.Repos
 }
 
 // baseForm отображает форму ввода с кнопкой отмены и регистрирует следующий шаг.
-func baseForm(client tgbotapi.BotAPI, update tgbotapi.Update, params map[string]any, formText, CancelMessage string, formHandler controllers.NextStepFunc, cancelCallbackData string, isLastStep bool) error {
+func baseForm(client telegram.Messenger, repos repository.Repos, update tgbotapi.Update, params map[string]any, formText, CancelMessage string, formHandler controllers.NextStepFunc, cancelCallbackData string, isLastStep bool) error {
 	client.Request(tgbotapi.NewDeleteMessage(util.GetMessage(update).Chat.ID, util.GetMessage(update).MessageID-1))
 	client.Request(tgbotapi.NewDeleteMessage(util.GetMessage(update).Chat.ID, util.GetMessage(update).MessageID))
 
-	msg := tgbotapi.NewMessage(util.GetMessage(update).Chat.ID, formText)
+	settings, err := controllers.GetUserSettings(repos.Settings, util.GetMessage(update).From.ID)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(util.GetMessage(update).Chat.ID, i18n.T(settings.Language, formText))
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Отмена", cancelCallbackData),
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(settings.Language, "Отмена"), cancelCallbackData),
 		),
 	)
-	_, err := client.Send(msg)
+	_, err = controllers.SendTracked(client, repos.BotMessages, msg, util.GetMessage(update).From.ID)
 	if err != nil {
 		return err
 	}
@@ -46,7 +55,7 @@ func baseForm(client tgbotapi.BotAPI, update tgbotapi.Update, params map[string]
 		Func:          formHandler,
 		Params:        params,
 		CreatedAtTS:   time.Now().Unix(),
-		CancelMessage: CancelMessage,
+		CancelMessage: i18n.T(settings.Language, CancelMessage),
 		IsLastStep:    isLastStep,
 	}
 
@@ -55,12 +64,9 @@ func baseForm(client tgbotapi.BotAPI, update tgbotapi.Update, params map[string]
 	return nil
 }
 
-func (a AddSecret) StartPoll(update tgbotapi.Update) error {
-	callbackDataParams := make(map[string]any)
-	err := json.Unmarshal([]byte(update.CallbackQuery.Data), &callbackDataParams)
-	if err != nil {
-		return err
-	}
+func (a AddSecret) StartPoll(ctx *handlers.Context) error {
+	update := ctx.Update
+	callbackDataParams := ctx.CallbackData
 
 	stepParams := make(map[string]any)
 
@@ -81,19 +87,20 @@ func (a AddSecret) StartPoll(update tgbotapi.Update) error {
 	stepParams["on_cancel"] = string(cancelParamsJSON)
 
 	return baseForm(
-		stepParams["client"].(tgbotapi.BotAPI),
+		stepParams["client"].(telegram.Messenger),
+		a.Repos,
 		stepParams["update"].(tgbotapi.Update),
 		stepParams,
 		"Отправьте название секрета ниже:",
 		"Создание секрета отменено",
-		getTitle,
+		withRepos(a.Repos, getTitle),
 		stepParams["on_cancel"].(string),
 		false,
 	)
 }
 
-func finishPollWithoutSession(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update) bool {
-	if util.HasActiveSession(stepUpdate) {
+func finishPollWithoutSession(client telegram.Messenger, sessions repository.SessionRepo, stepUpdate tgbotapi.Update) bool {
+	if util.HasActiveSession(sessions, stepUpdate) {
 		return false
 	}
 
@@ -105,8 +112,8 @@ func finishPollWithoutSession(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update
 	return true
 }
 
-func encryptDataWithSessionPassword(stepParams map[string]any, data string) (string, error) {
-	session, err := util.GetSession(stepParams["update"].(tgbotapi.Update))
+func encryptDataWithSessionPassword(sessions repository.SessionRepo, stepParams map[string]any, data string) (string, error) {
+	session, err := util.GetSession(sessions, stepParams["update"].(tgbotapi.Update))
 	if err != nil {
 		log.Printf("Failed to get session: %v", err)
 		return "", err
@@ -138,34 +145,35 @@ func encryptDataWithSessionPassword(stepParams map[string]any, data string) (str
 	return encrypted, nil
 }
 
-func getTitle(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+func getTitle(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
 	secret := &models.Secrets{Title: stepUpdate.Message.Text}
 	stepParams["update"] = stepUpdate
 	stepParams["new_secret"] = secret
 
-	if finishPollWithoutSession(client, stepUpdate) {
+	if finishPollWithoutSession(client, repos.Sessions, stepUpdate) {
 		return nil
 	}
 
 	return baseForm(
-		stepParams["client"].(tgbotapi.BotAPI),
+		stepParams["client"].(telegram.Messenger),
+		repos,
 		stepParams["update"].(tgbotapi.Update),
 		stepParams,
 		"Отправьте ваш логин:",
 		"Создание секрета отменено",
-		getLogin,
+		withRepos(repos, getLogin),
 		stepParams["on_cancel"].(string),
 		false,
 	)
 }
 
-func getLogin(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+func getLogin(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
 	stepParams["update"] = stepUpdate
-	if finishPollWithoutSession(client, stepUpdate) {
+	if finishPollWithoutSession(client, repos.Sessions, stepUpdate) {
 		return nil
 	}
 
-	encryptedLogin, err := encryptDataWithSessionPassword(stepParams, stepUpdate.Message.Text)
+	encryptedLogin, err := encryptDataWithSessionPassword(repos.Sessions, stepParams, stepUpdate.Message.Text)
 	if err != nil {
 		client.Request(tgbotapi.NewDeleteMessage(util.GetMessage(stepUpdate).Chat.ID, util.GetMessage(stepUpdate).MessageID-1))
 		client.Request(tgbotapi.NewDeleteMessage(util.GetMessage(stepUpdate).Chat.ID, util.GetMessage(stepUpdate).MessageID))
@@ -178,24 +186,25 @@ func getLogin(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams map
 	*stepParams["new_secret"].(*models.Secrets) = *editedSecret
 
 	return baseForm(
-		stepParams["client"].(tgbotapi.BotAPI),
+		stepParams["client"].(telegram.Messenger),
+		repos,
 		stepParams["update"].(tgbotapi.Update),
 		stepParams,
 		"Отправьте ваш пароль:",
 		"Создание секрета отменено",
-		getPassword,
+		withRepos(repos, getPassword),
 		stepParams["on_cancel"].(string),
 		false,
 	)
 }
 
-func getPassword(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+func getPassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
 	stepParams["update"] = stepUpdate
-	if finishPollWithoutSession(client, stepUpdate) {
+	if finishPollWithoutSession(client, repos.Sessions, stepUpdate) {
 		return nil
 	}
 
-	encryptedPassword, err := encryptDataWithSessionPassword(stepParams, stepUpdate.Message.Text)
+	encryptedPassword, err := encryptDataWithSessionPassword(repos.Sessions, stepParams, stepUpdate.Message.Text)
 	if err != nil {
 		client.Request(tgbotapi.NewDeleteMessage(util.GetMessage(stepUpdate).Chat.ID, util.GetMessage(stepUpdate).MessageID-1))
 		client.Request(tgbotapi.NewDeleteMessage(util.GetMessage(stepUpdate).Chat.ID, util.GetMessage(stepUpdate).MessageID))
@@ -208,20 +217,21 @@ func getPassword(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams
 	*stepParams["new_secret"].(*models.Secrets) = *editedSecret
 
 	return baseForm(
-		stepParams["client"].(tgbotapi.BotAPI),
+		stepParams["client"].(telegram.Messenger),
+		repos,
 		stepParams["update"].(tgbotapi.Update),
 		stepParams,
 		"Отправьте ссылку на ресурс (Или \"-\" чтобы пропустить):",
 		"Создание секрета отменено",
-		getSiteLink,
+		withRepos(repos, getSiteLink),
 		stepParams["on_cancel"].(string),
 		false,
 	)
 }
 
-func getSiteLink(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+func getSiteLink(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
 	stepParams["update"] = stepUpdate
-	if finishPollWithoutSession(client, stepUpdate) {
+	if finishPollWithoutSession(client, repos.Sessions, stepUpdate) {
 		return nil
 	}
 
@@ -232,20 +242,21 @@ func getSiteLink(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams
 	}
 
 	return baseForm(
-		stepParams["client"].(tgbotapi.BotAPI),
+		stepParams["client"].(telegram.Messenger),
+		repos,
 		stepParams["update"].(tgbotapi.Update),
 		stepParams,
 		"Отправьте описание секрета (Или \"-\" чтобы пропустить):",
 		"Создание секрета отменено",
-		getDescriptionAndFinishPoll,
+		withRepos(repos, getDescriptionAndFinishPoll),
 		stepParams["on_cancel"].(string),
 		true,
 	)
 }
 
-func getDescriptionAndFinishPoll(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+func getDescriptionAndFinishPoll(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
 	stepParams["update"] = stepUpdate
-	if finishPollWithoutSession(client, stepUpdate) {
+	if finishPollWithoutSession(client, repos.Sessions, stepUpdate) {
 		return nil
 	}
 
@@ -255,16 +266,29 @@ func getDescriptionAndFinishPoll(client tgbotapi.BotAPI, stepUpdate tgbotapi.Upd
 		*stepParams["new_secret"].(*models.Secrets) = *editedSecret
 	}
 
-	db := database.GetDB()
+	session, err := util.GetSession(repos.Sessions, stepUpdate)
+	if err != nil {
+		return err
+	}
+
 	editedSecret := stepParams["new_secret"].(*models.Secrets)
 	editedSecret.UserID = util.GetMessage(stepUpdate).From.ID
+	editedSecret.IsDecoy = session.IsDuress
 	*stepParams["new_secret"].(*models.Secrets) = *editedSecret
 
-	_, err := db.Model(stepParams["new_secret"].(*models.Secrets)).Insert()
+	err = repos.Secrets.Create(stepParams["new_secret"].(*models.Secrets))
 	if err != nil {
 		return err
 	}
 
+	controllers.Audit(repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditSecretCreate,
+		UserID:    stepUpdate.Message.From.ID,
+		ChatID:    stepUpdate.Message.Chat.ID,
+		SecretID:  editedSecret.ID,
+		IsDuress:  session.IsDuress,
+	})
+
 	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
 	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
 
@@ -278,22 +302,27 @@ func getDescriptionAndFinishPoll(client tgbotapi.BotAPI, stepUpdate tgbotapi.Upd
 		return err
 	}
 
-	response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, "Секрет успешно создан!")
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(settings.Language, "Секрет успешно создан!"))
 	response.ReplyMarkup = tgbotapi.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{
-			{Text: "К секретам", CallbackData: util.StringPtr(string(callbackDataJSON))},
+			{Text: i18n.T(settings.Language, "К секретам"), CallbackData: util.StringPtr(string(callbackDataJSON))},
 		}},
 	}
 
-	_, err = client.Request(response)
+	_, err = controllers.SendTracked(client, repos.BotMessages, response, stepUpdate.Message.From.ID)
 
 	return err
 }
 
-func (a AddSecret) Run(update tgbotapi.Update) error {
-	controllers.ClearNextStepForUser(update, &a.Client, true)
+func (a AddSecret) Run(ctx *handlers.Context) error {
+	controllers.ClearNextStepForUser(ctx.Update, a.Client, true)
 
-	return a.StartPoll(update)
+	return a.StartPoll(ctx)
 }
 
 func (a AddSecret) GetName() string {
@@ -0,0 +1,219 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/controllers"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const AUDIT_EVENTS_PER_PAGE = 10
+
+var auditEventTitles = map[string]string{
+	controllers.AuditLogin:          "Вход",
+	controllers.AuditLoginFailed:    "Неудачная попытка входа",
+	controllers.AuditSecretView:     "Просмотр секрета",
+	controllers.AuditSecretCreate:   "Создание секрета",
+	controllers.AuditSecretEdit:     "Изменение секрета",
+	controllers.AuditSecretDelete:   "Удаление секрета",
+	controllers.AuditExport:         "Экспорт",
+	controllers.AuditSessionExpired: "Сессия истекла",
+	controllers.AuditSessionRevoked: "Сессия завершена",
+	controllers.AuditLock:           "Блокировка",
+	controllers.AuditWipe:           "Экстренное удаление данных",
+	controllers.AuditRestore:        "Восстановление из резервной копии",
+	controllers.AuditImport:         "Импорт из другого менеджера паролей",
+	controllers.AuditPlainExport:    "Экспорт в открытом виде",
+	controllers.AuditShareCreate:    "Создание ссылки на секрет",
+	controllers.AuditShareView:      "Просмотр секрета по ссылке",
+	controllers.AuditShareRevoke:    "Отзыв ссылок на секрет",
+}
+
+type AuditLog struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// auditLogPage формирует страницу журнала пользователя, начиная с новых событий
+func auditLogPage(repos repository.Repos, userID int64, isDuress bool, lang string, eventsOffset int, sessionKey, pageOffset any) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	events, count, err := controllers.GetAuditEvents(repos.Audit, userID, isDuress, eventsOffset, AUDIT_EVENTS_PER_PAGE)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	text := i18n.T(lang, "Журнал") + "\n"
+	if count == 0 {
+		text += "\n" + i18n.T(lang, "Событий пока нет.")
+	}
+
+	for _, event := range events {
+		line := fmt.Sprintf("\n%s %s", time.Unix(event.CreatedAt, 0).Format(SESSION_TIME_LAYOUT), i18n.T(lang, auditEventTitles[event.EventType]))
+		if event.SecretID != 0 {
+			line += fmt.Sprintf(" #%d", event.SecretID)
+		}
+
+		text += line + fmt.Sprintf(i18n.T(lang, " (чат %d)"), event.ChatID)
+	}
+
+	pageData := func(offset int) (string, error) {
+		dataJSON, err := json.Marshal(map[string]any{
+			"a": "j",        // action: journal
+			"k": sessionKey, // session_key
+			"o": pageOffset, // offest
+			"e": offset,     // events offset
+		})
+
+		return string(dataJSON), err
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup()
+	navigationRow := []tgbotapi.InlineKeyboardButton{}
+
+	if eventsOffset > 0 {
+		prevData, err := pageData(max(eventsOffset-AUDIT_EVENTS_PER_PAGE, 0))
+		if err != nil {
+			return "", tgbotapi.InlineKeyboardMarkup{}, err
+		}
+
+		navigationRow = append(navigationRow, tgbotapi.InlineKeyboardButton{Text: i18n.T(lang, "Новее"), CallbackData: util.StringPtr(prevData)})
+	}
+
+	if eventsOffset+AUDIT_EVENTS_PER_PAGE < count {
+		nextData, err := pageData(eventsOffset + AUDIT_EVENTS_PER_PAGE)
+		if err != nil {
+			return "", tgbotapi.InlineKeyboardMarkup{}, err
+		}
+
+		navigationRow = append(navigationRow, tgbotapi.InlineKeyboardButton{Text: i18n.T(lang, "Старее"), CallbackData: util.StringPtr(nextData)})
+	}
+
+	if len(navigationRow) > 0 {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, navigationRow)
+	}
+
+	backDataJSON, err := json.Marshal(map[string]any{
+		"a": "g", // action: settings
+		"k": sessionKey,
+		"o": pageOffset,
+	})
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{
+		{Text: i18n.T(lang, "Назад"), CallbackData: util.StringPtr(string(backDataJSON))},
+	})
+
+	return text, keyboard, nil
+}
+
+func (a AuditLog) ShowPage(ctx *handlers.Context) error {
+	update := ctx.Update
+	session := ctx.Session
+	data := ctx.CallbackData
+
+	controllers.ClearNextStepForUser(update, a.Client, true)
+	updateSession(a.Repos.Sessions, session)
+
+	settings, err := controllers.GetUserSettings(a.Repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+
+	eventsOffset, _ := data["e"].(float64)
+
+	text, keyboard, err := auditLogPage(a.Repos, update.CallbackQuery.From.ID, session.IsDuress, settings.Language, int(eventsOffset), data["k"], data["o"])
+	if err != nil {
+		return err
+	}
+
+	_, err = a.Client.Request(tgbotapi.NewEditMessageTextAndMarkup(
+		update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID,
+		text,
+		keyboard,
+	))
+
+	return err
+}
+
+// Export отправляет администратору весь журнал вместе с результатом проверки цепочки хешей
+func (a AuditLog) Export(ctx *handlers.Context) error {
+	update := ctx.Update
+	a.Client.Request(tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID))
+
+	// В хранилище-приманке команда ведет себя как неизвестная
+	if ctx.Session != nil && ctx.Session.IsDuress {
+		return nil
+	}
+
+	events, err := controllers.GetAllAuditEvents(a.Repos.Audit)
+	if err != nil {
+		return err
+	}
+
+	eventsJSON, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	key, err := controllers.AuditKey()
+	if err != nil {
+		return err
+	}
+
+	head, err := controllers.ReadAuditHead()
+	if err != nil {
+		return err
+	}
+
+	caption := fmt.Sprintf("Журнал доступа: %d событий. Цепочка хешей не нарушена.", len(events))
+	brokenID, truncated := controllers.VerifyAuditChain(events, key, head)
+	switch {
+	case brokenID != 0:
+		caption = fmt.Sprintf("Журнал доступа: %d событий.\n\nВнимание! Цепочка хешей нарушена на записи %d.", len(events), brokenID)
+	case truncated:
+		caption = fmt.Sprintf("Журнал доступа: %d событий.\n\nВнимание! Последние записи журнала удалены.", len(events))
+	case head == nil && len(events) > 0:
+		caption += "\nПоследняя запись журнала не сохранена, удаление последних записей не проверяется."
+	}
+
+	document := tgbotapi.NewDocument(update.Message.Chat.ID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("audit-%d.json", time.Now().Unix()),
+		Bytes: eventsJSON,
+	})
+	document.Caption = caption
+
+	_, err = a.Client.Send(document)
+	if err != nil {
+		return err
+	}
+
+	controllers.Audit(a.Repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditExport,
+		UserID:    update.Message.From.ID,
+		ChatID:    update.Message.Chat.ID,
+	})
+
+	return nil
+}
+
+func (a AuditLog) Run(ctx *handlers.Context) error {
+	if ctx.Update.CallbackQuery != nil {
+		return a.ShowPage(ctx)
+	}
+
+	return a.Export(ctx)
+}
+
+func (a AuditLog) GetName() string {
+	return a.Name
+}
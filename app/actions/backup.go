@@ -0,0 +1,591 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/controllers"
+	"main/crypto"
+	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	BACKUP_FORMAT  = "vault-backup"
+	BACKUP_VERSION = 1
+
+	MIN_BACKUP_PASSPHRASE_LENGTH = 8
+
+	RESTORE_MERGE   = "объединить"
+	RESTORE_REPLACE = "заменить"
+)
+
+type Backup struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+type Restore struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// backupFile - файл резервной копии. Заголовок открыт, а секреты зашифрованы фразой-паролем,
+// которая не связана с мастер-паролем: копию можно восстановить и после его смены.
+type backupFile struct {
+	Format  string         `json:"format"`
+	Version int            `json:"version"`
+	Sealed  *crypto.Sealed `json:"sealed"`
+}
+
+type vaultBackup struct {
+	CreatedAt int64          `json:"created_at"`
+	Secrets   []backupSecret `json:"secrets"`
+}
+
+// backupSecret - секрет в открытом виде. Логин и пароль снова шифруются мастер-паролем при восстановлении.
+type backupSecret struct {
+	Title       string `json:"title"`
+	Login       string `json:"login"`
+	Password    string `json:"password"`
+	SiteLink    string `json:"site_link"`
+	Description string `json:"description"`
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+// restorePlan - чем копия отличается от хранилища. Секреты сопоставляются по названию и логину.
+type restorePlan struct {
+	changes   repository.SecretChanges
+	unchanged int
+	vaultOnly []int64 // Секреты, которых нет в копии: их удаляет только замена
+}
+
+// backupHeader защищает формат и версию файла от подмены: они входят в шифрование как дополнительные данные
+func backupHeader(format string, version int) []byte {
+	return []byte(fmt.Sprintf("%s/%d", format, version))
+}
+
+// askVaultPassword переводит text и cancelMessage на язык пользователя, как baseForm
+func askVaultPassword(client telegram.Messenger, repos repository.Repos, update tgbotapi.Update, text, cancelMessage string, next repoStepFunc) error {
+	client.Request(tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(repos.Settings, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = controllers.SendTracked(client, repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, i18n.T(settings.Language, text)), update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	controllers.GetNextStepManager().RegisterNextStepAction(controllers.NextStepKey{
+		ChatID: update.Message.Chat.ID,
+		UserID: update.Message.From.ID,
+	}, controllers.NextStepAction{
+		Func:          withRepos(repos, next),
+		Params:        make(map[string]any),
+		CreatedAtTS:   time.Now().Unix(),
+		CancelMessage: i18n.T(settings.Language, cancelMessage),
+	})
+
+	return nil
+}
+
+// checkVaultPassword проверяет мастер-пароль так же, как вход. Пароль под принуждением открывает хранилище-приманку.
+func checkVaultPassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) (bool, error) {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	blocked, err := loginBlocked(client, repos, stepUpdate)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	user, err := repos.Users.GetByTelegramID(stepUpdate.Message.From.ID)
+	if err != nil {
+		return false, err
+	}
+
+	password := stepUpdate.Message.Text
+	isDuress := isDuressPassword(user, password)
+	if !isDuress && !isRealPassword(user, password) {
+		controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+			ChatID: stepUpdate.Message.Chat.ID,
+			UserID: stepUpdate.Message.From.ID,
+		}, client, false)
+
+		settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+		if err != nil {
+			return false, err
+		}
+
+		return false, rejectPassword(client, repos, stepUpdate, user, password, i18n.T(settings.Language, "Неверный пароль."))
+	}
+
+	stepParams["password"] = password
+	stepParams["duress"] = isDuress
+
+	return true, nil
+}
+
+// nextBackupStep отправляет вопрос и ждет ответа на него в step
+func nextBackupStep(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any, text, cancelMessage string, step repoStepFunc) error {
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = controllers.SendTracked(client, repos.BotMessages, tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(settings.Language, text)), stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	controllers.GetNextStepManager().RegisterNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, controllers.NextStepAction{
+		Func:          withRepos(repos, step),
+		Params:        stepParams,
+		CreatedAtTS:   time.Now().Unix(),
+		CancelMessage: i18n.T(settings.Language, cancelMessage),
+	})
+
+	return nil
+}
+
+// finishBackupStep завершает диалог сообщением text
+func finishBackupStep(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, text string) error {
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = controllers.SendTracked(client, repos.BotMessages, tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(settings.Language, text)), stepUpdate.Message.From.ID)
+
+	return err
+}
+
+func handleBackupPassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	ok, err := checkVaultPassword(client, repos, stepUpdate, stepParams)
+	if err != nil || !ok {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	return nextBackupStep(client, repos, stepUpdate, stepParams, fmt.Sprintf(i18n.T(settings.Language,
+		"Придумайте фразу-пароль для резервной копии, не короче %d символов. Она не связана с мастер-паролем и понадобится для восстановления:"),
+		MIN_BACKUP_PASSPHRASE_LENGTH,
+	), "Резервное копирование отменено", handleBackupPassphrase)
+}
+
+func handleBackupPassphrase(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	passphrase := stepUpdate.Message.Text
+	if utf8.RuneCountInString(passphrase) < MIN_BACKUP_PASSPHRASE_LENGTH {
+		return nextBackupStep(client, repos, stepUpdate, stepParams, fmt.Sprintf(
+			i18n.T(settings.Language, "Фраза-пароль должна быть не короче %d символов. Придумайте другую:"), MIN_BACKUP_PASSPHRASE_LENGTH,
+		), "Резервное копирование отменено", handleBackupPassphrase)
+	}
+
+	stepParams["passphrase"] = passphrase
+
+	return nextBackupStep(client, repos, stepUpdate, stepParams, "Повторите фразу-пароль:", "Резервное копирование отменено", handleBackupPassphraseRepeat)
+}
+
+// decryptSecrets расшифровывает секреты хранилища в памяти для экспорта
+func decryptSecrets(secrets []models.Secrets, password string) ([]backupSecret, error) {
+	decrypted := []backupSecret{}
+
+	for _, secret := range secrets {
+		login, err := crypto.Decrypt(secret.Login, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt login: %w", err)
+		}
+
+		secretPassword, err := crypto.Decrypt(secret.Password, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt password: %w", err)
+		}
+
+		decrypted = append(decrypted, backupSecret{
+			Title:       secret.Title,
+			Login:       login,
+			Password:    secretPassword,
+			SiteLink:    secret.SiteLink,
+			Description: secret.Description,
+			CreatedAt:   secret.CreatedAt,
+			UpdatedAt:   secret.UpdatedAt,
+		})
+	}
+
+	return decrypted, nil
+}
+
+// buildBackup расшифровывает секреты хранилища и запечатывает их фразой-паролем
+func buildBackup(secrets []models.Secrets, password, passphrase string) ([]byte, error) {
+	decrypted, err := decryptSecrets(secrets, password)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := vaultBackup{
+		CreatedAt: time.Now().Unix(),
+		Secrets:   decrypted,
+	}
+
+	plaintext, err := json.Marshal(backup)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := crypto.Seal(plaintext, passphrase, backupHeader(BACKUP_FORMAT, BACKUP_VERSION))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(backupFile{
+		Format:  BACKUP_FORMAT,
+		Version: BACKUP_VERSION,
+		Sealed:  sealed,
+	}, "", "  ")
+}
+
+func handleBackupPassphraseRepeat(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	if stepUpdate.Message.Text != stepParams["passphrase"].(string) {
+		return finishBackupStep(client, repos, stepUpdate, "Фразы-пароли не совпадают. Резервное копирование отменено.")
+	}
+
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	isDuress := stepParams["duress"].(bool)
+
+	secrets, err := repos.Secrets.List(stepUpdate.Message.From.ID, isDuress, repository.SortOrderOld, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	data, err := buildBackup(secrets, stepParams["password"].(string), stepParams["passphrase"].(string))
+	if err != nil {
+		return err
+	}
+
+	document := tgbotapi.NewDocument(stepUpdate.Message.Chat.ID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("vault-backup-%s.json", time.Now().Format("2006-01-02")),
+		Bytes: data,
+	})
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	document.Caption = fmt.Sprintf(
+		i18n.T(settings.Language, "Резервная копия хранилища: %d секретов.\n\nХраните фразу-пароль отдельно от файла. Восстановить копию можно командой /restore."),
+		len(secrets),
+	)
+
+	_, err = client.Send(document)
+	if err != nil {
+		return err
+	}
+
+	controllers.Audit(repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditExport,
+		UserID:    stepUpdate.Message.From.ID,
+		ChatID:    stepUpdate.Message.Chat.ID,
+		IsDuress:  isDuress,
+	})
+
+	return nil
+}
+
+func handleRestorePassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	ok, err := checkVaultPassword(client, repos, stepUpdate, stepParams)
+	if err != nil || !ok {
+		return err
+	}
+
+	return nextBackupStep(client, repos, stepUpdate, stepParams, "Отправьте файл резервной копии:", "Восстановление отменено", handleRestoreDocument)
+}
+
+// parseBackupFile проверяет, что файл - резервная копия поддерживаемой версии
+func parseBackupFile(data []byte) (*backupFile, error) {
+	file := &backupFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+
+	if file.Format != BACKUP_FORMAT || file.Sealed == nil {
+		return nil, errors.New("not a vault backup")
+	}
+	if file.Version != BACKUP_VERSION {
+		return nil, fmt.Errorf("unsupported backup version %d", file.Version)
+	}
+
+	return file, nil
+}
+
+func handleRestoreDocument(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+
+	document := stepUpdate.Message.Document
+	if document == nil {
+		client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+		return nextBackupStep(client, repos, stepUpdate, stepParams, "Отправьте файл резервной копии документом:", "Восстановление отменено", handleRestoreDocument)
+	}
+
+	if document.FileSize > telegram.MaxDownloadSize {
+		return finishBackupStep(client, repos, stepUpdate, "Файл слишком большой. Восстановление отменено.")
+	}
+
+	data, err := telegram.DownloadFile(client, document.FileID)
+	if err != nil {
+		return err
+	}
+
+	file, err := parseBackupFile(data)
+	if err != nil {
+		return finishBackupStep(client, repos, stepUpdate, "Это не резервная копия хранилища. Восстановление отменено.")
+	}
+
+	stepParams["backup"] = file
+
+	return nextBackupStep(client, repos, stepUpdate, stepParams, "Введите фразу-пароль резервной копии:", "Восстановление отменено", handleRestorePassphrase)
+}
+
+// encryptBackupSecret превращает секрет из копии в запись хранилища, зашифрованную мастер-паролем
+func encryptBackupSecret(item backupSecret, password string) (models.Secrets, error) {
+	login, err := crypto.Encrypt(item.Login, password)
+	if err != nil {
+		return models.Secrets{}, err
+	}
+
+	secretPassword, err := crypto.Encrypt(item.Password, password)
+	if err != nil {
+		return models.Secrets{}, err
+	}
+
+	return models.Secrets{
+		CreatedAt:   item.CreatedAt,
+		UpdatedAt:   item.UpdatedAt,
+		Title:       item.Title,
+		Login:       login,
+		Password:    secretPassword,
+		SiteLink:    item.SiteLink,
+		Description: item.Description,
+	}, nil
+}
+
+// planRestore сравнивает копию с хранилищем. Одинаковые название и логин считаются одним секретом.
+func planRestore(secrets repository.SecretRepo, userID int64, isDecoy bool, password string, backup []backupSecret) (restorePlan, error) {
+	plan := restorePlan{}
+
+	current, err := secrets.List(userID, isDecoy, repository.SortOrderOld, 0, 0)
+	if err != nil {
+		return plan, err
+	}
+
+	byKey := map[string][]models.Secrets{}
+	for _, secret := range current {
+		login, err := crypto.Decrypt(secret.Login, password)
+		if err != nil {
+			return plan, fmt.Errorf("failed to decrypt login: %w", err)
+		}
+
+		secret.Password, err = crypto.Decrypt(secret.Password, password)
+		if err != nil {
+			return plan, fmt.Errorf("failed to decrypt password: %w", err)
+		}
+
+		key := secret.Title + "\x00" + login
+		byKey[key] = append(byKey[key], secret)
+	}
+
+	now := time.Now().Unix()
+	for _, item := range backup {
+		restored, err := encryptBackupSecret(item, password)
+		if err != nil {
+			return plan, err
+		}
+
+		key := item.Title + "\x00" + item.Login
+		matches := byKey[key]
+		if len(matches) == 0 {
+			plan.changes.Create = append(plan.changes.Create, restored)
+			continue
+		}
+
+		existing := matches[0]
+		byKey[key] = matches[1:]
+
+		if existing.Password == item.Password && existing.SiteLink == item.SiteLink && existing.Description == item.Description {
+			plan.unchanged++
+			continue
+		}
+
+		restored.ID = existing.ID
+		restored.UpdatedAt = now
+		plan.changes.Update = append(plan.changes.Update, restored)
+	}
+
+	for _, rest := range byKey {
+		for _, secret := range rest {
+			plan.vaultOnly = append(plan.vaultOnly, secret.ID)
+		}
+	}
+
+	return plan, nil
+}
+
+func handleRestorePassphrase(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	file := stepParams["backup"].(*backupFile)
+
+	plaintext, err := file.Sealed.Open(stepUpdate.Message.Text, backupHeader(file.Format, file.Version))
+	if err != nil {
+		return finishBackupStep(client, repos, stepUpdate, "Неверная фраза-пароль, или файл поврежден. Восстановление отменено.")
+	}
+
+	backup := vaultBackup{}
+	if err := json.Unmarshal(plaintext, &backup); err != nil {
+		return finishBackupStep(client, repos, stepUpdate, "Файл резервной копии поврежден. Восстановление отменено.")
+	}
+
+	plan, err := planRestore(repos.Secrets, stepUpdate.Message.From.ID, stepParams["duress"].(bool), stepParams["password"].(string), backup.Secrets)
+	if err != nil {
+		return err
+	}
+
+	stepParams["secrets"] = backup.Secrets
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	return nextBackupStep(client, repos, stepUpdate, stepParams, fmt.Sprintf(i18n.T(lang,
+		"Резервная копия от %s: %d секретов.\n\nНовых: %d\nИзменившихся: %d\nБез изменений: %d\nЕсть только в хранилище: %d\n\n"+
+			"Отправьте «%s», чтобы добавить новые и обновить изменившиеся секреты, или «%s», чтобы хранилище совпало с копией: секреты, которых нет в копии, будут удалены. Любой другой ответ отменит восстановление."),
+		time.Unix(backup.CreatedAt, 0).Format(SESSION_TIME_LAYOUT),
+		len(backup.Secrets),
+		len(plan.changes.Create),
+		len(plan.changes.Update),
+		plan.unchanged,
+		len(plan.vaultOnly),
+		i18n.T(lang, RESTORE_MERGE),
+		i18n.T(lang, RESTORE_REPLACE),
+	), "Восстановление отменено", handleRestoreConfirmation)
+}
+
+// restoreMode распознает ответ на сводку восстановления на языке пользователя или по-русски
+func restoreMode(answer, lang string) string {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	for _, mode := range []string{RESTORE_MERGE, RESTORE_REPLACE} {
+		if answer == mode || answer == strings.ToLower(i18n.T(lang, mode)) {
+			return mode
+		}
+	}
+
+	return ""
+}
+
+func handleRestoreConfirmation(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	mode := restoreMode(stepUpdate.Message.Text, settings.Language)
+	if mode == "" {
+		return finishBackupStep(client, repos, stepUpdate, "Восстановление отменено.")
+	}
+
+	telegramID := stepUpdate.Message.From.ID
+	isDuress := stepParams["duress"].(bool)
+
+	// Хранилище могло измениться, пока пользователь читал сводку, поэтому сравниваем заново
+	plan, err := planRestore(repos.Secrets, telegramID, isDuress, stepParams["password"].(string), stepParams["secrets"].([]backupSecret))
+	if err != nil {
+		return err
+	}
+	if mode == RESTORE_REPLACE {
+		plan.changes.Delete = plan.vaultOnly
+	}
+
+	err = repos.Secrets.Apply(telegramID, isDuress, plan.changes)
+	if err != nil {
+		return err
+	}
+
+	controllers.Audit(repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditRestore,
+		UserID:    telegramID,
+		ChatID:    stepUpdate.Message.Chat.ID,
+		IsDuress:  isDuress,
+	})
+
+	return finishBackupStep(client, repos, stepUpdate, fmt.Sprintf(i18n.T(settings.Language,
+		"Хранилище восстановлено.\n\nДобавлено: %d\nОбновлено: %d\nУдалено: %d"),
+		len(plan.changes.Create), len(plan.changes.Update), len(plan.changes.Delete),
+	))
+}
+
+func (b Backup) Run(ctx *handlers.Context) error {
+	controllers.ClearNextStepForUser(ctx.Update, b.Client, false)
+
+	return askVaultPassword(b.Client, b.Repos, ctx.Update, "Резервная копия хранилища.\n\nВведите мастер-пароль:", "Резервное копирование отменено", handleBackupPassword)
+}
+
+func (b Backup) GetName() string {
+	return b.Name
+}
+
+func (r Restore) Run(ctx *handlers.Context) error {
+	controllers.ClearNextStepForUser(ctx.Update, r.Client, false)
+
+	return askVaultPassword(r.Client, r.Repos, ctx.Update, "Восстановление из резервной копии.\n\nВведите мастер-пароль:", "Восстановление отменено", handleRestorePassword)
+}
+
+func (r Restore) GetName() string {
+	return r.Name
+}
@@ -1,50 +1,55 @@
 package actions
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"main/database"
-	"main/database/models"
+	"main/controllers"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
 
-	"github.com/go-pg/pg/v10"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type DeleteSecret struct {
 	Name   string
-	Client tgbotapi.BotAPI
-	DB     *pg.DB
+	Client telegram.Messenger
+	Repos  repository.Repos
 }
 
-func (d DeleteSecret) Run(update tgbotapi.Update) error {
-	d.DB = database.GetDB()
+func (d DeleteSecret) Run(ctx *handlers.Context) error {
+	update := ctx.Update
+	session := ctx.Session
 
 	if update.CallbackQuery == nil {
 		return errors.New("callback query is nil")
 	}
 
-	var data viewSecretCallbackData
-	if err := json.Unmarshal([]byte(update.CallbackQuery.Data), &data); err != nil {
-		return fmt.Errorf("failed to unmarshal callback data: %w", err)
-	}
+	// Данные кнопки разбирает кодек маршрута
+	data := *ctx.Payload.(*viewSecretCallbackData)
 
-	_, err := d.DB.Model(&models.Secrets{}).Where("id = ?", data.SecretID).Delete()
+	_, err := d.Repos.Secrets.Delete(update.CallbackQuery.From.ID, session.IsDuress, int64(data.SecretID))
 	if err != nil {
 		return fmt.Errorf("failed to delete secret: %w", err)
 	}
 
-	d.Client.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, "Секрет удален"))
+	controllers.Audit(d.Repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditSecretDelete,
+		UserID:    update.CallbackQuery.From.ID,
+		ChatID:    update.CallbackQuery.Message.Chat.ID,
+		SecretID:  int64(data.SecretID),
+		IsDuress:  session.IsDuress,
+	})
 
-	var session models.Sessions
-	err = d.DB.Model(&session).Where("user_id = ?", update.CallbackQuery.From.ID).Order("created_at DESC").Limit(1).Select()
+	settings, err := controllers.GetUserSettings(d.Repos.Settings, update.CallbackQuery.From.ID)
 	if err != nil {
-		d.Client.Request(tgbotapi.NewDeleteMessage(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID))
-		
-		return nil
+		return err
 	}
 
-	return MainPage{Name: "main-page-from-delete-page", Client: d.Client}.MainPage(update, &session, data.SessionKey, true)
+	d.Client.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, i18n.T(settings.Language, "Секрет удален")))
+
+	return MainPage{Name: "main-page-from-delete-page", Client: d.Client, Repos: d.Repos}.MainPage(update, session, data.SessionKey, true)
 }
 
 func (d DeleteSecret) GetName() string {
@@ -0,0 +1,431 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/controllers"
+	"main/crypto"
+	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+	"slices"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	DURESS_LOCK_DURATION = 24 * 60 * 60 // На сколько блокируется основное хранилище
+
+	DuressActionNone = ""
+	DuressActionLock = "lock"
+	DuressActionWipe = "wipe"
+)
+
+var (
+	duressActionsOrder = []string{DuressActionNone, DuressActionLock, DuressActionWipe}
+	duressActionTitles = map[string]string{
+		DuressActionNone: "ничего не делать",
+		DuressActionLock: "заблокировать основное хранилище на сутки",
+		DuressActionWipe: "удалить основное хранилище",
+	}
+)
+
+type Duress struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// isRealPassword проверяет мастер-пароль с учетом блокировки основного хранилища
+func isRealPassword(user *models.Users, password string) bool {
+	return crypto.HashString(password) == user.PasswordHash && time.Now().Unix() >= user.VaultLockedUntil
+}
+
+func isDuressPassword(user *models.Users, password string) bool {
+	return user.DuressPasswordHash != "" && crypto.CheckDuressPassword(password, user.DuressPasswordHash)
+}
+
+// isLockedRealPassword проверяет, что введен мастер-пароль от заблокированного основного хранилища
+func isLockedRealPassword(user *models.Users, password string) bool {
+	return crypto.HashString(password) == user.PasswordHash && time.Now().Unix() < user.VaultLockedUntil
+}
+
+// rejectPassword отклоняет неверный пароль. Мастер-пароль во время блокировки основного хранилища
+// отклоняется так же, но не считается неудачной попыткой: иначе блокировка входа и уведомление
+// администратора сработали бы против владельца.
+func rejectPassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, user *models.Users, password, reason string) error {
+	if !isLockedRealPassword(user, password) {
+		return rejectLogin(client, repos, stepUpdate, reason)
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf(i18n.T(settings.Language, "%s\n\nGo away."), reason)
+	_, err = controllers.SendTracked(client, repos.BotMessages, tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, text), stepUpdate.Message.From.ID)
+
+	return err
+}
+
+// applyDuressAction выполняет настроенную реакцию на вход с паролем под принуждением
+func applyDuressAction(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update) error {
+	user, err := repos.Users.GetByTelegramID(stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	switch user.DuressAction {
+	case DuressActionLock:
+		user.VaultLockedUntil = time.Now().Unix() + DURESS_LOCK_DURATION
+		err = repos.Users.Update(user, "vault_locked_until")
+	case DuressActionWipe:
+		_, err = repos.Secrets.DeleteAll(user.TelegramID, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	alertChatID, ok := util.GetDuressAlertChatID()
+	if !user.DuressAlert || !ok {
+		return nil
+	}
+
+	// Уведомление в тот же чат выдало бы хранилище-приманку
+	if alertChatID == stepUpdate.Message.Chat.ID {
+		return nil
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, user.TelegramID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	_, err = client.Send(tgbotapi.NewMessage(alertChatID, fmt.Sprintf(
+		i18n.T(lang, "Внимание! Пользователь %d (@%s) вошел с паролем под принуждением.\nРеакция: %s."),
+		stepUpdate.Message.From.ID,
+		stepUpdate.Message.From.UserName,
+		i18n.T(lang, duressActionTitles[user.DuressAction]),
+	)))
+
+	return err
+}
+
+func (d Duress) AskMasterPassword(ctx *handlers.Context) error {
+	update := ctx.Update
+
+	d.Client.Request(tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(d.Repos.Settings, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = controllers.SendTracked(d.Client, d.Repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, i18n.T(settings.Language, "Введите мастер-пароль для настройки пароля под принуждением:")), update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	// В хранилище-приманке настройка выглядит так же, но мастер-паролем считается пароль под принуждением
+	// и ничего не сохраняется
+	controllers.GetNextStepManager().RegisterNextStepAction(controllers.NextStepKey{
+		ChatID: update.Message.Chat.ID,
+		UserID: update.Message.From.ID,
+	}, controllers.NextStepAction{
+		Func:        withRepos(d.Repos, handleDuressMasterPassword),
+		Params:      map[string]any{"duress": ctx.Session != nil && ctx.Session.IsDuress},
+		CreatedAtTS: time.Now().Unix(),
+	})
+
+	return nil
+}
+
+func handleDuressMasterPassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	stepKey := controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}
+
+	blocked, err := loginBlocked(client, repos, stepUpdate)
+	if err != nil || blocked {
+		return err
+	}
+
+	user, err := repos.Users.GetByTelegramID(stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	valid := isRealPassword(user, stepUpdate.Message.Text)
+	if stepParams["duress"].(bool) {
+		valid = isDuressPassword(user, stepUpdate.Message.Text)
+	}
+
+	if !valid {
+		controllers.GetNextStepManager().RemoveNextStepAction(stepKey, client, false)
+
+		return rejectPassword(client, repos, stepUpdate, user, stepUpdate.Message.Text, i18n.T(settings.Language, "Неверный пароль."))
+	}
+
+	stepParams["password"] = stepUpdate.Message.Text
+
+	_, err = controllers.SendTracked(client, repos.BotMessages, tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(settings.Language, "Отправьте новый пароль под принуждением (или \"-\" чтобы удалить его):")), stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	controllers.GetNextStepManager().RegisterNextStepAction(stepKey, controllers.NextStepAction{
+		Func:        withRepos(repos, handleNewDuressPassword),
+		Params:      stepParams,
+		CreatedAtTS: time.Now().Unix(),
+	})
+
+	return nil
+}
+
+func handleNewDuressPassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	user, err := repos.Users.GetByTelegramID(stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	isDecoy := stepParams["duress"].(bool)
+
+	duressPassword := stepUpdate.Message.Text
+	sameAsMaster := crypto.HashString(duressPassword) == user.PasswordHash
+	if isDecoy {
+		sameAsMaster = duressPassword == stepParams["password"].(string)
+	}
+
+	if duressPassword != "-" && sameAsMaster {
+		_, err = controllers.SendTracked(client, repos.BotMessages, tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(settings.Language, "Пароль под принуждением должен отличаться от мастер-пароля. Отправьте другой пароль:")), stepUpdate.Message.From.ID)
+
+		return err
+	}
+
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	if isDecoy {
+		decoyUser := &models.Users{}
+		if duressPassword != "-" {
+			decoyUser.DuressPasswordHash = "-"
+		}
+
+		return sendDuressOptions(client, repos, stepUpdate, decoyUser, true, settings.Language)
+	}
+
+	if duressPassword == "-" {
+		user.DuressPasswordHash = ""
+		user.DuressTOTPSecret = ""
+	} else {
+		user.DuressPasswordHash = crypto.HashDuressPassword(duressPassword)
+		user.DuressTOTPSecret = ""
+
+		if user.TOTPEnabled {
+			totpSecret, err := crypto.Decrypt(user.TOTPSecret, stepParams["password"].(string))
+			if err != nil {
+				return err
+			}
+
+			user.DuressTOTPSecret, err = crypto.Encrypt(totpSecret, duressPassword)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	user.UpdatedAt = time.Now().Unix()
+
+	err = repos.Users.Update(user, "duress_password_hash", "duress_totp_secret", "updated_at")
+	if err != nil {
+		return err
+	}
+
+	return sendDuressOptions(client, repos, stepUpdate, user, false, settings.Language)
+}
+
+func sendDuressOptions(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, user *models.Users, isDecoy bool, lang string) error {
+	text, keyboard, err := duressOptionsPage(user, isDecoy, lang)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, text)
+	response.ReplyMarkup = keyboard
+
+	_, err = controllers.SendTracked(client, repos.BotMessages, response, stepUpdate.Message.From.ID)
+
+	return err
+}
+
+// duressOptionsPage формирует страницу настроек реакции на пароль под принуждением.
+// Страница хранилища-приманки ничего не сохраняет, а держит показанные настройки в данных кнопок.
+func duressOptionsPage(user *models.Users, isDecoy bool, lang string) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	var (
+		actionData = map[string]any{"a": "z", "m": "w"} // mode: action
+		alertData  = map[string]any{"a": "z", "m": "n"} // mode: notify
+		doneData   = map[string]any{"a": "z", "m": "q"} // mode: quit
+	)
+
+	if isDecoy {
+		for _, data := range []map[string]any{actionData, alertData} {
+			data["r"] = user.DuressAction // reaction
+			data["e"] = user.DuressAlert  // alert enabled
+		}
+	}
+
+	actionDataJSON, err := json.Marshal(actionData)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	alertDataJSON, err := json.Marshal(alertData)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	doneDataJSON, err := json.Marshal(doneData)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	doneRow := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "Готово"), string(doneDataJSON)))
+
+	if user.DuressPasswordHash == "" {
+		return i18n.T(lang, "Пароль под принуждением не задан."), tgbotapi.NewInlineKeyboardMarkup(doneRow), nil
+	}
+
+	text := fmt.Sprintf(
+		i18n.T(lang, "Пароль под принуждением задан. Вход с ним откроет отдельное хранилище-приманку.\n\nПри входе: %s"),
+		i18n.T(lang, duressActionTitles[user.DuressAction]),
+	)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "Сменить реакцию"), string(actionDataJSON))),
+	)
+
+	// Уведомлять можно только в отдельный чат из DURESS_ALERT_CHAT_ID: в чате бота уведомление увидел бы тот, кто принуждает
+	if _, ok := util.GetDuressAlertChatID(); ok {
+		alert := i18n.T(lang, "нет")
+		if user.DuressAlert {
+			alert = i18n.T(lang, "да")
+		}
+
+		text += fmt.Sprintf(i18n.T(lang, "\nУведомлять чат оповещений: %s"), alert)
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "Уведомления вкл/выкл"), string(alertDataJSON))),
+		)
+	}
+
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, doneRow)
+
+	return text, keyboard, nil
+}
+
+func (d Duress) UpdateOptions(ctx *handlers.Context) error {
+	update := ctx.Update
+	data := ctx.CallbackData
+
+	if data["m"] == "q" {
+		d.Client.Request(tgbotapi.NewDeleteMessage(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID))
+
+		return nil
+	}
+
+	// Кнопки страницы из хранилища-приманки не меняют настройки, даже если сессия уже закрыта
+	_, isDecoy := data["r"]
+	if ctx.Session != nil && ctx.Session.IsDuress {
+		isDecoy = true
+	}
+
+	var (
+		user *models.Users
+		err  error
+	)
+	if isDecoy {
+		action, _ := data["r"].(string)
+		alert, _ := data["e"].(bool)
+		user = &models.Users{DuressPasswordHash: "-", DuressAction: action, DuressAlert: alert}
+	} else {
+		user, err = d.Repos.Users.GetByTelegramID(update.CallbackQuery.From.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch data["m"] {
+	case "w":
+		next := (slices.Index(duressActionsOrder, user.DuressAction) + 1) % len(duressActionsOrder)
+		user.DuressAction = duressActionsOrder[next]
+	case "n":
+		if _, ok := util.GetDuressAlertChatID(); ok {
+			user.DuressAlert = !user.DuressAlert
+		}
+	}
+
+	if !isDecoy {
+		user.UpdatedAt = time.Now().Unix()
+
+		err = d.Repos.Users.Update(user, "duress_action", "duress_alert", "updated_at")
+		if err != nil {
+			return err
+		}
+	}
+
+	settings, err := controllers.GetUserSettings(d.Repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+
+	text, keyboard, err := duressOptionsPage(user, isDecoy, settings.Language)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.Client.Request(tgbotapi.NewEditMessageTextAndMarkup(
+		update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID,
+		text,
+		keyboard,
+	))
+
+	return err
+}
+
+func (d Duress) Run(ctx *handlers.Context) error {
+	if ctx.Update.CallbackQuery != nil {
+		return d.UpdateOptions(ctx)
+	}
+
+	controllers.ClearNextStepForUser(ctx.Update, d.Client, false)
+
+	return d.AskMasterPassword(ctx)
+}
+
+func (d Duress) GetName() string {
+	return d.Name
+}
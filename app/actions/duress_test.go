@@ -0,0 +1,35 @@
+package actions
+
+import (
+	"main/database/models"
+	"main/i18n"
+	"main/util"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDuressAlertOptionNeedsAlertChat(t *testing.T) {
+	user := &models.Users{DuressPasswordHash: "hash"}
+
+	t.Setenv("DURESS_ALERT_CHAT_ID", "")
+	text, keyboard, err := duressOptionsPage(user, false, i18n.LangRu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(text, "Уведомлять") || len(keyboard.InlineKeyboard) != 2 {
+		t.Fatalf("options without an alert chat offer alerts: %q", text)
+	}
+
+	t.Setenv("DURESS_ALERT_CHAT_ID", strconv.Itoa(42))
+	if chatID, ok := util.GetDuressAlertChatID(); !ok || chatID != 42 {
+		t.Fatalf("alert chat = %d, %v", chatID, ok)
+	}
+	text, keyboard, err = duressOptionsPage(user, false, i18n.LangRu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "Уведомлять чат оповещений: нет") || len(keyboard.InlineKeyboard) != 3 {
+		t.Fatalf("options with an alert chat = %q", text)
+	}
+}
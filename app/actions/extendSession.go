@@ -0,0 +1,64 @@
+package actions
+
+import (
+	"main/controllers"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type ExtendSession struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// SessionExpired используется в RequireSession для кнопок, нажатых без активной сессии
+func SessionExpired(client telegram.Messenger, repos repository.Repos) handlers.HandlerFunc {
+	return func(ctx *handlers.Context) error {
+		return showSessionExpired(client, repos, ctx.Update)
+	}
+}
+
+// showSessionExpired заменяет сообщение, кнопку которого нажали без активной сессии, на понятную подсказку
+func showSessionExpired(client telegram.Messenger, repos repository.Repos, update tgbotapi.Update) error {
+	settings, err := controllers.GetUserSettings(repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+
+	client.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, ""))
+	_, err = client.Request(tgbotapi.NewEditMessageText(
+		update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID,
+		i18n.T(settings.Language, controllers.SessionExpiredText),
+	))
+
+	return err
+}
+
+func (e ExtendSession) Run(ctx *handlers.Context) error {
+	update := ctx.Update
+
+	err := updateSession(e.Repos.Sessions, ctx.Session)
+	if err != nil {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(e.Repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+
+	e.Client.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, i18n.T(settings.Language, "Сессия продлена")))
+	e.Client.Request(tgbotapi.NewDeleteMessage(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID))
+
+	return controllers.CancelMessageExpiry(e.Repos.ExpiringMessages, update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID)
+}
+
+func (e ExtendSession) GetName() string {
+	return e.Name
+}
@@ -0,0 +1,233 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"main/controllers"
+	"main/crypto"
+	"main/handlers"
+	"main/i18n"
+	"main/importer"
+	"main/repository"
+	"main/telegram"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Сколько дубликатов и ошибок перечисляется в отчете об импорте
+const IMPORT_REPORT_LIMIT = 10
+
+const IMPORT_HELP_TEXT = "Импорт секретов.\n\nОтправьте в этот чат файл экспорта другого менеджера паролей: Bitwarden (JSON или CSV без пароля), KeePass (XML), Chrome, Firefox или 1Password (CSV).\n\nФайл будет удален из чата сразу после чтения."
+
+type Import struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// importPlan - записи, которые будут добавлены, и записи, которые уже есть в хранилище
+type importPlan struct {
+	changes    repository.SecretChanges
+	duplicates []importer.Entry
+}
+
+// ReceiveFile читает присланный файл экспорта и спрашивает мастер-пароль, которым будут зашифрованы записи
+func (i Import) ReceiveFile(update tgbotapi.Update) error {
+	settings, err := controllers.GetUserSettings(i.Repos.Settings, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	document := update.Message.Document
+	if document.FileSize > telegram.MaxDownloadSize {
+		_, err := controllers.SendTracked(i.Client, i.Repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, i18n.T(lang, "Файл слишком большой.")), update.Message.From.ID)
+
+		return err
+	}
+
+	data, err := telegram.DownloadFile(i.Client, document.FileID)
+
+	// В файле пароли в открытом виде, поэтому он не должен оставаться в чате
+	i.Client.Request(tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID))
+
+	if err != nil {
+		return err
+	}
+
+	result, err := importer.Parse(data)
+	if err != nil {
+		text := i18n.T(lang, "Не удалось прочитать файл.")
+		switch {
+		case errors.Is(err, importer.ErrUnknownFormat):
+			text = i18n.T(lang, "Формат файла не распознан.") + "\n\n" + i18n.T(lang, IMPORT_HELP_TEXT)
+		case errors.Is(err, importer.ErrEncryptedExport):
+			text = i18n.T(lang, "Файл зашифрован. Экспортируйте хранилище без пароля и отправьте файл снова.")
+		case errors.Is(err, importer.ErrTooManyItems):
+			text = fmt.Sprintf(i18n.T(lang, "В файле больше %d записей. Разделите его на части."), importer.MaxEntries)
+		}
+
+		_, err = controllers.SendTracked(i.Client, i.Repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, text), update.Message.From.ID)
+
+		return err
+	}
+
+	_, err = controllers.SendTracked(i.Client, i.Repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf(
+		i18n.T(lang, "Файл %s: записей %d, с ошибками %d.\n\nВведите мастер-пароль, чтобы импортировать записи:"),
+		result.Format, len(result.Entries), len(result.Errors),
+	)), update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	controllers.GetNextStepManager().RegisterNextStepAction(controllers.NextStepKey{
+		ChatID: update.Message.Chat.ID,
+		UserID: update.Message.From.ID,
+	}, controllers.NextStepAction{
+		Func:          withRepos(i.Repos, handleImportPassword),
+		Params:        map[string]any{"import": result},
+		CreatedAtTS:   time.Now().Unix(),
+		CancelMessage: i18n.T(lang, "Импорт отменен"),
+	})
+
+	return nil
+}
+
+// planImport шифрует записи паролем хранилища и отбрасывает те, что уже есть в нем или повторяются в файле.
+// Дубликатом считается запись с тем же названием и логином.
+func planImport(secrets repository.SecretRepo, userID int64, isDecoy bool, password string, entries []importer.Entry) (importPlan, error) {
+	plan := importPlan{}
+
+	current, err := secrets.List(userID, isDecoy, repository.SortOrderOld, 0, 0)
+	if err != nil {
+		return plan, err
+	}
+
+	seen := map[string]bool{}
+	for _, secret := range current {
+		login, err := crypto.Decrypt(secret.Login, password)
+		if err != nil {
+			return plan, fmt.Errorf("failed to decrypt login: %w", err)
+		}
+
+		seen[secret.Title+"\x00"+login] = true
+	}
+
+	for _, entry := range entries {
+		key := entry.Title + "\x00" + entry.Login
+		if seen[key] {
+			plan.duplicates = append(plan.duplicates, entry)
+			continue
+		}
+		seen[key] = true
+
+		secret, err := encryptBackupSecret(backupSecret{
+			Title:       entry.Title,
+			Login:       entry.Login,
+			Password:    entry.Password,
+			SiteLink:    entry.SiteLink,
+			Description: entry.Description,
+		}, password)
+		if err != nil {
+			return plan, err
+		}
+
+		plan.changes.Create = append(plan.changes.Create, secret)
+	}
+
+	return plan, nil
+}
+
+// importReport перечисляет в отчете первые дубликаты и ошибки
+func importReport(plan importPlan, rowErrors []importer.RowError, lang string) string {
+	text := fmt.Sprintf(
+		i18n.T(lang, "Импорт завершен.\n\nДобавлено: %d\nДубликаты (пропущены): %d\nОшибки: %d"),
+		len(plan.changes.Create), len(plan.duplicates), len(rowErrors),
+	)
+
+	lines := []string{}
+	for _, entry := range plan.duplicates {
+		lines = append(lines, fmt.Sprintf(i18n.T(lang, "%d: %s - уже есть"), entry.Row, entry.Title))
+	}
+	for _, rowError := range rowErrors {
+		lines = append(lines, fmt.Sprintf("%d: %s", rowError.Row, fmt.Sprintf(i18n.T(lang, rowError.Reason), rowError.Args...)))
+	}
+	if len(lines) == 0 {
+		return text
+	}
+
+	text += i18n.T(lang, "\n\nПропущенные записи:\n") + strings.Join(lines[:min(len(lines), IMPORT_REPORT_LIMIT)], "\n")
+	if len(lines) > IMPORT_REPORT_LIMIT {
+		text += fmt.Sprintf(i18n.T(lang, "\nи еще %d"), len(lines)-IMPORT_REPORT_LIMIT)
+	}
+
+	return text
+}
+
+func handleImportPassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	ok, err := checkVaultPassword(client, repos, stepUpdate, stepParams)
+	if err != nil || !ok {
+		return err
+	}
+
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	telegramID := stepUpdate.Message.From.ID
+	isDuress := stepParams["duress"].(bool)
+	result := stepParams["import"].(*importer.Result)
+
+	// Пароль под принуждением импортирует записи в хранилище-приманку
+	plan, err := planImport(repos.Secrets, telegramID, isDuress, stepParams["password"].(string), result.Entries)
+	if err != nil {
+		return err
+	}
+
+	err = repos.Secrets.Apply(telegramID, isDuress, plan.changes)
+	if err != nil {
+		return err
+	}
+
+	controllers.Audit(repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditImport,
+		UserID:    telegramID,
+		ChatID:    stepUpdate.Message.Chat.ID,
+		IsDuress:  isDuress,
+	})
+
+	settings, err := controllers.GetUserSettings(repos.Settings, telegramID)
+	if err != nil {
+		return err
+	}
+
+	_, err = controllers.SendTracked(client, repos.BotMessages, tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, importReport(plan, result.Errors, settings.Language)), telegramID)
+
+	return err
+}
+
+func (i Import) Run(ctx *handlers.Context) error {
+	controllers.ClearNextStepForUser(ctx.Update, i.Client, false)
+
+	if ctx.Update.Message.Document != nil {
+		return i.ReceiveFile(ctx.Update)
+	}
+
+	i.Client.Request(tgbotapi.NewDeleteMessage(ctx.Update.Message.Chat.ID, ctx.Update.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(i.Repos.Settings, ctx.Update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = controllers.SendTracked(i.Client, i.Repos.BotMessages, tgbotapi.NewMessage(ctx.Update.Message.Chat.ID, i18n.T(settings.Language, IMPORT_HELP_TEXT)), ctx.Update.Message.From.ID)
+
+	return err
+}
+
+func (i Import) GetName() string {
+	return i.Name
+}
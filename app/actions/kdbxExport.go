@@ -0,0 +1,163 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"main/controllers"
+	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/kdbx"
+	"main/repository"
+	"main/telegram"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Через сколько секунд файл экспорта удаляется из чата
+const EXPORT_DOCUMENT_TTL = 300
+
+type KDBXExport struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+func handleKDBXPassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	ok, err := checkVaultPassword(client, repos, stepUpdate, stepParams)
+	if err != nil || !ok {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	return nextBackupStep(client, repos, stepUpdate, stepParams, fmt.Sprintf(
+		i18n.T(settings.Language, "Придумайте пароль для файла KeePass, не короче %d символов:"), MIN_BACKUP_PASSPHRASE_LENGTH,
+	), "Экспорт отменен", handleKDBXFilePassword)
+}
+
+func handleKDBXFilePassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	filePassword := stepUpdate.Message.Text
+	if utf8.RuneCountInString(filePassword) < MIN_BACKUP_PASSPHRASE_LENGTH {
+		return nextBackupStep(client, repos, stepUpdate, stepParams, fmt.Sprintf(
+			i18n.T(settings.Language, "Пароль должен быть не короче %d символов. Придумайте другой:"), MIN_BACKUP_PASSPHRASE_LENGTH,
+		), "Экспорт отменен", handleKDBXFilePassword)
+	}
+
+	stepParams["file_password"] = filePassword
+
+	return nextBackupStep(client, repos, stepUpdate, stepParams, "Повторите пароль для файла:", "Экспорт отменен", handleKDBXFilePasswordRepeat)
+}
+
+// buildKDBX расшифровывает секреты хранилища и записывает их в базу KeePass, защищенную паролем filePassword
+func buildKDBX(secrets []models.Secrets, password, filePassword, lang string) ([]byte, error) {
+	decrypted, err := decryptSecrets(secrets, password)
+	if err != nil {
+		return nil, err
+	}
+
+	db := kdbx.Database{Name: i18n.T(lang, "Хранилище")}
+	for _, secret := range decrypted {
+		db.Entries = append(db.Entries, kdbx.Entry{
+			Title:     secret.Title,
+			UserName:  secret.Login,
+			Password:  secret.Password,
+			URL:       secret.SiteLink,
+			Notes:     secret.Description,
+			CreatedAt: time.Unix(secret.CreatedAt, 0),
+			UpdatedAt: time.Unix(secret.UpdatedAt, 0),
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	err = kdbx.Write(buf, db, filePassword)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// sendExportDocument отправляет файл экспорта и ставит его в очередь на удаление из чата
+func sendExportDocument(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, name, caption string, data []byte) error {
+	document := tgbotapi.NewDocument(stepUpdate.Message.Chat.ID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	document.Caption = caption
+
+	sent, err := client.Send(document)
+	if err != nil {
+		return err
+	}
+
+	return controllers.ScheduleMessageExpiry(repos.ExpiringMessages, stepUpdate.Message.Chat.ID, sent.MessageID, stepUpdate.Message.From.ID, EXPORT_DOCUMENT_TTL, controllers.ExpireActionDelete)
+}
+
+func handleKDBXFilePasswordRepeat(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	if stepUpdate.Message.Text != stepParams["file_password"].(string) {
+		return finishBackupStep(client, repos, stepUpdate, "Пароли не совпадают. Экспорт отменен.")
+	}
+
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	isDuress := stepParams["duress"].(bool)
+
+	secrets, err := repos.Secrets.List(stepUpdate.Message.From.ID, isDuress, repository.SortOrderOld, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := buildKDBX(secrets, stepParams["password"].(string), stepParams["file_password"].(string), settings.Language)
+	if err != nil {
+		return err
+	}
+
+	err = sendExportDocument(client, repos, stepUpdate, fmt.Sprintf("vault-%s.kdbx", time.Now().Format("2006-01-02")), fmt.Sprintf(
+		i18n.T(settings.Language, "База KeePass: %d секретов. Откройте ее в KeePassXC, KeePass или другом совместимом менеджере.\n\nСохраните файл: через %d минут он будет удален из чата."),
+		len(secrets), EXPORT_DOCUMENT_TTL/60,
+	), data)
+	if err != nil {
+		return err
+	}
+
+	controllers.Audit(repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditExport,
+		UserID:    stepUpdate.Message.From.ID,
+		ChatID:    stepUpdate.Message.Chat.ID,
+		IsDuress:  isDuress,
+	})
+
+	return nil
+}
+
+func (e KDBXExport) Run(ctx *handlers.Context) error {
+	controllers.ClearNextStepForUser(ctx.Update, e.Client, false)
+
+	return askVaultPassword(e.Client, e.Repos, ctx.Update, "Экспорт в KeePass (KDBX 4).\n\nВведите мастер-пароль:", "Экспорт отменен", handleKDBXPassword)
+}
+
+func (e KDBXExport) GetName() string {
+	return e.Name
+}
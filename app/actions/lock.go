@@ -0,0 +1,77 @@
+package actions
+
+import (
+	"main/controllers"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const LOCKED_VAULT_TEXT = "Хранилище заблокировано.\n\nЧтобы продолжить работу, отправьте /start."
+
+type Lock struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// LockVault немедленно завершает сессию и убирает расшифрованные данные из чата
+func (l Lock) LockVault(update tgbotapi.Update) error {
+	message := util.GetMessage(update)
+
+	settings, err := controllers.GetUserSettings(l.Repos.Settings, message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := l.Repos.Sessions.DeleteByChat(message.From.ID, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		controllers.Audit(l.Repos.Audit, controllers.AuditEntry{
+			EventType: controllers.AuditLock,
+			UserID:    session.UserID,
+			ChatID:    session.ChatID,
+			IsDuress:  session.IsDuress,
+		})
+	}
+
+	controllers.ClearNextStepForUser(update, l.Client, false)
+	cancelChatRemasks(message.Chat.ID)
+
+	err = controllers.EditTrackedMessages(l.Client, l.Repos.BotMessages, message.Chat.ID, i18n.T(settings.Language, LOCKED_VAULT_TEXT))
+	if err != nil {
+		return err
+	}
+
+	return l.Repos.ExpiringMessages.DeleteByChat(message.Chat.ID)
+}
+
+func (l Lock) Run(ctx *handlers.Context) error {
+	update := ctx.Update
+
+	if update.Message != nil {
+		l.Client.Request(tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID))
+	} else if update.CallbackQuery != nil {
+		settings, err := controllers.GetUserSettings(l.Repos.Settings, update.CallbackQuery.From.ID)
+		if err != nil {
+			return err
+		}
+
+		l.Client.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, i18n.T(settings.Language, "Хранилище заблокировано")))
+		// Сообщение с кнопкой могло быть не запомнено, поэтому правим его отдельно
+		l.Client.Request(tgbotapi.NewEditMessageText(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID, i18n.T(settings.Language, LOCKED_VAULT_TEXT)))
+	}
+
+	return l.LockVault(update)
+}
+
+func (l Lock) GetName() string {
+	return l.Name
+}
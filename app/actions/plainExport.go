@@ -0,0 +1,132 @@
+package actions
+
+import (
+	"fmt"
+	"main/controllers"
+	"main/exporter"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const PLAIN_EXPORT_WARNING = "⚠️ ВНИМАНИЕ: файл будет содержать все логины и пароли в открытом виде, без шифрования.\n\nЛюбой, кто получит доступ к файлу, этому чату или устройству, куда файл будет сохранен, увидит все ваши секреты. Не пересылайте файл и удалите его сразу после использования. Не открывайте CSV в табличных редакторах: логин или пароль, начинающийся с =, +, - или @, может выполниться как формула. Для переноса в KeePass лучше использовать зашифрованный экспорт /kdbx."
+
+const PLAIN_EXPORT_HELP_TEXT = "Экспорт в открытом виде в формате Bitwarden.\n\nУкажите формат: /export csv или /export json."
+
+type PlainExport struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// buildPlainExport записывает расшифрованные секреты в CSV или JSON формата Bitwarden
+func buildPlainExport(decrypted []backupSecret, format string) ([]byte, error) {
+	entries := []exporter.Entry{}
+	for _, secret := range decrypted {
+		entries = append(entries, exporter.Entry{
+			Title:       secret.Title,
+			Login:       secret.Login,
+			Password:    secret.Password,
+			SiteLink:    secret.SiteLink,
+			Description: secret.Description,
+		})
+	}
+
+	if format == exporter.FormatJSON {
+		return exporter.BitwardenJSON(entries)
+	}
+
+	return exporter.BitwardenCSV(entries)
+}
+
+func handlePlainExportPassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	ok, err := checkVaultPassword(client, repos, stepUpdate, stepParams)
+	if err != nil || !ok {
+		return err
+	}
+
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	isDuress := stepParams["duress"].(bool)
+	format := stepParams["format"].(string)
+
+	secrets, err := repos.Secrets.List(stepUpdate.Message.From.ID, isDuress, repository.SortOrderOld, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	// Секреты расшифровываются только в памяти и сразу уходят в документ
+	decrypted, err := decryptSecrets(secrets, stepParams["password"].(string))
+	if err != nil {
+		return err
+	}
+
+	data, err := buildPlainExport(decrypted, format)
+	if err != nil {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	err = sendExportDocument(client, repos, stepUpdate, fmt.Sprintf("vault-export-%s.%s", time.Now().Format("2006-01-02"), format), fmt.Sprintf(
+		i18n.T(settings.Language, "Экспорт в открытом виде: %d секретов.\n\n⚠️ Все пароли в файле не зашифрованы. Через %d минут файл будет удален из чата, удалите и его копии после использования."),
+		len(secrets), EXPORT_DOCUMENT_TTL/60,
+	), data)
+	if err != nil {
+		return err
+	}
+
+	controllers.Audit(repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditPlainExport,
+		UserID:    stepUpdate.Message.From.ID,
+		ChatID:    stepUpdate.Message.Chat.ID,
+		IsDuress:  isDuress,
+	})
+
+	return nil
+}
+
+func (e PlainExport) Run(ctx *handlers.Context) error {
+	controllers.ClearNextStepForUser(ctx.Update, e.Client, false)
+
+	settings, err := controllers.GetUserSettings(e.Repos.Settings, ctx.Update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	format := strings.ToLower(strings.TrimSpace(ctx.Update.Message.CommandArguments()))
+	if format != exporter.FormatCSV && format != exporter.FormatJSON {
+		e.Client.Request(tgbotapi.NewDeleteMessage(ctx.Update.Message.Chat.ID, ctx.Update.Message.MessageID))
+
+		text := i18n.T(lang, PLAIN_EXPORT_HELP_TEXT) + "\n\n" + i18n.T(lang, PLAIN_EXPORT_WARNING)
+		_, err := controllers.SendTracked(e.Client, e.Repos.BotMessages, tgbotapi.NewMessage(ctx.Update.Message.Chat.ID, text), ctx.Update.Message.From.ID)
+
+		return err
+	}
+
+	text := i18n.T(lang, PLAIN_EXPORT_WARNING) + "\n\n" + i18n.T(lang, "Чтобы продолжить, введите мастер-пароль:")
+
+	return askVaultPassword(e.Client, e.Repos, ctx.Update, text, "Экспорт отменен",
+		func(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+			stepParams["format"] = format
+
+			return handlePlainExportPassword(client, repos, stepUpdate, stepParams)
+		},
+	)
+}
+
+func (e PlainExport) GetName() string {
+	return e.Name
+}
@@ -0,0 +1,78 @@
+package actions
+
+import (
+	"main/handlers"
+	"main/repository"
+	"main/telegram"
+)
+
+// CallbackRoutes возвращает маршруты кнопок бота. Код действия хранится в поле "a" данных кнопки.
+func CallbackRoutes(client telegram.Messenger, repos repository.Repos) []handlers.Route {
+	// Кнопкам хранилища нужна открытая сессия, без нее сообщение заменяется подсказкой
+	sessionRequired := handlers.RequireSession(repos.Sessions, SessionExpired(client, repos))
+
+	return []handlers.Route{
+		{
+			Actions:    []string{"n", "p", "c"}, // next, prev, current page
+			Callback:   MainPage{Name: "main-page-call-query", Client: client, Repos: repos},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+		{
+			Actions:    []string{"a"}, // add secret
+			Callback:   AddSecret{Name: "add-secret-call-query", Client: client, Repos: repos},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+		{
+			Actions:    []string{"s", "v"}, // show secret, reveal password
+			Callback:   ViewSecret{Name: "view-secret-call-query", Client: client, Repos: repos},
+			Codec:      handlers.JSONCodec[viewSecretCallbackData]{},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+		{
+			Actions:    []string{"d"}, // delete secret
+			Callback:   DeleteSecret{Name: "delete-secret-call-query", Client: client, Repos: repos},
+			Codec:      handlers.JSONCodec[viewSecretCallbackData]{},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+		{
+			Actions:    []string{"h", "y", "q"}, // share options, create link, revoke links
+			Callback:   Share{Name: "share-call-query", Client: client, Repos: repos},
+			Codec:      handlers.JSONCodec[shareCallbackData]{},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+		{
+			Actions:    []string{"t", "u"}, // two-factor status, disable
+			Callback:   TwoFactor{Name: "two-factor-call-query", Client: client, Repos: repos},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+		{
+			Actions:  []string{"l"}, // lock
+			Callback: Lock{Name: "lock-call-query", Client: client, Repos: repos},
+		},
+		{
+			Actions:    []string{"z"}, // duress options
+			Callback:   Duress{Name: "duress-call-query", Client: client, Repos: repos},
+			Middleware: []handlers.Middleware{handlers.LoadSession(repos.Sessions)},
+		},
+		{
+			Actions:    []string{"g"}, // settings
+			Callback:   Settings{Name: "settings-call-query", Client: client, Repos: repos},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+		{
+			Actions:    []string{"x"}, // extend session
+			Callback:   ExtendSession{Name: "extend-session-call-query", Client: client, Repos: repos},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+		{
+			Actions:    []string{"r"}, // active sessions
+			Callback:   ActiveSessions{Name: "active-sessions-call-query", Client: client, Repos: repos},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+		{
+			Actions:    []string{"j"}, // journal
+			Callback:   AuditLog{Name: "audit-log-call-query", Client: client, Repos: repos},
+			Middleware: []handlers.Middleware{sessionRequired},
+		},
+	}
+}
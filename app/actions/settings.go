@@ -0,0 +1,278 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/controllers"
+	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	MIN_REVEAL_TIMEOUT = 10
+	MAX_REVEAL_TIMEOUT = 3600
+)
+
+type Settings struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// SetRevealTimeout обрабатывает команду /autohide <секунды>
+func (s Settings) SetRevealTimeout(update tgbotapi.Update) error {
+	s.Client.Request(tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(s.Repos.Settings, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	argument := strings.TrimSpace(update.Message.CommandArguments())
+	if argument == "" {
+		text := fmt.Sprintf(i18n.T(settings.Language, "Сообщения с секретами скрываются через %d сек."), settings.RevealTimeout)
+		if settings.RevealTimeout == 0 {
+			text = i18n.T(settings.Language, "Автоматическое скрытие секретов отключено.")
+		}
+
+		text += fmt.Sprintf(i18n.T(settings.Language, "\n\nИзменить: /autohide <секунды от %d до %d> или /autohide 0, чтобы отключить."), MIN_REVEAL_TIMEOUT, MAX_REVEAL_TIMEOUT)
+		_, err = controllers.SendTracked(s.Client, s.Repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, text), update.Message.From.ID)
+
+		return err
+	}
+
+	timeout, err := strconv.ParseInt(argument, 10, 64)
+	if err != nil || (timeout != 0 && (timeout < MIN_REVEAL_TIMEOUT || timeout > MAX_REVEAL_TIMEOUT)) {
+		text := fmt.Sprintf(i18n.T(settings.Language, "Укажите число секунд от %d до %d или 0, чтобы отключить скрытие."), MIN_REVEAL_TIMEOUT, MAX_REVEAL_TIMEOUT)
+		_, err = controllers.SendTracked(s.Client, s.Repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, text), update.Message.From.ID)
+
+		return err
+	}
+
+	settings.RevealTimeout = timeout
+	err = controllers.SaveUserSettings(s.Repos.Settings, settings)
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf(i18n.T(settings.Language, "Готово! Сообщения с секретами будут скрываться через %d сек."), timeout)
+	if timeout == 0 {
+		text = i18n.T(settings.Language, "Готово! Автоматическое скрытие секретов отключено.")
+	}
+
+	_, err = controllers.SendTracked(s.Client, s.Repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, text), update.Message.From.ID)
+
+	return err
+}
+
+var displayModeTitles = map[string]string{
+	controllers.DisplayModeCode:    "моноширинный текст",
+	controllers.DisplayModeSpoiler: "под спойлером",
+	controllers.DisplayModeMasked:  "маска с кнопкой \"Показать пароль\"",
+}
+
+// SetDisplayMode обрабатывает команду /display <code|spoiler|masked>
+func (s Settings) SetDisplayMode(update tgbotapi.Update) error {
+	s.Client.Request(tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(s.Repos.Settings, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(update.Message.CommandArguments()))
+	if !slices.Contains(controllers.DisplayModes, mode) {
+		text := fmt.Sprintf(
+			i18n.T(settings.Language, "Сейчас пароли показываются так: %s.\n\nИзменить: /display %s"),
+			i18n.T(settings.Language, displayModeTitles[settings.DisplayMode]),
+			strings.Join(controllers.DisplayModes, "|"),
+		)
+		_, err = controllers.SendTracked(s.Client, s.Repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, text), update.Message.From.ID)
+
+		return err
+	}
+
+	settings.DisplayMode = mode
+	err = controllers.SaveUserSettings(s.Repos.Settings, settings)
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf(i18n.T(settings.Language, "Готово! Пароли будут показываться так: %s."), i18n.T(settings.Language, displayModeTitles[mode]))
+	_, err = controllers.SendTracked(s.Client, s.Repos.BotMessages, tgbotapi.NewMessage(update.Message.Chat.ID, text), update.Message.From.ID)
+
+	return err
+}
+
+var sortOrderTitles = map[string]string{
+	controllers.SortOrderOld:   "сначала старые",
+	controllers.SortOrderNew:   "сначала новые",
+	controllers.SortOrderTitle: "по названию",
+}
+
+// nextInCycle возвращает значение, следующее за current в списке values
+func nextInCycle[T comparable](values []T, current T) T {
+	return values[(slices.Index(values, current)+1)%len(values)]
+}
+
+// settingsMenu формирует меню настроек; каждая кнопка переключает параметр на следующее значение
+func settingsMenu(settings *models.UserSettings, sessionKey, pageOffset any) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	lang := settings.Language
+
+	revealTimeout := i18n.T(lang, "никогда")
+	if settings.RevealTimeout > 0 {
+		revealTimeout = fmt.Sprintf(i18n.T(lang, "через %d сек."), settings.RevealTimeout)
+	}
+
+	buttons := []struct {
+		field string
+		text  string
+	}{
+		{"t", fmt.Sprintf(i18n.T(lang, "Таймаут сессии: %s"), fmt.Sprintf(i18n.T(lang, "%d сек."), settings.SessionTimeout))},
+		{"p", fmt.Sprintf(i18n.T(lang, "Секретов на странице: %d"), settings.PageSize)},
+		{"s", fmt.Sprintf(i18n.T(lang, "Сортировка: %s"), i18n.T(lang, sortOrderTitles[settings.SortOrder]))},
+		{"l", fmt.Sprintf(i18n.T(lang, "Язык: %s"), i18n.LanguageTitles[settings.Language])},
+		{"d", fmt.Sprintf(i18n.T(lang, "Пароли: %s"), i18n.T(lang, displayModeTitles[settings.DisplayMode]))},
+		{"h", fmt.Sprintf(i18n.T(lang, "Скрывать секреты: %s"), revealTimeout)},
+	}
+
+	baseData := map[string]any{
+		"a": "g",        // action: settings
+		"k": sessionKey, // session_key
+		"o": pageOffset, // offest
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup()
+	for _, button := range buttons {
+		fieldData := map[string]any{"f": button.field} // field
+		maps.Copy(fieldData, baseData)
+
+		fieldDataJSON, err := json.Marshal(fieldData)
+		if err != nil {
+			return "", tgbotapi.InlineKeyboardMarkup{}, err
+		}
+
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{
+			{Text: button.text, CallbackData: util.StringPtr(string(fieldDataJSON))},
+		})
+	}
+
+	sessionsDataJSON, err := json.Marshal(map[string]any{
+		"a": "r", // action: active sessions
+		"k": sessionKey,
+		"o": pageOffset,
+	})
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	auditLogDataJSON, err := json.Marshal(map[string]any{
+		"a": "j", // action: journal
+		"k": sessionKey,
+		"o": pageOffset,
+	})
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{
+		{Text: i18n.T(lang, "Активные сессии"), CallbackData: util.StringPtr(string(sessionsDataJSON))},
+		{Text: i18n.T(lang, "Журнал"), CallbackData: util.StringPtr(string(auditLogDataJSON))},
+	})
+
+	backKeyboard, err := backToSecretsKeyboard(sessionKey, pageOffset, lang)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, backKeyboard.InlineKeyboard...)
+
+	return i18n.T(lang, "Настройки\n\nНажмите на параметр, чтобы изменить его:"), keyboard, nil
+}
+
+// UpdateMenu показывает меню настроек и переключает выбранный в нем параметр
+func (s Settings) UpdateMenu(ctx *handlers.Context) error {
+	update := ctx.Update
+	session := ctx.Session
+	data := ctx.CallbackData
+
+	controllers.ClearNextStepForUser(update, s.Client, true)
+	updateSession(s.Repos.Sessions, session)
+
+	settings, err := controllers.GetUserSettings(s.Repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+
+	field, _ := data["f"].(string)
+	switch field {
+	case "t":
+		settings.SessionTimeout = nextInCycle(controllers.SessionTimeouts, settings.SessionTimeout)
+
+		// Новый таймаут действует и на уже открытую сессию
+		session.ResetTimeInterval = settings.SessionTimeout
+		err = s.Repos.Sessions.Update(session, "reset_time_interval")
+		if err != nil {
+			return err
+		}
+	case "p":
+		settings.PageSize = nextInCycle(controllers.PageSizes, settings.PageSize)
+	case "s":
+		settings.SortOrder = nextInCycle(controllers.SortOrders, settings.SortOrder)
+	case "l":
+		settings.Language = nextInCycle(i18n.Languages, settings.Language)
+	case "d":
+		settings.DisplayMode = nextInCycle(controllers.DisplayModes, settings.DisplayMode)
+	case "h":
+		settings.RevealTimeout = nextInCycle(controllers.RevealTimeouts, settings.RevealTimeout)
+	}
+
+	if field != "" {
+		err = controllers.SaveUserSettings(s.Repos.Settings, settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	text, keyboard, err := settingsMenu(settings, data["k"], data["o"])
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Client.Request(tgbotapi.NewEditMessageTextAndMarkup(
+		update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID,
+		text,
+		keyboard,
+	))
+
+	return err
+}
+
+func (s Settings) Run(ctx *handlers.Context) error {
+	if ctx.Update.CallbackQuery != nil {
+		return s.UpdateMenu(ctx)
+	}
+
+	switch ctx.Update.Message.Command() {
+	case "autohide":
+		return s.SetRevealTimeout(ctx.Update)
+	case "display":
+		return s.SetDisplayMode(ctx.Update)
+	}
+
+	return nil
+}
+
+func (s Settings) GetName() string {
+	return s.Name
+}
@@ -0,0 +1,351 @@
+package actions
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/controllers"
+	"main/crypto"
+	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Через сколько секунд секрет, открытый по ссылке, удаляется из чата получателя
+const SHARE_MESSAGE_TTL = 300
+
+const SHARE_INVALID_TEXT = "Ссылка недействительна: ее уже открыли, отозвали или срок ее действия истек."
+
+var shareTTLTitles = map[int64]string{
+	3600:          "1 час",
+	24 * 3600:     "1 день",
+	7 * 24 * 3600: "7 дней",
+}
+
+type Share struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+type shareCallbackData struct {
+	Action     string `json:"a"`
+	SessionKey string `json:"k"`
+	Offset     int    `json:"o"`
+	SecretID   int    `json:"i"`
+	Views      int    `json:"w,omitempty"` // Индекс в controllers.ShareViewLimits
+	TTL        int    `json:"e,omitempty"` // Индекс в controllers.ShareTTLs
+}
+
+func (data shareCallbackData) withAction(action string) string {
+	data.Action = action
+	dataJSON, _ := json.Marshal(data)
+
+	return string(dataJSON)
+}
+
+// shareOptions формирует экран ссылок на секрет: параметры новой ссылки и отзыв уже выданных
+func (s Share) shareOptions(secret *models.Secrets, data shareCallbackData, lang string) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	shares, err := s.Repos.Shares.ListBySecret(secret.UserID, secret.IsDecoy, secret.ID, time.Now().Unix())
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	views := controllers.ShareViewLimits[data.Views]
+	ttl := controllers.ShareTTLs[data.TTL]
+
+	text := fmt.Sprintf(i18n.T(lang, "Поделиться секретом «%s»\n\nПолучатель увидит логин и пароль без доступа к хранилищу. Ссылка перестанет работать после последнего просмотра или когда истечет срок.\n\nАктивных ссылок: %d"), secret.Title, len(shares))
+
+	nextViews, nextTTL := data, data
+	nextViews.Views = (data.Views + 1) % len(controllers.ShareViewLimits)
+	nextTTL.TTL = (data.TTL + 1) % len(controllers.ShareTTLs)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		[]tgbotapi.InlineKeyboardButton{
+			{Text: fmt.Sprintf(i18n.T(lang, "Просмотров: %d"), views), CallbackData: util.StringPtr(nextViews.withAction("h"))},
+			{Text: fmt.Sprintf(i18n.T(lang, "Срок: %s"), i18n.T(lang, shareTTLTitles[ttl])), CallbackData: util.StringPtr(nextTTL.withAction("h"))},
+		},
+		[]tgbotapi.InlineKeyboardButton{
+			{Text: i18n.T(lang, "Создать ссылку"), CallbackData: util.StringPtr(data.withAction("y"))},
+		},
+	)
+
+	if len(shares) > 0 {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{
+			{Text: fmt.Sprintf(i18n.T(lang, "Отозвать ссылки (%d)"), len(shares)), CallbackData: util.StringPtr(data.withAction("q"))},
+		})
+	}
+
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{
+		{Text: i18n.T(lang, "Назад"), CallbackData: util.StringPtr(data.withAction("s"))},
+	})
+
+	return text, keyboard, nil
+}
+
+// createShare шифрует секрет новым случайным ключом и сохраняет ссылку. Возвращает токен, в котором лежит ключ.
+func (s Share) createShare(secret *models.Secrets, sessionPassword string, views int, ttl int64) (string, error) {
+	decrypted, err := decryptSecrets([]models.Secrets{*secret}, sessionPassword)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(decrypted[0])
+	if err != nil {
+		return "", err
+	}
+
+	token, key := controllers.NewShareToken()
+	encrypted, err := crypto.EncryptWithKey(payload, key)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.Repos.Shares.Create(&models.Shares{
+		UserID:    secret.UserID,
+		SecretID:  secret.ID,
+		IsDecoy:   secret.IsDecoy,
+		TokenHash: controllers.ShareTokenHash(token),
+		Payload:   base64.StdEncoding.EncodeToString(encrypted),
+		ViewsLeft: views,
+		ExpiresAt: time.Now().Unix() + ttl,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s Share) Run(ctx *handlers.Context) error {
+	update := ctx.Update
+	session := ctx.Session
+
+	if update.CallbackQuery == nil {
+		return errors.New("callback query is nil")
+	}
+
+	// Данные кнопки разбирает кодек маршрута
+	data := *ctx.Payload.(*shareCallbackData)
+	if data.Views < 0 || data.Views >= len(controllers.ShareViewLimits) {
+		data.Views = 0
+	}
+	if data.TTL < 0 || data.TTL >= len(controllers.ShareTTLs) {
+		data.TTL = 0
+	}
+
+	chatID := update.CallbackQuery.Message.Chat.ID
+	messageID := update.CallbackQuery.Message.MessageID
+
+	secret, err := s.Repos.Secrets.Get(update.CallbackQuery.From.ID, session.IsDuress, int64(data.SecretID))
+	if err != nil {
+		return fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	settings, err := controllers.GetUserSettings(s.Repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	// Сообщение больше не показывает пароль, скрывать нечего
+	cancelRemask(chatID, messageID)
+	err = controllers.CancelMessageExpiry(s.Repos.ExpiringMessages, chatID, messageID)
+	if err != nil {
+		return err
+	}
+
+	switch data.Action {
+	case "y":
+		sessionPassword, err := crypto.Decrypt(session.EncryptedPassword, data.SessionKey)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt session password: %w", err)
+		}
+
+		views := controllers.ShareViewLimits[data.Views]
+		ttl := controllers.ShareTTLs[data.TTL]
+
+		token, err := s.createShare(secret, sessionPassword, views, ttl)
+		if err != nil {
+			return err
+		}
+
+		me, err := s.Client.GetMe()
+		if err != nil {
+			return err
+		}
+		link := fmt.Sprintf("https://t.me/%s?start=%s%s", me.UserName, controllers.SharePrefix, token)
+
+		controllers.Audit(s.Repos.Audit, controllers.AuditEntry{
+			EventType: controllers.AuditShareCreate,
+			UserID:    update.CallbackQuery.From.ID,
+			ChatID:    chatID,
+			SecretID:  secret.ID,
+			IsDuress:  session.IsDuress,
+		})
+
+		text := fmt.Sprintf(
+			i18n.T(lang, "Ссылка на «%s» создана. Перешлите ее получателю:\n\n%s\n\nПросмотров: %d, действует до %s.\nСсылка показывается только сейчас: бот хранит не ее, а хеш."),
+			secret.Title, link, views, time.Now().Add(time.Duration(ttl)*time.Second).Format(SESSION_TIME_LAYOUT),
+		)
+
+		editMsg := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, tgbotapi.NewInlineKeyboardMarkup(
+			[]tgbotapi.InlineKeyboardButton{
+				{Text: i18n.T(lang, "Назад"), CallbackData: util.StringPtr(data.withAction("h"))},
+			},
+		))
+		editMsg.Entities = EntityMachine(text, []keywordObj{{Keyword: link, EntityName: "code"}})
+
+		_, err = s.Client.Send(editMsg)
+		if err != nil {
+			return err
+		}
+
+		// Ссылка открывает секрет, поэтому скрывается так же, как он сам
+		return scheduleSecretHiding(s.Repos.ExpiringMessages, update, settings)
+	case "q":
+		count, err := s.Repos.Shares.DeleteBySecret(update.CallbackQuery.From.ID, session.IsDuress, secret.ID)
+		if err != nil {
+			return err
+		}
+
+		controllers.Audit(s.Repos.Audit, controllers.AuditEntry{
+			EventType: controllers.AuditShareRevoke,
+			UserID:    update.CallbackQuery.From.ID,
+			ChatID:    chatID,
+			SecretID:  secret.ID,
+			IsDuress:  session.IsDuress,
+		})
+
+		s.Client.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, fmt.Sprintf(i18n.T(lang, "Отозвано ссылок: %d"), count)))
+	}
+
+	text, keyboard, err := s.shareOptions(secret, data, lang)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Client.Request(tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard))
+
+	return err
+}
+
+func (s Share) GetName() string {
+	return s.Name
+}
+
+// OpenShare показывает секрет по ссылке /start share_<токен>. Ссылку может открыть кто угодно, не только владелец бота.
+type OpenShare struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// open списывает просмотр ссылки и расшифровывает ее секрет. Неверный токен дает repository.ErrNotFound.
+func (o OpenShare) open(token string) (*models.Shares, *backupSecret, error) {
+	key, err := controllers.ShareKey(token)
+	if err != nil {
+		return nil, nil, repository.ErrNotFound
+	}
+
+	share, err := o.Repos.Shares.Consume(controllers.ShareTokenHash(token), time.Now().Unix())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(share.Payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := crypto.DecryptWithKey(encrypted, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secret := &backupSecret{}
+	err = json.Unmarshal(payload, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return share, secret, nil
+}
+
+func (o OpenShare) Run(ctx *handlers.Context) error {
+	update := ctx.Update
+	chatID := update.Message.Chat.ID
+
+	// Токен в истории чата позволил бы открыть ссылку еще раз
+	o.Client.Request(tgbotapi.NewDeleteMessage(chatID, update.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(o.Repos.Settings, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	token := strings.TrimPrefix(strings.TrimSpace(update.Message.CommandArguments()), controllers.SharePrefix)
+
+	share, secret, err := o.open(token)
+	if errors.Is(err, repository.ErrNotFound) {
+		_, err = o.Client.Send(tgbotapi.NewMessage(chatID, i18n.T(lang, SHARE_INVALID_TEXT)))
+
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	// Кнопки "Показать пароль" у получателя нет, поэтому маска заменяется обычным видом
+	displayMode := settings.DisplayMode
+	if displayMode == controllers.DisplayModeMasked {
+		displayMode = controllers.DisplayModeCode
+	}
+
+	text, entities := ViewSecret{}.formatSecretMessage(&models.Secrets{
+		Title:       secret.Title,
+		Login:       secret.Login,
+		Password:    secret.Password,
+		SiteLink:    secret.SiteLink,
+		Description: secret.Description,
+	}, displayMode, lang)
+
+	if share.ViewsLeft > 0 {
+		text += fmt.Sprintf(i18n.T(lang, "\n\nОсталось просмотров по ссылке: %d."), share.ViewsLeft)
+	} else {
+		text += i18n.T(lang, "\n\nЭто был последний просмотр, ссылка больше не работает.")
+	}
+	text += fmt.Sprintf(i18n.T(lang, "\nСообщение будет удалено через %d минут, сохраните данные."), SHARE_MESSAGE_TTL/60)
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.Entities = entities
+
+	sent, err := o.Client.Send(msg)
+	if err != nil {
+		return err
+	}
+
+	// Событие попадает в журнал владельца, чат - получателя
+	controllers.Audit(o.Repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditShareView,
+		UserID:    share.UserID,
+		ChatID:    chatID,
+		SecretID:  share.SecretID,
+		IsDuress:  share.IsDecoy,
+	})
+
+	return controllers.ScheduleMessageExpiry(o.Repos.ExpiringMessages, chatID, sent.MessageID, update.Message.From.ID, SHARE_MESSAGE_TTL, controllers.ExpireActionDelete)
+}
+
+func (o OpenShare) GetName() string {
+	return o.Name
+}
@@ -3,10 +3,14 @@ package actions
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"main/controllers"
 	"main/crypto"
-	"main/database"
 	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
 	"main/util"
 	"maps"
 	"math"
@@ -15,20 +19,21 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-const (
-	BUTTONS_PER_PAGE            = 6
-	SESSION_RESET_TIME_INTERVAL = 1000 // 600
-)
-
 type MainPage struct {
 	Name   string
-	Client tgbotapi.BotAPI
+	Client telegram.Messenger
+	Repos  repository.Repos
 }
 
 func (m MainPage) AskPassword(update tgbotapi.Update) error {
+	settings, err := controllers.GetUserSettings(m.Repos.Settings, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
 	m.Client.Request(tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID))
-	response := tgbotapi.NewMessage(update.Message.Chat.ID, "Введите пароль или отправьте реплай на сообщение, текст которого содержит пароль:")
-	_, err := m.Client.Send(response)
+	response := tgbotapi.NewMessage(update.Message.Chat.ID, i18n.T(settings.Language, "Введите пароль или отправьте реплай на сообщение, текст которого содержит пароль:"))
+	_, err = controllers.SendTracked(m.Client, m.Repos.BotMessages, response, update.Message.From.ID)
 	if err != nil {
 		return err
 	}
@@ -38,7 +43,7 @@ func (m MainPage) AskPassword(update tgbotapi.Update) error {
 		UserID: update.Message.From.ID,
 	}
 	stepAction := controllers.NextStepAction{
-		Func:        HandlePassword,
+		Func:        withRepos(m.Repos, HandlePassword),
 		Params:      make(map[string]any),
 		CreatedAtTS: time.Now().Unix(),
 	}
@@ -48,15 +53,24 @@ func (m MainPage) AskPassword(update tgbotapi.Update) error {
 	return nil
 }
 
-func HandlePassword(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+func HandlePassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
 	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
 	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
 
-	var userDb models.Users
-	err := database.GetDB().Model(&userDb).Where("telegram_id = ?", stepUpdate.Message.From.ID).Select()
+	blocked, err := loginBlocked(client, repos, stepUpdate)
+	if err != nil || blocked {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
 	if err != nil {
-		response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, "Тебе тут не место.\n\nGo away.")
-		_, err = client.Send(response)
+		return err
+	}
+
+	userDb, err := repos.Users.GetByTelegramID(stepUpdate.Message.From.ID)
+	if err != nil {
+		response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(settings.Language, "Тебе тут не место.\n\nGo away."))
+		_, err = controllers.SendTracked(client, repos.BotMessages, response, stepUpdate.Message.From.ID)
 		if err != nil {
 			return err
 		}
@@ -71,47 +85,165 @@ func HandlePassword(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepPara
 		stepParams["password"] = stepUpdate.Message.Text
 	}
 
-	if crypto.HashString(stepParams["password"].(string)) != userDb.PasswordHash {
-		response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, "Неверный пароль.\n\nGo away.")
-		_, err = client.Send(response)
-		if err != nil {
-			return err
-		}
+	isDuress := isDuressPassword(userDb, stepParams["password"].(string))
+	if !isDuress && !isRealPassword(userDb, stepParams["password"].(string)) {
+		return rejectPassword(client, repos, stepUpdate, userDb, stepParams["password"].(string), i18n.T(settings.Language, "Неверный пароль."))
+	}
+
+	stepParams["duress"] = isDuress
+
+	if userDb.TOTPEnabled {
+		return askTOTPCode(client, repos, stepUpdate, stepParams)
+	}
+
+	return openSession(client, repos, stepUpdate, stepParams["password"].(string), isDuress)
+}
+
+// loginBlocked сообщает пользователю о временной блокировке входа, если она действует
+func loginBlocked(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update) (bool, error) {
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if !controllers.PasswordLimiter.Allow(stepUpdate.Message.From.ID) {
+		response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(settings.Language, "Слишком частые попытки. Подождите несколько секунд и попробуйте снова."))
+		_, err = controllers.SendTracked(client, repos.BotMessages, response, stepUpdate.Message.From.ID)
+
+		return true, err
+	}
+
+	wait, err := controllers.LoginWaitTime(repos.LoginAttempts, stepUpdate.Message.From.ID)
+	if err != nil || wait == 0 {
+		return false, err
+	}
+
+	response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, fmt.Sprintf(i18n.T(settings.Language, "Слишком много неудачных попыток входа.\n\nПовторите через %s."), wait))
+	_, err = controllers.SendTracked(client, repos.BotMessages, response, stepUpdate.Message.From.ID)
+
+	return true, err
+}
+
+// rejectLogin учитывает неудачную попытку входа и уведомляет администратора о блокировке
+func rejectLogin(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, reason string) error {
+	wait, locked, err := controllers.RegisterFailedLogin(repos.LoginAttempts, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	controllers.Audit(repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditLoginFailed,
+		UserID:    stepUpdate.Message.From.ID,
+		ChatID:    stepUpdate.Message.Chat.ID,
+	})
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf(i18n.T(settings.Language, "%s\n\nGo away."), reason)
+	if wait >= time.Minute {
+		text = fmt.Sprintf(i18n.T(settings.Language, "%s\n\nВход заблокирован на %s."), reason, wait)
+	}
 
+	_, err = controllers.SendTracked(client, repos.BotMessages, tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, text), stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	if !locked {
 		return nil
 	}
 
+	adminID, err := util.GetAdminID()
+	if err != nil {
+		return err
+	}
+
+	adminSettings, err := controllers.GetUserSettings(repos.Settings, adminID)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Send(tgbotapi.NewMessage(adminID, fmt.Sprintf(
+		i18n.T(adminSettings.Language, "Внимание! Вход для пользователя %d (@%s) заблокирован на %s из-за неудачных попыток ввода пароля."),
+		stepUpdate.Message.From.ID,
+		stepUpdate.Message.From.UserName,
+		wait,
+	)))
+
+	return err
+}
+
+// openSession создает сессию после успешной проверки всех факторов и показывает главную страницу
+func openSession(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, password string, isDuress bool) error {
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	err := controllers.ResetLoginAttempts(repos.LoginAttempts, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
 	sessionKey := crypto.GenerateRandomString(8)
-	encryptedPassword, err := crypto.Encrypt(stepParams["password"].(string), sessionKey)
+	encryptedPassword, err := crypto.Encrypt(password, sessionKey)
 	if err != nil {
 		return err
 	}
 
 	newSession := &models.Sessions{
 		UserID:            stepUpdate.Message.From.ID,
+		Source:            models.SessionSourceTelegram,
+		ChatID:            stepUpdate.Message.Chat.ID,
 		EncryptedPassword: encryptedPassword,
-		ResetTimeInterval: SESSION_RESET_TIME_INTERVAL,
+		ResetTimeInterval: settings.SessionTimeout,
+		IsDuress:          isDuress,
 	}
 
-	_, err = database.GetDB().Model(newSession).Insert()
+	// В чате может быть только одна сессия, старая заменяется новой
+	_, err = repos.Sessions.DeleteByChat(newSession.UserID, newSession.ChatID)
 	if err != nil {
 		return err
 	}
 
-	return MainPage{Name: "main-page-from-step-func", Client: client}.MainPage(stepUpdate, newSession, sessionKey, false)
-}
-
-func updateSession(session *models.Sessions) error {
-	session.UpdatedAt = time.Now().Unix()
-	_, err := database.GetDB().Model(session).WherePK().Update()
+	err = repos.Sessions.Create(newSession)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	controllers.Audit(repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditLogin,
+		UserID:    stepUpdate.Message.From.ID,
+		ChatID:    stepUpdate.Message.Chat.ID,
+		IsDuress:  isDuress,
+	})
+
+	if isDuress {
+		err = applyDuressAction(client, repos, stepUpdate)
+		if err != nil {
+			log.Printf("Failed to apply duress action: %v", err)
+		}
+	}
+
+	return MainPage{Name: "main-page-from-step-func", Client: client, Repos: repos}.MainPage(stepUpdate, newSession, sessionKey, false)
+}
+
+func updateSession(sessions repository.SessionRepo, session *models.Sessions) error {
+	session.UpdatedAt = time.Now().Unix()
+	session.WarningMessageID = 0 // Сессия снова активна, о следующем окончании нужно предупредить заново
+
+	return sessions.Update(session)
 }
 
-func getCallbackParams(update tgbotapi.Update, offest *int, sessionKey *string, updateFromID *int64) error {
+func getCallbackParams(update tgbotapi.Update, pageSize int, offest *int, sessionKey *string, updateFromID *int64) error {
 	var data map[string]any
 	err := json.Unmarshal([]byte(update.CallbackQuery.Data), &data)
 	if err != nil {
@@ -124,54 +256,56 @@ func getCallbackParams(update tgbotapi.Update, offest *int, sessionKey *string,
 
 	switch data["a"].(string) {
 	case "n": // next
-		*offest += BUTTONS_PER_PAGE
+		*offest += pageSize
 	case "p": // prev
-		*offest -= BUTTONS_PER_PAGE
+		*offest -= pageSize
 	}
 
 	return nil
 }
 
-func getPageNoAndCount(offest int, updateFromID int64) (int, int, error) {
+func getPageNoAndCount(secretRepo repository.SecretRepo, offest int, updateFromID int64, isDecoy bool, pageSize int) (int, int, error) {
 	var pageNo, pageCount int
-	secretsCount, err := database.GetDB().Model(&models.Secrets{}).Where("user_id = ?", updateFromID).Count()
+	secretsCount, err := secretRepo.Count(updateFromID, isDecoy)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	pageCount = int(math.Ceil(float64(secretsCount) / float64(BUTTONS_PER_PAGE)))
+	pageCount = int(math.Ceil(float64(secretsCount) / float64(pageSize)))
 	if pageCount == 0 {
 		pageNo = 0
 	} else {
 		// Ensure offset is within bounds before calculating page number
-		totalItems := pageCount * BUTTONS_PER_PAGE
+		totalItems := pageCount * pageSize
 		if offest >= totalItems {
 			offest = 0
 		} else if offest < 0 {
-			offest = totalItems - BUTTONS_PER_PAGE
+			offest = totalItems - pageSize
 			if offest < 0 {
 				offest = 0
 			}
 		}
-		pageNo = (offest / BUTTONS_PER_PAGE) + 1
+		pageNo = (offest / pageSize) + 1
 	}
 
 	return pageNo, pageCount, nil
 }
 
-func getPageText(pageNo, pageCount int) string {
-	return fmt.Sprintf("Менеджер паролей Крови Весны\nСтраница: %d // %d\n\nВыберите сервис для просмотра пароля:", pageNo, pageCount)
+func getPageText(pageNo, pageCount int, lang string) string {
+	return fmt.Sprintf(i18n.T(lang, "Менеджер паролей Крови Весны\nСтраница: %d // %d\n\nВыберите сервис для просмотра пароля:"), pageNo, pageCount)
 }
 
-func getKeyboard(pageCount, offest int, updateFromID int64, sessionKey string) (tgbotapi.InlineKeyboardMarkup, error) {
-	totalItems := pageCount * BUTTONS_PER_PAGE
+func getKeyboard(secretRepo repository.SecretRepo, pageCount, offest int, updateFromID int64, sessionKey string, isDecoy bool, settings *models.UserSettings) (tgbotapi.InlineKeyboardMarkup, error) {
+	pageSize := settings.PageSize
+	lang := settings.Language
+	totalItems := pageCount * pageSize
 
 	if totalItems > 0 {
 		// Ensure offset stays within bounds
 		if offest >= totalItems {
 			offest = 0
 		} else if offest < 0 {
-			offest = totalItems - BUTTONS_PER_PAGE
+			offest = totalItems - pageSize
 			if offest < 0 {
 				offest = 0
 			}
@@ -180,8 +314,7 @@ func getKeyboard(pageCount, offest int, updateFromID int64, sessionKey string) (
 		offest = 0
 	}
 
-	secrets := []*models.Secrets{}
-	err := database.GetDB().Model(&secrets).Where("user_id = ?", updateFromID).Offset(offest).Limit(BUTTONS_PER_PAGE).Select()
+	secrets, err := secretRepo.List(updateFromID, isDecoy, settings.SortOrder, offest, pageSize)
 	if err != nil {
 		return tgbotapi.InlineKeyboardMarkup{}, err
 	}
@@ -251,29 +384,70 @@ func getKeyboard(pageCount, offest int, updateFromID int64, sessionKey string) (
 	navigationBarRow := []tgbotapi.InlineKeyboardButton{}
 
 	if pageCount > 1 {
-		navigationBarRow = append(navigationBarRow, tgbotapi.InlineKeyboardButton{Text: "Назад", CallbackData: util.StringPtr(string(prevDataJSON))})
+		navigationBarRow = append(navigationBarRow, tgbotapi.InlineKeyboardButton{Text: i18n.T(lang, "Назад"), CallbackData: util.StringPtr(string(prevDataJSON))})
 	}
 
 	navigationBarRow = append(navigationBarRow, tgbotapi.InlineKeyboardButton{Text: "+", CallbackData: util.StringPtr(string(addDataJSON))})
 
 	if pageCount > 1 {
-		navigationBarRow = append(navigationBarRow, tgbotapi.InlineKeyboardButton{Text: "Вперед", CallbackData: util.StringPtr(string(nextDataJSON))})
+		navigationBarRow = append(navigationBarRow, tgbotapi.InlineKeyboardButton{Text: i18n.T(lang, "Вперед"), CallbackData: util.StringPtr(string(nextDataJSON))})
 	}
 
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, [][]tgbotapi.InlineKeyboardButton{navigationBarRow}...)
 
+	var (
+		twoFactorData = map[string]any{"a": "t"} // action: two-factor
+		settingsData  = map[string]any{"a": "g"} // action: settings
+		lockData      = map[string]any{"a": "l"} // action: lock
+	)
+
+	maps.Copy(twoFactorData, baseData)
+	maps.Copy(settingsData, baseData)
+
+	twoFactorDataJSON, err := json.Marshal(twoFactorData)
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	settingsDataJSON, err := json.Marshal(settingsData)
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	lockDataJSON, err := json.Marshal(lockData)
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	// Настройки 2FA общие для обоих хранилищ, поэтому в хранилище-приманке их нет:
+	// изменения там пришлось бы изображать, а коды восстановления - настоящие
+	menuRow := []tgbotapi.InlineKeyboardButton{}
+	if !isDecoy {
+		menuRow = append(menuRow, tgbotapi.InlineKeyboardButton{Text: "2FA", CallbackData: util.StringPtr(string(twoFactorDataJSON))})
+	}
+	menuRow = append(menuRow,
+		tgbotapi.InlineKeyboardButton{Text: i18n.T(lang, "Настройки"), CallbackData: util.StringPtr(string(settingsDataJSON))},
+		tgbotapi.InlineKeyboardButton{Text: i18n.T(lang, "Заблокировать"), CallbackData: util.StringPtr(string(lockDataJSON))},
+	)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, menuRow)
+
 	return keyboard, nil
 }
 
 func (m MainPage) MainPage(update tgbotapi.Update, session *models.Sessions, newSessionKey string, isCallback bool) error {
-	updateSession(session)
+	updateSession(m.Repos.Sessions, session)
 
 	var offest int
 	var sessionKey string
 	var updateFromID int64
 
+	settings, err := controllers.GetUserSettings(m.Repos.Settings, session.UserID)
+	if err != nil {
+		return err
+	}
+
 	if isCallback {
-		err := getCallbackParams(update, &offest, &sessionKey, &updateFromID)
+		err := getCallbackParams(update, settings.PageSize, &offest, &sessionKey, &updateFromID)
 		if err != nil {
 			return err
 		}
@@ -283,13 +457,13 @@ func (m MainPage) MainPage(update tgbotapi.Update, session *models.Sessions, new
 		updateFromID = update.Message.From.ID
 	}
 
-	pageNo, pageCount, err := getPageNoAndCount(offest, updateFromID)
+	pageNo, pageCount, err := getPageNoAndCount(m.Repos.Secrets, offest, updateFromID, session.IsDuress, settings.PageSize)
 	if err != nil {
 		return err
 	}
-	text := getPageText(pageNo, pageCount)
+	text := getPageText(pageNo, pageCount, settings.Language)
 
-	keyboard, err := getKeyboard(pageCount, offest, updateFromID, sessionKey)
+	keyboard, err := getKeyboard(m.Repos.Secrets, pageCount, offest, updateFromID, sessionKey, session.IsDuress, settings)
 	if err != nil {
 		return err
 	}
@@ -298,30 +472,33 @@ func (m MainPage) MainPage(update tgbotapi.Update, session *models.Sessions, new
 		response := tgbotapi.NewMessage(updateFromID, text)
 		response.ReplyMarkup = keyboard
 
-		_, err = m.Client.Send(response)
+		_, err = controllers.SendTracked(m.Client, m.Repos.BotMessages, response, updateFromID)
 	} else {
 		response := tgbotapi.NewEditMessageText(updateFromID, update.CallbackQuery.Message.MessageID, text)
 		response.ReplyMarkup = &keyboard
 
 		_, err = m.Client.Request(response)
+		if err != nil {
+			return err
+		}
+
+		// В сообщении больше нет расшифрованных данных, скрывать его не нужно
+		cancelRemask(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID)
+		err = controllers.CancelMessageExpiry(m.Repos.ExpiringMessages, update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID)
 	}
 
 	return err
 }
 
-func (m MainPage) main(update tgbotapi.Update) error {
-	if update.CallbackQuery != nil {
-		session, err := util.GetSession(update)
-
-		if err != nil {
-			m.Client.Send(tgbotapi.NewDeleteMessage(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID))
+func (m MainPage) main(ctx *handlers.Context) error {
+	update := ctx.Update
 
-			return nil
-		}
-
-		return m.MainPage(update, &session, "", true)
+	if update.CallbackQuery != nil {
+		// Сессию проверяет RequireSession
+		return m.MainPage(update, ctx.Session, "", true)
 	} else if update.Message != nil {
-		database.GetDB().Model(&models.Sessions{}).Where("user_id = ?", update.Message.From.ID).Delete()
+		// Сессии в других чатах остаются, их можно завершить со страницы активных сессий
+		m.Repos.Sessions.DeleteByChat(update.Message.From.ID, update.Message.Chat.ID)
 
 		return m.AskPassword(update)
 	}
@@ -329,8 +506,8 @@ func (m MainPage) main(update tgbotapi.Update) error {
 	return nil
 }
 
-func (m MainPage) Run(update tgbotapi.Update) error {
-	err := m.main(update)
+func (m MainPage) Run(ctx *handlers.Context) error {
+	err := m.main(ctx)
 
 	return err
 }
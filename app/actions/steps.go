@@ -0,0 +1,19 @@
+package actions
+
+import (
+	"main/controllers"
+	"main/repository"
+	"main/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// repoStepFunc - шаг диалога, которому нужны хранилища
+type repoStepFunc func(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error
+
+// withRepos превращает шаг с хранилищами в обычный шаг диалога
+func withRepos(repos repository.Repos, step repoStepFunc) controllers.NextStepFunc {
+	return func(client telegram.Messenger, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+		return step(client, repos, stepUpdate, stepParams)
+	}
+}
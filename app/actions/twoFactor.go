@@ -0,0 +1,415 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/controllers"
+	"main/crypto"
+	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	TOTP_ISSUER          = "PasswordHolder"
+	RECOVERY_CODES_COUNT = 8
+)
+
+type TwoFactor struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+// checkSecondFactor проверяет TOTP код или код восстановления.
+// Использованный код восстановления сразу удаляется из списка пользователя.
+func checkSecondFactor(users repository.UserRepo, user *models.Users, password, code string) (bool, error) {
+	encryptedSecret := user.TOTPSecret
+	if isDuressPassword(user, password) {
+		encryptedSecret = user.DuressTOTPSecret
+	}
+
+	if encryptedSecret == "" {
+		return false, nil
+	}
+
+	totpSecret, err := crypto.Decrypt(encryptedSecret, password)
+	if err != nil {
+		return false, err
+	}
+
+	if crypto.ValidateTOTP(totpSecret, code, time.Now()) {
+		return true, nil
+	}
+
+	index := slices.IndexFunc(user.RecoveryCodes, func(hash string) bool {
+		return crypto.CheckRecoveryCode(code, hash)
+	})
+	if index == -1 {
+		return false, nil
+	}
+
+	user.RecoveryCodes = slices.Delete(user.RecoveryCodes, index, index+1)
+	user.UpdatedAt = time.Now().Unix()
+	err = users.Update(user, "recovery_codes", "updated_at")
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// askTOTPCode запрашивает второй фактор после верного мастер-пароля
+func askTOTPCode(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(settings.Language, "Введите код из приложения-аутентификатора или код восстановления:"))
+	_, err = controllers.SendTracked(client, repos.BotMessages, response, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	stepKey := controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}
+	stepAction := controllers.NextStepAction{
+		Func:        withRepos(repos, HandleTOTPCode),
+		Params:      stepParams,
+		CreatedAtTS: time.Now().Unix(),
+	}
+
+	controllers.GetNextStepManager().RegisterNextStepAction(stepKey, stepAction)
+
+	return nil
+}
+
+func HandleTOTPCode(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	blocked, err := loginBlocked(client, repos, stepUpdate)
+	if err != nil || blocked {
+		return err
+	}
+
+	user, err := repos.Users.GetByTelegramID(stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	password := stepParams["password"].(string)
+	ok, err := checkSecondFactor(repos.Users, user, password, stepUpdate.Message.Text)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+			ChatID: stepUpdate.Message.Chat.ID,
+			UserID: stepUpdate.Message.From.ID,
+		}, client, false)
+
+		settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+		if err != nil {
+			return err
+		}
+
+		return rejectLogin(client, repos, stepUpdate, i18n.T(settings.Language, "Неверный код."))
+	}
+
+	return openSession(client, repos, stepUpdate, password, stepParams["duress"].(bool))
+}
+
+// backToSecretsKeyboard возвращает клавиатуру с единственной кнопкой возврата к списку секретов
+func backToSecretsKeyboard(sessionKey, pageOffset any, lang string) (tgbotapi.InlineKeyboardMarkup, error) {
+	callbackData := map[string]any{
+		"k": sessionKey,
+		"o": pageOffset,
+		"a": "c",
+	}
+	callbackDataJSON, err := json.Marshal(callbackData)
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	return tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{
+			{Text: i18n.T(lang, "К секретам"), CallbackData: util.StringPtr(string(callbackDataJSON))},
+		}},
+	}, nil
+}
+
+func (t TwoFactor) getStepParams(update tgbotapi.Update, callbackDataParams map[string]any) (map[string]any, error) {
+	stepParams := make(map[string]any)
+	stepParams["session_key"] = callbackDataParams["k"]
+	stepParams["page_offest"] = callbackDataParams["o"]
+	stepParams["client"] = t.Client
+	stepParams["update"] = update
+
+	cancelParams := make(map[string]any)
+	maps.Copy(cancelParams, callbackDataParams)
+	cancelParams["a"] = "c"
+
+	cancelParamsJSON, err := json.Marshal(cancelParams)
+	if err != nil {
+		return nil, err
+	}
+
+	stepParams["on_cancel"] = string(cancelParamsJSON)
+
+	return stepParams, nil
+}
+
+// StartEnrollment выдает новый TOTP секрет и ждет первый код для подтверждения
+func (t TwoFactor) StartEnrollment(update tgbotapi.Update, stepParams map[string]any) error {
+	secret := crypto.GenerateTOTPSecret()
+	stepParams["totp_secret"] = secret
+
+	account := update.CallbackQuery.From.UserName
+	if account == "" {
+		account = strconv.FormatInt(update.CallbackQuery.From.ID, 10)
+	}
+
+	settings, err := controllers.GetUserSettings(t.Repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+
+	formText := fmt.Sprintf(
+		i18n.T(settings.Language, "Добавьте ключ в приложение-аутентификатор по ссылке:\n\n%s\n\nИли введите ключ вручную: %s\n\nЗатем отправьте 6-значный код из приложения:"),
+		crypto.TOTPURI(TOTP_ISSUER, account, secret),
+		secret,
+	)
+
+	return baseForm(t.Client, t.Repos, update, stepParams, formText, "Подключение 2FA отменено", withRepos(t.Repos, confirmTOTPEnrollment), stepParams["on_cancel"].(string), false)
+}
+
+func confirmTOTPEnrollment(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	stepParams["update"] = stepUpdate
+	if finishPollWithoutSession(client, repos.Sessions, stepUpdate) {
+		return nil
+	}
+
+	secret := stepParams["totp_secret"].(string)
+	if !crypto.ValidateTOTP(secret, stepUpdate.Message.Text, time.Now()) {
+		return baseForm(client, repos, stepUpdate, stepParams, "Неверный код. Отправьте код из приложения еще раз:", "Подключение 2FA отменено", withRepos(repos, confirmTOTPEnrollment), stepParams["on_cancel"].(string), false)
+	}
+
+	encryptedSecret, err := encryptDataWithSessionPassword(repos.Sessions, stepParams, secret)
+	if err != nil {
+		return err
+	}
+
+	user, err := repos.Users.GetByTelegramID(stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	recoveryCodes := crypto.GenerateRecoveryCodes(RECOVERY_CODES_COUNT)
+	resultText := i18n.T(lang, "Двухфакторная аутентификация включена!")
+
+	user.RecoveryCodes = make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		user.RecoveryCodes[i] = crypto.HashRecoveryCode(code)
+	}
+	user.TOTPSecret = encryptedSecret
+	user.TOTPEnabled = true
+	user.UpdatedAt = time.Now().Unix()
+
+	// Копию секрета для пароля под принуждением зашифровать нечем, поэтому его нужно задать заново
+	if user.DuressPasswordHash != "" {
+		user.DuressPasswordHash = ""
+		user.DuressTOTPSecret = ""
+		resultText += i18n.T(lang, "\n\nПароль под принуждением сброшен, задайте его заново командой /duress.")
+	}
+
+	err = repos.Users.Update(user, "totp_secret", "totp_enabled", "recovery_codes", "duress_password_hash", "duress_totp_secret", "updated_at")
+	if err != nil {
+		return err
+	}
+
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	keyboard, err := backToSecretsKeyboard(stepParams["session_key"], stepParams["page_offest"], lang)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, fmt.Sprintf(
+		i18n.T(lang, "%s\n\nОдноразовые коды восстановления (сохраните их в надежном месте, больше они показаны не будут):\n\n%s"),
+		resultText,
+		strings.Join(recoveryCodes, "\n"),
+	))
+	response.ReplyMarkup = keyboard
+
+	_, err = controllers.SendTracked(client, repos.BotMessages, response, stepUpdate.Message.From.ID)
+
+	return err
+}
+
+// ShowStatus показывает состояние 2FA и предлагает отключить ее
+func (t TwoFactor) ShowStatus(update tgbotapi.Update, user *models.Users, stepParams map[string]any) error {
+	settings, err := controllers.GetUserSettings(t.Repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	disableData := map[string]any{
+		"a": "u", // action: disable two-factor
+		"k": stepParams["session_key"],
+		"o": stepParams["page_offest"],
+	}
+	disableDataJSON, err := json.Marshal(disableData)
+	if err != nil {
+		return err
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "Назад"), stepParams["on_cancel"].(string)),
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "Отключить"), string(disableDataJSON)),
+		),
+	)
+
+	response := tgbotapi.NewEditMessageTextAndMarkup(
+		update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID,
+		fmt.Sprintf(i18n.T(lang, "Двухфакторная аутентификация включена.\nОсталось кодов восстановления: %d"), len(user.RecoveryCodes)),
+		keyboard,
+	)
+
+	_, err = t.Client.Request(response)
+
+	return err
+}
+
+func disableTOTP(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	stepParams["update"] = stepUpdate
+	if finishPollWithoutSession(client, repos.Sessions, stepUpdate) {
+		return nil
+	}
+
+	user, err := repos.Users.GetByTelegramID(stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	session, err := util.GetSession(repos.Sessions, stepUpdate)
+	if err != nil {
+		return err
+	}
+
+	password, err := crypto.Decrypt(session.EncryptedPassword, stepParams["session_key"].(string))
+	if err != nil {
+		return err
+	}
+
+	ok, err := checkSecondFactor(repos.Users, user, password, stepUpdate.Message.Text)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return baseForm(client, repos, stepUpdate, stepParams, "Неверный код. Отправьте код из приложения или код восстановления еще раз:", "Отключение 2FA отменено", withRepos(repos, disableTOTP), stepParams["on_cancel"].(string), false)
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.DuressTOTPSecret = ""
+	user.RecoveryCodes = nil
+	user.UpdatedAt = time.Now().Unix()
+
+	err = repos.Users.Update(user, "totp_secret", "totp_enabled", "recovery_codes", "duress_totp_secret", "updated_at")
+	if err != nil {
+		return err
+	}
+
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	keyboard, err := backToSecretsKeyboard(stepParams["session_key"], stepParams["page_offest"], settings.Language)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(settings.Language, "Двухфакторная аутентификация отключена."))
+	response.ReplyMarkup = keyboard
+
+	_, err = controllers.SendTracked(client, repos.BotMessages, response, stepUpdate.Message.From.ID)
+
+	return err
+}
+
+func (t TwoFactor) Run(ctx *handlers.Context) error {
+	update := ctx.Update
+	data := ctx.CallbackData
+
+	// В хранилище-приманке кнопки 2FA нет, на ее старые данные отвечается как на устаревшую кнопку
+	if ctx.Session.IsDuress {
+		_, err := t.Client.Request(tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, handlers.StaleButtonText))
+
+		return err
+	}
+
+	controllers.ClearNextStepForUser(update, t.Client, true)
+
+	stepParams, err := t.getStepParams(update, data)
+	if err != nil {
+		return err
+	}
+
+	user, err := t.Repos.Users.GetByTelegramID(update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !user.TOTPEnabled:
+		return t.StartEnrollment(update, stepParams)
+	case data["a"] == "u":
+		return baseForm(t.Client, t.Repos, update, stepParams, "Отправьте код из приложения или код восстановления для отключения 2FA:", "Отключение 2FA отменено", withRepos(t.Repos, disableTOTP), stepParams["on_cancel"].(string), false)
+	default:
+		return t.ShowStatus(update, user, stepParams)
+	}
+}
+
+func (t TwoFactor) GetName() string {
+	return t.Name
+}
@@ -0,0 +1,67 @@
+package actions
+
+import (
+	"main/crypto"
+	"main/database/models"
+	"main/repository"
+	"testing"
+)
+
+func TestRecoveryCodeIsUsedOnce(t *testing.T) {
+	repos := repository.NewMemoryRepos()
+
+	password := "master"
+	totpSecret, err := crypto.Encrypt(crypto.GenerateTOTPSecret(), password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := crypto.GenerateRecoveryCodes(2)
+	user := &models.Users{
+		TelegramID:   1,
+		PasswordHash: crypto.HashString(password),
+		TOTPSecret:   totpSecret,
+		TOTPEnabled:  true,
+	}
+	for _, code := range codes {
+		user.RecoveryCodes = append(user.RecoveryCodes, crypto.HashRecoveryCode(code))
+	}
+	repos.Users.Create(user)
+
+	ok, err := checkSecondFactor(repos.Users, user, password, codes[0])
+	if err != nil || !ok {
+		t.Fatalf("first use of a recovery code = %v, %v", ok, err)
+	}
+
+	stored, _ := repos.Users.GetByTelegramID(1)
+	if len(stored.RecoveryCodes) != 1 {
+		t.Fatalf("%d recovery codes stored, want 1 left", len(stored.RecoveryCodes))
+	}
+
+	ok, err = checkSecondFactor(repos.Users, stored, password, codes[0])
+	if err != nil || ok {
+		t.Fatalf("second use of a recovery code = %v, %v", ok, err)
+	}
+}
+
+func TestPageCountUsesOnlyCurrentVault(t *testing.T) {
+	secrets := repository.NewMemoryRepos().Secrets
+
+	for i := 0; i < 7; i++ {
+		secrets.Create(&models.Secrets{UserID: 1})
+	}
+	secrets.Create(&models.Secrets{UserID: 1, IsDecoy: true})
+
+	pageNo, pageCount, err := getPageNoAndCount(secrets, 6, 1, false, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pageNo != 2 || pageCount != 2 {
+		t.Fatalf("page %d of %d, want 2 of 2", pageNo, pageCount)
+	}
+
+	pageNo, pageCount, _ = getPageNoAndCount(secrets, 0, 1, true, 6)
+	if pageNo != 1 || pageCount != 1 {
+		t.Fatalf("decoy vault: page %d of %d, want 1 of 1", pageNo, pageCount)
+	}
+}
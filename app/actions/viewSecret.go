@@ -4,20 +4,56 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"main/controllers"
 	"main/crypto"
-	"main/database"
 	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
 	"main/util"
+	"strings"
+	"sync"
+	"time"
 	"unicode/utf16"
 
-	"github.com/go-pg/pg/v10"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+const (
+	MASKED_PASSWORD         = "••••••••"
+	PASSWORD_REVEAL_SECONDS = 10
+)
+
+type messageKey struct {
+	ChatID    int64
+	MessageID int
+}
+
+// revealTimers хранит таймеры, которые снова маскируют пароль, показанный по кнопке
+var (
+	revealTimers   = make(map[messageKey]*time.Timer)
+	revealTimersMu sync.Mutex
+)
+
+// cancelChatRemasks отменяет все ожидающие маскирования в чате, например при блокировке хранилища
+func cancelChatRemasks(chatID int64) {
+	revealTimersMu.Lock()
+	defer revealTimersMu.Unlock()
+
+	for key, timer := range revealTimers {
+		if key.ChatID == chatID {
+			timer.Stop()
+			delete(revealTimers, key)
+		}
+	}
+}
+
 type ViewSecret struct {
 	Name   string
-	Client tgbotapi.BotAPI
-	DB     *pg.DB
+	Client telegram.Messenger
+	Repos  repository.Repos
 }
 
 type viewSecretCallbackData struct {
@@ -100,43 +136,62 @@ func EntityMachine(text string, keywords []keywordObj) []tgbotapi.MessageEntity
 	return entities
 }
 
-func (v ViewSecret) formatSecretMessage(secret *models.Secrets) (string, []tgbotapi.MessageEntity) {
-	messageText := fmt.Sprintf("=== %s ===\n\nЛогин: %s\nПароль: %s",
-		secret.Title,
-		secret.Login,
-		secret.Password,
-	)
+// messageBuilder собирает текст и сущности по позициям частей текста. Поиск подстроки здесь не годится:
+// пароль может совпасть с частью подписи, например "Пароль", и тогда спойлер скрыл бы подпись, а не пароль.
+type messageBuilder struct {
+	text     strings.Builder
+	length   int // Длина текста в UTF-16, в ней Telegram считает смещения
+	entities []tgbotapi.MessageEntity
+}
 
-	dataForEntityMachine := []keywordObj{
-		{Keyword: fmt.Sprintf("=== %s ===", secret.Title), EntityName: "bold"},
-		{Keyword: secret.Login, EntityName: "code"},
-		{Keyword: secret.Password, EntityName: "code"},
+// write добавляет часть текста; если entityType не пустой, часть выделяется сущностью этого типа
+func (b *messageBuilder) write(part, entityType string) {
+	length := len(utf16.Encode([]rune(part)))
+	if entityType != "" && length > 0 {
+		b.entities = append(b.entities, tgbotapi.MessageEntity{Type: entityType, Offset: b.length, Length: length})
 	}
 
-	if secret.SiteLink != "" {
-		messageText += fmt.Sprintf("\nГде использовать: %s", secret.SiteLink)
-		dataForEntityMachine = append(dataForEntityMachine, keywordObj{Keyword: fmt.Sprintf("Где использовать: %s", secret.SiteLink), EntityName: "null"})
+	b.text.WriteString(part)
+	b.length += length
+}
+
+func (v ViewSecret) formatSecretMessage(secret *models.Secrets, displayMode, lang string) (string, []tgbotapi.MessageEntity) {
+	password := secret.Password
+	if displayMode == controllers.DisplayModeMasked {
+		password = MASKED_PASSWORD
 	}
 
-	if secret.Description != "" {
-		messageText += fmt.Sprintf("\n\nОписание:\n%s", secret.Description)
-		dataForEntityMachine = append(dataForEntityMachine, keywordObj{Keyword: "Описание:", EntityName: "italic"})
-		dataForEntityMachine = append(dataForEntityMachine, keywordObj{Keyword: secret.Description, EntityName: "blockquote"})
+	passwordEntity := "code"
+	switch displayMode {
+	case controllers.DisplayModeSpoiler:
+		passwordEntity = "spoiler"
+	case controllers.DisplayModeMasked:
+		// Маску выделять незачем, пароль показывается по кнопке
+		passwordEntity = ""
 	}
 
-	entities := EntityMachine(messageText, dataForEntityMachine)
+	var message messageBuilder
+	message.write(fmt.Sprintf("=== %s ===", secret.Title), "bold")
+	message.write(fmt.Sprintf("\n\n%s: ", i18n.T(lang, "Логин")), "")
+	message.write(secret.Login, "code")
+	message.write(fmt.Sprintf("\n%s: ", i18n.T(lang, "Пароль")), "")
+	message.write(password, passwordEntity)
 
-	for i, e := range entities {
-		if e.Type == "null" {
-			entities = append(entities[:i], entities[i+1:]...)
-			i--
-		}
+	if secret.SiteLink != "" {
+		message.write(fmt.Sprintf("\n%s: %s", i18n.T(lang, "Где использовать"), secret.SiteLink), "")
 	}
 
-	return messageText, entities
+	if secret.Description != "" {
+		message.write("\n\n", "")
+		message.write(i18n.T(lang, "Описание")+":", "italic")
+		message.write("\n", "")
+		message.write(secret.Description, "blockquote")
+	}
+
+	return message.text.String(), message.entities
 }
 
-func (v ViewSecret) createKeyboard(data viewSecretCallbackData) tgbotapi.InlineKeyboardMarkup {
+func (v ViewSecret) createKeyboard(data viewSecretCallbackData, withRevealButton bool, lang string) tgbotapi.InlineKeyboardMarkup {
 	backData := viewSecretCallbackData{
 		Action:     "c",
 		SessionKey: data.SessionKey,
@@ -152,56 +207,112 @@ func (v ViewSecret) createKeyboard(data viewSecretCallbackData) tgbotapi.InlineK
 	}
 	deleteDataJSON, _ := json.Marshal(deleteData)
 
-	return tgbotapi.InlineKeyboardMarkup{
+	shareData := viewSecretCallbackData{
+		Action:     "h",
+		SessionKey: data.SessionKey,
+		Offset:     data.Offset,
+		SecretID:   data.SecretID,
+	}
+	shareDataJSON, _ := json.Marshal(shareData)
+
+	keyboard := tgbotapi.InlineKeyboardMarkup{
 		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
 			{
 				{
-					Text:         "Назад",
+					Text:         i18n.T(lang, "Поделиться"),
+					CallbackData: util.StringPtr(string(shareDataJSON)),
+				},
+			},
+			{
+				{
+					Text:         i18n.T(lang, "Назад"),
 					CallbackData: util.StringPtr(string(backDataJSON)),
 				},
 				{
-					Text:         "Удалить",
+					Text:         i18n.T(lang, "Удалить"),
 					CallbackData: util.StringPtr(string(deleteDataJSON)),
 				},
 			},
 		},
 	}
-}
 
-func (v ViewSecret) Run(update tgbotapi.Update) error {
-	v.DB = database.GetDB()
+	if withRevealButton {
+		revealData := viewSecretCallbackData{
+			Action:     "v",
+			SessionKey: data.SessionKey,
+			Offset:     data.Offset,
+			SecretID:   data.SecretID,
+		}
+		revealDataJSON, _ := json.Marshal(revealData)
 
-	if update.CallbackQuery == nil {
-		return errors.New("callback query is nil")
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{{
+			{
+				Text:         i18n.T(lang, "Показать пароль"),
+				CallbackData: util.StringPtr(string(revealDataJSON)),
+			},
+		}}, keyboard.InlineKeyboard...)
 	}
 
-	var data viewSecretCallbackData
-	if err := json.Unmarshal([]byte(update.CallbackQuery.Data), &data); err != nil {
-		return fmt.Errorf("failed to unmarshal callback data: %w", err)
+	return keyboard
+}
+
+// scheduleRemask через несколько секунд возвращает сообщению замаскированный вид
+func (v ViewSecret) scheduleRemask(chatID int64, messageID int, text string, entities []tgbotapi.MessageEntity, keyboard tgbotapi.InlineKeyboardMarkup) {
+	key := messageKey{ChatID: chatID, MessageID: messageID}
+
+	revealTimersMu.Lock()
+	defer revealTimersMu.Unlock()
+
+	if timer, ok := revealTimers[key]; ok {
+		timer.Stop()
 	}
 
-	// Проверяем наличие активной сессии
-	var session models.Sessions
-	err := v.DB.Model(&session).
-		Where("user_id = ?", update.CallbackQuery.From.ID).
-		Order("created_at DESC").
-		Limit(1).
-		Select()
+	var timer *time.Timer
+	timer = time.AfterFunc(PASSWORD_REVEAL_SECONDS*time.Second, func() {
+		revealTimersMu.Lock()
+		if revealTimers[key] != timer {
+			revealTimersMu.Unlock()
+			return
+		}
+		delete(revealTimers, key)
+		revealTimersMu.Unlock()
 
-	if err == pg.ErrNoRows {
-		// Если нет активной сессии, удаляем сообщение
-		deleteMsg := tgbotapi.NewDeleteMessage(
-			update.CallbackQuery.Message.Chat.ID,
-			update.CallbackQuery.Message.MessageID,
-		)
-		_, err = v.Client.Send(deleteMsg)
-		return err
+		editMsg := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+		editMsg.Entities = entities
+
+		if _, err := v.Client.Send(editMsg); err != nil {
+			log.Printf("Failed to mask password again: %v", err)
+		}
+	})
+	revealTimers[key] = timer
+}
+
+// cancelRemask отменяет обратное маскирование, если пользователь ушел со страницы секрета
+func cancelRemask(chatID int64, messageID int) {
+	key := messageKey{ChatID: chatID, MessageID: messageID}
+
+	revealTimersMu.Lock()
+	defer revealTimersMu.Unlock()
+
+	if timer, ok := revealTimers[key]; ok {
+		timer.Stop()
+		delete(revealTimers, key)
 	}
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to get session: %w", err)
+func (v ViewSecret) Run(ctx *handlers.Context) error {
+	update := ctx.Update
+	session := ctx.Session
+
+	if update.CallbackQuery == nil {
+		return errors.New("callback query is nil")
 	}
 
+	// Данные кнопки разбирает кодек маршрута
+	data := *ctx.Payload.(*viewSecretCallbackData)
+
+	// Наличие активной сессии проверяет RequireSession
+
 	// Расшифровываем пароль сессии
 	sessionPassword, err := crypto.Decrypt(session.EncryptedPassword, data.SessionKey)
 	if err != nil {
@@ -209,25 +320,32 @@ func (v ViewSecret) Run(update tgbotapi.Update) error {
 	}
 
 	// Получаем секрет
-	var secret models.Secrets
-	err = v.DB.Model(&secret).
-		Where("id = ?", data.SecretID).
-		Select()
-
+	secret, err := v.Repos.Secrets.Get(update.CallbackQuery.From.ID, session.IsDuress, int64(data.SecretID))
 	if err != nil {
 		return fmt.Errorf("failed to get secret: %w", err)
 	}
 
 	// Расшифровываем данные секрета
-	if err = v.decryptSecret(&secret, sessionPassword); err != nil {
+	if err = v.decryptSecret(secret, sessionPassword); err != nil {
 		return err
 	}
 
+	settings, err := controllers.GetUserSettings(v.Repos.Settings, update.CallbackQuery.From.ID)
+	if err != nil {
+		return err
+	}
+
+	// По кнопке "Показать пароль" пароль временно выводится как обычно
+	displayMode := settings.DisplayMode
+	if data.Action == "v" {
+		displayMode = controllers.DisplayModeCode
+	}
+
 	// Форматируем сообщение и получаем entities
-	messageText, entities := v.formatSecretMessage(&secret)
+	messageText, entities := v.formatSecretMessage(secret, displayMode, settings.Language)
 
 	// Создаем клавиатуру
-	keyboard := v.createKeyboard(data)
+	keyboard := v.createKeyboard(data, displayMode == controllers.DisplayModeMasked, settings.Language)
 
 	// Обновляем сообщение
 	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
@@ -239,7 +357,46 @@ func (v ViewSecret) Run(update tgbotapi.Update) error {
 	editMsg.Entities = entities
 
 	_, err = v.Client.Send(editMsg)
-	return err
+	if err != nil {
+		return err
+	}
+
+	controllers.Audit(v.Repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditSecretView,
+		UserID:    update.CallbackQuery.From.ID,
+		ChatID:    update.CallbackQuery.Message.Chat.ID,
+		SecretID:  int64(data.SecretID),
+		IsDuress:  session.IsDuress,
+	})
+
+	if data.Action == "v" {
+		maskedText, maskedEntities := v.formatSecretMessage(secret, controllers.DisplayModeMasked, settings.Language)
+		v.scheduleRemask(
+			update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID,
+			maskedText,
+			maskedEntities,
+			v.createKeyboard(data, true, settings.Language),
+		)
+	}
+
+	return scheduleSecretHiding(v.Repos.ExpiringMessages, update, settings)
+}
+
+// scheduleSecretHiding ставит сообщение с расшифрованным секретом в очередь на скрытие
+func scheduleSecretHiding(repo repository.ExpiringMessageRepo, update tgbotapi.Update, settings *models.UserSettings) error {
+	if settings.RevealTimeout <= 0 {
+		return nil
+	}
+
+	return controllers.ScheduleMessageExpiry(
+		repo,
+		update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID,
+		update.CallbackQuery.From.ID,
+		settings.RevealTimeout,
+		controllers.ExpireActionHide,
+	)
 }
 
 func (v ViewSecret) GetName() string {
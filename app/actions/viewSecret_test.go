@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"main/controllers"
+	"main/database/models"
+	"main/i18n"
+	"slices"
+	"testing"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSpoilerModeHidesPasswordWithSpoilerOnly(t *testing.T) {
+	secret := &models.Secrets{Title: "GitHub", Login: "octocat", Password: "hunter2"}
+
+	_, entities := ViewSecret{}.formatSecretMessage(secret, controllers.DisplayModeSpoiler, "ru")
+
+	var spoilers []tgbotapi.MessageEntity
+	for _, entity := range entities {
+		if entity.Type == "spoiler" {
+			spoilers = append(spoilers, entity)
+		}
+	}
+	if len(spoilers) != 1 {
+		t.Fatalf("entities = %+v, want one spoiler", entities)
+	}
+
+	for _, entity := range entities {
+		if entity.Type != "spoiler" && entity.Offset < spoilers[0].Offset+spoilers[0].Length && spoilers[0].Offset < entity.Offset+entity.Length {
+			t.Fatalf("%s entity overlaps the spoiler: %+v", entity.Type, entities)
+		}
+	}
+}
+
+func TestSpoilerHidesPasswordMatchingLabel(t *testing.T) {
+	for _, lang := range []string{i18n.LangRu, i18n.LangEn} {
+		for _, password := range []string{"Пароль", "ароль", "а", "in", "Password", "Логин: octocat"} {
+			secret := &models.Secrets{Title: "GitHub", Login: "octocat", Password: password}
+
+			text, entities := ViewSecret{}.formatSecretMessage(secret, controllers.DisplayModeSpoiler, lang)
+
+			textUTF16 := utf16.Encode([]rune(text))
+			passwordUTF16 := utf16.Encode([]rune(password))
+			want := tgbotapi.MessageEntity{Type: "spoiler", Offset: len(textUTF16) - len(passwordUTF16), Length: len(passwordUTF16)}
+			if !slices.Contains(entities, want) {
+				t.Errorf("%s, password %q: entities = %+v, want %+v", lang, password, entities, want)
+			}
+		}
+	}
+}
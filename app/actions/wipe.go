@@ -0,0 +1,234 @@
+package actions
+
+import (
+	"fmt"
+	"log"
+	"main/controllers"
+	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+	"os"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const WIPE_CONFIRMATION_PHRASE = "УДАЛИТЬ ВСЁ"
+
+type Wipe struct {
+	Name   string
+	Client telegram.Messenger
+	Repos  repository.Repos
+}
+
+func (w Wipe) AskMasterPassword(update tgbotapi.Update) error {
+	w.Client.Request(tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID))
+
+	settings, err := controllers.GetUserSettings(w.Repos.Settings, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	response := tgbotapi.NewMessage(update.Message.Chat.ID, i18n.T(settings.Language, "Экстренное удаление данных.\n\nВведите мастер-пароль:"))
+	_, err = controllers.SendTracked(w.Client, w.Repos.BotMessages, response, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	controllers.GetNextStepManager().RegisterNextStepAction(controllers.NextStepKey{
+		ChatID: update.Message.Chat.ID,
+		UserID: update.Message.From.ID,
+	}, controllers.NextStepAction{
+		Func:          withRepos(w.Repos, handleWipePassword),
+		Params:        make(map[string]any),
+		CreatedAtTS:   time.Now().Unix(),
+		CancelMessage: i18n.T(settings.Language, "Удаление данных отменено"),
+	})
+
+	return nil
+}
+
+func handleWipePassword(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	stepKey := controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}
+
+	blocked, err := loginBlocked(client, repos, stepUpdate)
+	if err != nil || blocked {
+		return err
+	}
+
+	user, err := repos.Users.GetByTelegramID(stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	password := stepUpdate.Message.Text
+	isDuress := isDuressPassword(user, password)
+	if !isDuress && !isRealPassword(user, password) {
+		controllers.GetNextStepManager().RemoveNextStepAction(stepKey, client, false)
+
+		return rejectPassword(client, repos, stepUpdate, user, password, i18n.T(lang, "Неверный пароль."))
+	}
+
+	stepParams["password"] = password
+	stepParams["duress"] = isDuress
+
+	text := i18n.T(lang, "Будут безвозвратно удалены все секреты, сессии и незавершенные действия, а также сообщения бота в этом чате.")
+	if os.Getenv("WIPE_BACKUP_TO_ADMIN") == "true" {
+		text += "\n\n" + i18n.T(lang, "Администратору будет отправлена резервная копия секретов, зашифрованная мастер-паролем.")
+		// О хранилище-приманке говорится только владельцу, вошедшему настоящим паролем
+		if !isDuress && user.DuressPasswordHash != "" {
+			text += " " + i18n.T(lang, "Хранилище-приманка тоже будет удалено, но в копию не попадет.")
+		}
+	}
+
+	response := tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, fmt.Sprintf(i18n.T(lang, "%s\n\nДля подтверждения отправьте фразу:\n%s"), text, i18n.T(lang, WIPE_CONFIRMATION_PHRASE)))
+	_, err = controllers.SendTracked(client, repos.BotMessages, response, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	controllers.GetNextStepManager().RegisterNextStepAction(stepKey, controllers.NextStepAction{
+		Func:          withRepos(repos, handleWipeConfirmation),
+		Params:        stepParams,
+		CreatedAtTS:   time.Now().Unix(),
+		CancelMessage: i18n.T(lang, "Удаление данных отменено"),
+	})
+
+	return nil
+}
+
+// sendWipeBackup отправляет администратору копию удаляемых секретов в формате /backup.
+// Фраза-пароль копии - мастер-пароль пользователя, восстановить ее можно командой /restore.
+func sendWipeBackup(client telegram.Messenger, settingsRepo repository.SettingsRepo, telegramID int64, password string, secrets []models.Secrets) error {
+	adminID, err := util.GetAdminID()
+	if err != nil {
+		return err
+	}
+
+	adminSettings, err := controllers.GetUserSettings(settingsRepo, adminID)
+	if err != nil {
+		return err
+	}
+
+	data, err := buildBackup(secrets, password, password)
+	if err != nil {
+		return err
+	}
+
+	document := tgbotapi.NewDocument(adminID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("wipe-backup-%d-%d.json", telegramID, time.Now().Unix()),
+		Bytes: data,
+	})
+	document.Caption = fmt.Sprintf(i18n.T(adminSettings.Language, "Резервная копия перед экстренным удалением данных пользователя %d. Фраза-пароль - его мастер-пароль, восстановить копию можно командой /restore."), telegramID)
+
+	_, err = client.Send(document)
+
+	return err
+}
+
+func handleWipeConfirmation(client telegram.Messenger, repos repository.Repos, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID-1))
+	client.Request(tgbotapi.NewDeleteMessage(stepUpdate.Message.Chat.ID, stepUpdate.Message.MessageID))
+
+	controllers.GetNextStepManager().RemoveNextStepAction(controllers.NextStepKey{
+		ChatID: stepUpdate.Message.Chat.ID,
+		UserID: stepUpdate.Message.From.ID,
+	}, client, false)
+
+	settings, err := controllers.GetUserSettings(repos.Settings, stepUpdate.Message.From.ID)
+	if err != nil {
+		return err
+	}
+	lang := settings.Language
+
+	phrase := stepUpdate.Message.Text
+	if phrase != WIPE_CONFIRMATION_PHRASE && phrase != i18n.T(lang, WIPE_CONFIRMATION_PHRASE) {
+		_, err := controllers.SendTracked(client, repos.BotMessages, tgbotapi.NewMessage(stepUpdate.Message.Chat.ID, i18n.T(lang, "Фраза не совпадает. Удаление данных отменено.")), stepUpdate.Message.From.ID)
+
+		return err
+	}
+
+	telegramID := stepUpdate.Message.From.ID
+	chatID := stepUpdate.Message.Chat.ID
+	isDuress := stepParams["duress"].(bool)
+
+	// При пароле под принуждением удаляется только хранилище-приманка
+	secrets, err := repos.Secrets.List(telegramID, isDuress, repository.SortOrderOld, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	backupSent := false
+	if os.Getenv("WIPE_BACKUP_TO_ADMIN") == "true" && len(secrets) > 0 {
+		err = sendWipeBackup(client, repos.Settings, telegramID, stepParams["password"].(string), secrets)
+		if err != nil {
+			log.Printf("Failed to send wipe backup: %v", err)
+		} else {
+			backupSent = true
+			controllers.Audit(repos.Audit, controllers.AuditEntry{
+				EventType: controllers.AuditExport,
+				UserID:    telegramID,
+				ChatID:    chatID,
+				IsDuress:  isDuress,
+			})
+		}
+	}
+
+	wipe := &models.Wipes{
+		TelegramID: telegramID,
+		ChatID:     chatID,
+		BackupSent: backupSent,
+		IsDuress:   isDuress,
+	}
+
+	err = repos.Wipes.Wipe(wipe)
+	if err != nil {
+		return err
+	}
+
+	controllers.Audit(repos.Audit, controllers.AuditEntry{
+		EventType: controllers.AuditWipe,
+		UserID:    telegramID,
+		ChatID:    chatID,
+		IsDuress:  isDuress,
+	})
+
+	// Шаги в других чатах тоже ждут ввода пароля от удаленного хранилища
+	controllers.GetNextStepManager().RemoveUserSteps(telegramID, client, false)
+
+	wipe.MessagesDeleted, err = controllers.DeleteTrackedMessages(client, repos.BotMessages, chatID)
+	if err != nil {
+		log.Printf("Failed to delete tracked messages: %v", err)
+	} else {
+		repos.Wipes.Update(wipe, "messages_deleted")
+	}
+
+	_, err = client.Send(tgbotapi.NewMessage(chatID, i18n.T(lang, "Все данные удалены.")))
+
+	return err
+}
+
+func (w Wipe) Run(ctx *handlers.Context) error {
+	controllers.ClearNextStepForUser(ctx.Update, w.Client, false)
+
+	return w.AskMasterPassword(ctx.Update)
+}
+
+func (w Wipe) GetName() string {
+	return w.Name
+}
@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"main/actions"
+	"main/controllers"
+	"main/dispatcher"
+	"main/handlers"
+	"main/ratelimit"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	SLOW_HANDLER_THRESHOLD = 2 * time.Second // Обработчики дольше этого времени попадают в лог
+
+	// Частота команд и нажатий кнопок для одного пользователя: в среднем rate в секунду, подряд не больше burst
+	MESSAGES_RATE   = 1
+	MESSAGES_BURST  = 5
+	CALLBACKS_RATE  = 2
+	CALLBACKS_BURST = 10
+)
+
+var (
+	MessagesLimiter  = ratelimit.NewLimiter(MESSAGES_RATE, MESSAGES_BURST)
+	CallbacksLimiter = ratelimit.NewLimiter(CALLBACKS_RATE, CALLBACKS_BURST)
+)
+
+// GetBotActions собирает обработчики команд и кнопок бота
+func GetBotActions(bot telegram.Messenger, repos repository.Repos) handlers.ActiveHandlers {
+	// Ссылка на секрет - это тоже /start, но с параметром share_<токен>
+	shareFilter := func(update tgbotapi.Update) bool {
+		return update.Message != nil && update.Message.Command() == "start" &&
+			strings.HasPrefix(update.Message.CommandArguments(), controllers.SharePrefix)
+	}
+	startFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "start" && !shareFilter(update) }
+	duressFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "duress" }
+	wipeFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "wipe" }
+	lockFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "lock" }
+	auditFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "audit" }
+	backupFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "backup" }
+	restoreFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "restore" }
+	importFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "import" }
+	kdbxFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "kdbx" }
+	exportFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "export" }
+	settingsFilter := func(update tgbotapi.Update) bool {
+		return slices.Contains([]string{"autohide", "display"}, update.Message.Command())
+	}
+
+	adminId, err := util.GetAdminID()
+	if err != nil {
+		panic(err)
+	}
+
+	router := handlers.NewRouter(bot)
+	router.Handle(actions.CallbackRoutes(bot, repos)...)
+
+	act := handlers.ActiveHandlers{
+		Client:    bot,
+		ErrorSink: handlers.LogErrorSink{},
+		Middleware: []handlers.Middleware{
+			handlers.AdminOnly(adminId, shareFilter), // Ссылку на секрет открывает получатель, а не владелец
+			handlers.RateLimit(MessagesLimiter, CallbacksLimiter, bot),
+			handlers.Timing(SLOW_HANDLER_THRESHOLD),
+		},
+	}
+	act.Handlers = []handlers.Handler{
+		router, // Все кнопки
+		handlers.CommandHandler.Product(actions.MainPage{Name: "main-page-cmd", Client: bot, Repos: repos}, []handlers.Filter{startFilter}),
+		handlers.CommandHandler.Product(actions.OpenShare{Name: "open-share-cmd", Client: bot, Repos: repos}, []handlers.Filter{shareFilter}),
+		handlers.CommandHandler.Product(actions.Duress{Name: "duress-cmd", Client: bot, Repos: repos}, []handlers.Filter{duressFilter}, handlers.LoadSession(repos.Sessions)),
+		handlers.CommandHandler.Product(actions.Wipe{Name: "wipe-cmd", Client: bot, Repos: repos}, []handlers.Filter{wipeFilter}),
+		handlers.CommandHandler.Product(actions.Lock{Name: "lock-cmd", Client: bot, Repos: repos}, []handlers.Filter{lockFilter}),
+		handlers.CommandHandler.Product(actions.Settings{Name: "settings-cmd", Client: bot, Repos: repos}, []handlers.Filter{settingsFilter}),
+		handlers.CommandHandler.Product(actions.AuditLog{Name: "audit-cmd", Client: bot, Repos: repos}, []handlers.Filter{auditFilter}, handlers.LoadSession(repos.Sessions)),
+		handlers.CommandHandler.Product(actions.Backup{Name: "backup-cmd", Client: bot, Repos: repos}, []handlers.Filter{backupFilter}),
+		handlers.CommandHandler.Product(actions.Restore{Name: "restore-cmd", Client: bot, Repos: repos}, []handlers.Filter{restoreFilter}),
+		handlers.CommandHandler.Product(actions.Import{Name: "import-cmd", Client: bot, Repos: repos}, []handlers.Filter{importFilter}),
+		handlers.CommandHandler.Product(actions.KDBXExport{Name: "kdbx-cmd", Client: bot, Repos: repos}, []handlers.Filter{kdbxFilter}),
+		handlers.CommandHandler.Product(actions.PlainExport{Name: "export-cmd", Client: bot, Repos: repos}, []handlers.Filter{exportFilter}),
+		handlers.DocumentHandler.Product(actions.Import{Name: "import-file", Client: bot, Repos: repos}, nil), // Файлы, которые не ждет ни один шаг диалога
+	}
+
+	return act
+}
+
+// HandleUpdate возвращает обработчик обновления: сначала ожидаемый шаг диалога, потом команды и кнопки
+func HandleUpdate(client telegram.Messenger, act handlers.ActiveHandlers) dispatcher.HandleFunc {
+	stepManager := controllers.GetNextStepManager()
+
+	return func(update tgbotapi.Update) {
+		// Ответ на шаг диалога обработчикам не передается: иначе файл для /restore
+		// получил бы еще и обработчик импорта, а он сбрасывает шаг и начинает свой диалог
+		if controllers.RunStepUpdates(update, stepManager, client) {
+			return
+		}
+
+		_ = act.HandleAll(update)
+	}
+}
@@ -0,0 +1,866 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"main/actions"
+	"main/controllers"
+	"main/crypto"
+	"main/database"
+	"main/database/migrations"
+	"main/database/models"
+	"main/handlers"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/telegram/telegramtest"
+	"main/util"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const testMasterPassword = "correct horse battery staple"
+
+// botHarness прогоняет обновления поддельного сервера через те же обработчики, что и main.go
+type botHarness struct {
+	t       *testing.T
+	server  *telegramtest.Server
+	updates tgbotapi.UpdatesChannel
+	handle  func(update tgbotapi.Update)
+	repos   repository.Repos
+	user    tgbotapi.User
+}
+
+// newBotHarness работает с Postgres, если задан POSTGRES_HOST, а иначе - с отдельным временным файлом SQLite
+func newBotHarness(t *testing.T) *botHarness {
+	t.Setenv("AUDIT_HMAC_KEY", "test audit key")
+	t.Setenv("AUDIT_HEAD_PATH", filepath.Join(t.TempDir(), "audit_head.json"))
+
+	repos := testRepos(t)
+
+	// Telegram ID уникален для каждого запуска, чтобы не задеть чужие данные в базе
+	telegramID := time.Now().UnixNano() % 1_000_000_000_000
+	t.Setenv("ADMIN_ID", strconv.FormatInt(telegramID, 10))
+
+	err := repos.Users.Create(&models.Users{
+		TelegramID:   telegramID,
+		PasswordHash: crypto.HashString(testMasterPassword),
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if os.Getenv("POSTGRES_HOST") != "" {
+		t.Cleanup(func() { deleteTestUser(telegramID) })
+	}
+
+	server := telegramtest.NewServer()
+	t.Cleanup(server.Close)
+
+	bot, err := server.NewBot()
+	if err != nil {
+		t.Fatalf("connect to fake bot api: %v", err)
+	}
+	t.Cleanup(bot.StopReceivingUpdates)
+
+	updateConfig := tgbotapi.NewUpdate(0)
+	updateConfig.Timeout = 1
+
+	return &botHarness{
+		t:       t,
+		server:  server,
+		updates: bot.GetUpdatesChan(updateConfig),
+		handle:  HandleUpdate(bot, GetBotActions(bot, repos)),
+		repos:   repos,
+		user:    tgbotapi.User{ID: telegramID, FirstName: "Test", UserName: "tester"},
+	}
+}
+
+// testRepos возвращает хранилища с примененными миграциями: общую базу Postgres или новый файл SQLite
+func testRepos(t *testing.T) repository.Repos {
+	if os.Getenv("POSTGRES_HOST") != "" {
+		if err := database.Migrate(migrations.Postgres(database.GetDB())); err != nil {
+			t.Fatalf("migrate postgres: %v", err)
+		}
+
+		return repository.NewPgRepos(database.GetDB())
+	}
+
+	sqlDB, err := database.OpenSQLite(filepath.Join(t.TempDir(), "vault.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := database.Migrate(migrations.SQLite(sqlDB)); err != nil {
+		t.Fatalf("migrate sqlite: %v", err)
+	}
+
+	return repository.NewSQLiteRepos(sqlDB)
+}
+
+// deleteTestUser убирает данные тестового пользователя из общей базы Postgres. Журнал не чистится: записи в нем связаны в цепочку.
+func deleteTestUser(telegramID int64) {
+	db := database.GetDB()
+
+	db.Model(&models.Shares{}).Where("user_id = ?", telegramID).Delete()
+	db.Model(&models.Secrets{}).Where("user_id = ?", telegramID).Delete()
+	db.Model(&models.Sessions{}).Where("user_id = ?", telegramID).Delete()
+	db.Model(&models.BotMessages{}).Where("user_id = ?", telegramID).Delete()
+	db.Model(&models.ExpiringMessages{}).Where("user_id = ?", telegramID).Delete()
+	db.Model(&models.LoginAttempts{}).Where("telegram_id = ?", telegramID).Delete()
+	db.Model(&models.UserSettings{}).Where("telegram_id = ?", telegramID).Delete()
+	db.Model(&models.Users{}).Where("telegram_id = ?", telegramID).Delete()
+}
+
+// deliver забирает обновление через getUpdates и обрабатывает его
+func (h *botHarness) deliver(sent tgbotapi.Update) {
+	h.t.Helper()
+
+	select {
+	case update := <-h.updates:
+		if update.UpdateID != sent.UpdateID {
+			h.t.Fatalf("got update %d, want %d", update.UpdateID, sent.UpdateID)
+		}
+		h.handle(update)
+	case <-time.After(5 * time.Second):
+		h.t.Fatalf("update %d was not delivered", sent.UpdateID)
+	}
+}
+
+func (h *botHarness) sendText(text string) {
+	h.t.Helper()
+	h.deliver(h.server.SendText(h.user.ID, h.user, text))
+}
+
+func (h *botHarness) sendDocument(name string, data []byte) {
+	h.t.Helper()
+	h.deliver(h.server.SendDocument(h.user.ID, h.user, name, data))
+}
+
+func (h *botHarness) press(buttonText string) {
+	h.t.Helper()
+
+	message := h.lastMessage()
+	button, ok := message.Button(buttonText)
+	if !ok {
+		h.t.Fatalf("no button %q in message %q", buttonText, message.Text)
+	}
+
+	h.deliver(h.server.PressButton(message, h.user, button))
+}
+
+func (h *botHarness) lastMessage() telegramtest.Message {
+	h.t.Helper()
+
+	message, ok := h.server.LastBotMessage(h.user.ID)
+	if !ok {
+		h.t.Fatal("bot has not sent any messages")
+	}
+
+	return message
+}
+
+func (h *botHarness) expectText(substr string) telegramtest.Message {
+	h.t.Helper()
+
+	message := h.lastMessage()
+	if !strings.Contains(message.Text, substr) {
+		h.t.Fatalf("last bot message is %q, want it to contain %q", message.Text, substr)
+	}
+
+	return message
+}
+
+// expectDeletion проверяет, что сообщение будет удалено из чата не позже чем через timeout секунд
+func (h *botHarness) expectDeletion(message telegramtest.Message, timeout int64) {
+	h.t.Helper()
+
+	due, err := h.repos.ExpiringMessages.ListDue(time.Now().Unix() + timeout)
+	if err != nil {
+		h.t.Fatalf("list expiring messages: %v", err)
+	}
+
+	for _, m := range due {
+		if m.ChatID == message.ChatID && m.MessageID == message.MessageID && m.Action == controllers.ExpireActionDelete {
+			return
+		}
+	}
+
+	h.t.Fatalf("message %d is not scheduled for deletion: %+v", message.MessageID, due)
+}
+
+func TestSecretLifecycle(t *testing.T) {
+	h := newBotHarness(t)
+
+	h.sendText("/start")
+	h.expectText("Введите пароль")
+
+	h.sendText(testMasterPassword)
+	h.expectText("Выберите сервис")
+
+	// Пароль не должен остаться в чате
+	for _, message := range h.server.Messages(h.user.ID) {
+		if strings.Contains(message.Text, testMasterPassword) {
+			t.Fatalf("master password is still visible in message %d", message.MessageID)
+		}
+	}
+
+	h.press("+")
+	h.expectText("Отправьте название секрета")
+	h.sendText("GitHub")
+	h.expectText("Отправьте ваш логин")
+	h.sendText("octocat")
+	h.expectText("Отправьте ваш пароль")
+	h.sendText("hunter2")
+	h.expectText("Отправьте ссылку")
+	h.sendText("-")
+	h.expectText("Отправьте описание")
+	h.sendText("-")
+	h.expectText("Секрет успешно создан!")
+
+	stored, err := h.repos.Secrets.List(h.user.ID, false, repository.SortOrderOld, 0, 0)
+	if err != nil {
+		t.Fatalf("select secrets: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Title != "GitHub" {
+		t.Fatalf("stored secrets = %+v, want one titled GitHub", stored)
+	}
+	if stored[0].Password == "hunter2" || stored[0].Login == "octocat" {
+		t.Fatal("secret is stored unencrypted")
+	}
+
+	h.press("К секретам")
+	h.expectText("Выберите сервис")
+
+	h.press("GitHub")
+	view := h.expectText("octocat")
+	if !strings.Contains(view.Text, "hunter2") {
+		t.Fatalf("secret view %q does not show the password", view.Text)
+	}
+
+	h.press("Удалить")
+	mainPage := h.expectText("Выберите сервис")
+	if _, ok := mainPage.Button("GitHub"); ok {
+		t.Fatal("deleted secret is still listed")
+	}
+
+	answered := false
+	for _, call := range h.server.CallsTo("answerCallbackQuery") {
+		if call.Params.Get("text") == "Секрет удален" {
+			answered = true
+		}
+	}
+	if !answered {
+		t.Fatal("delete was not confirmed with a callback answer")
+	}
+
+	count, err := h.repos.Secrets.Count(h.user.ID, false)
+	if err != nil {
+		t.Fatalf("count secrets: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("%d secrets left after delete", count)
+	}
+
+	events, _, err := controllers.GetAuditEvents(h.repos.Audit, h.user.ID, false, 0, 10)
+	if err != nil {
+		t.Fatalf("get audit events: %v", err)
+	}
+
+	logged := map[string]bool{}
+	for _, event := range events {
+		logged[event.EventType] = true
+	}
+	for _, eventType := range []string{controllers.AuditLogin, controllers.AuditSecretCreate, controllers.AuditSecretView, controllers.AuditSecretDelete} {
+		if !logged[eventType] {
+			t.Errorf("audit event %q was not logged", eventType)
+		}
+	}
+}
+
+func TestAuditExportDetectsTruncation(t *testing.T) {
+	h := newBotHarness(t)
+
+	h.sendText("/audit")
+	h.expectText("Цепочка хешей не нарушена")
+
+	head, err := controllers.ReadAuditHead()
+	if err != nil || head == nil {
+		t.Fatalf("audit head = %+v, %v, want the export event", head, err)
+	}
+
+	// Голова указывает на запись, которой больше нет в базе
+	data, err := json.Marshal(controllers.AuditHead{ID: head.ID + 1_000_000, Hash: head.Hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(os.Getenv("AUDIT_HEAD_PATH"), data, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.sendText("/audit")
+	h.expectText("Последние записи журнала удалены")
+}
+
+func TestPasswordsStayOutOfLog(t *testing.T) {
+	h := newBotHarness(t)
+
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	h.addSecret("GitHub", "octocat", "hunter2")
+
+	h.sendText("/start")
+	h.sendText(testMasterPassword)
+	h.expectText("Выберите сервис")
+
+	h.sendText("/kdbx")
+	h.sendText(testMasterPassword)
+	h.sendText("file password")
+	h.sendText("file password")
+	h.expectText("База KeePass")
+
+	for _, secret := range []string{testMasterPassword, "file password"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Fatalf("log contains %q:\n%s", secret, buf.String())
+		}
+	}
+}
+
+func TestDuressAlert(t *testing.T) {
+	h := newBotHarness(t)
+
+	alertChatID := h.user.ID + 1
+	t.Setenv("DURESS_ALERT_CHAT_ID", strconv.FormatInt(alertChatID, 10))
+
+	user, err := h.repos.Users.GetByTelegramID(h.user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	user.DuressPasswordHash = crypto.HashDuressPassword("duress password")
+	user.DuressAlert = true
+	if err := h.repos.Users.Update(user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+
+	h.sendText("/start")
+	h.sendText("duress password")
+	h.expectText("Выберите сервис")
+
+	alert, ok := h.server.LastBotMessage(alertChatID)
+	if !ok || !strings.Contains(alert.Text, "паролем под принуждением") {
+		t.Fatalf("alert chat got %+v, want a duress alert", alert)
+	}
+	for _, message := range h.server.Messages(h.user.ID) {
+		if strings.Contains(message.Text, "паролем под принуждением") {
+			t.Fatal("duress alert is visible in the bot chat")
+		}
+	}
+}
+
+func TestDecoyVaultHasNoTwoFactor(t *testing.T) {
+	h := newBotHarness(t)
+
+	user, err := h.repos.Users.GetByTelegramID(h.user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	user.DuressPasswordHash = crypto.HashDuressPassword("duress password")
+	if err := h.repos.Users.Update(user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+
+	h.sendText("/start")
+	h.sendText("duress password")
+	menu := h.expectText("Выберите сервис")
+	if _, ok := menu.Button("2FA"); ok {
+		t.Fatal("decoy vault offers 2FA settings")
+	}
+
+	// Данные старой кнопки 2FA с ключом текущей сессии
+	settings, ok := menu.Button("Настройки")
+	if !ok {
+		t.Fatal("no settings button in the decoy vault")
+	}
+	data := map[string]any{}
+	if err := json.Unmarshal([]byte(*settings.CallbackData), &data); err != nil {
+		t.Fatal(err)
+	}
+	data["a"] = "t"
+	twoFactorData, _ := json.Marshal(data)
+
+	h.deliver(h.server.PressButton(menu, h.user, tgbotapi.InlineKeyboardButton{CallbackData: util.StringPtr(string(twoFactorData))}))
+
+	answers := h.server.CallsTo("answerCallbackQuery")
+	if len(answers) == 0 || answers[len(answers)-1].Params.Get("text") != handlers.StaleButtonText {
+		t.Fatalf("2FA button in the decoy vault was answered with %+v", answers)
+	}
+	if h.lastMessage().Text != menu.Text {
+		t.Fatal("2FA page was opened in the decoy vault")
+	}
+}
+
+func TestDecoyDuressSettings(t *testing.T) {
+	h := newBotHarness(t)
+
+	user, err := h.repos.Users.GetByTelegramID(h.user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	user.DuressPasswordHash = crypto.HashDuressPassword("duress password")
+	if err := h.repos.Users.Update(user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+
+	h.sendText("/start")
+	h.sendText("duress password")
+	h.expectText("Выберите сервис")
+
+	command := h.server.SendText(h.user.ID, h.user, "/duress")
+	h.deliver(command)
+	h.expectText("Введите мастер-пароль для настройки пароля под принуждением")
+
+	deleted := false
+	for _, call := range h.server.CallsTo("deleteMessage") {
+		deleted = deleted || call.Params.Get("message_id") == strconv.Itoa(command.Message.MessageID)
+	}
+	if !deleted {
+		t.Fatal("/duress command was not deleted in the decoy vault")
+	}
+
+	// Пароль под принуждением принимается вместо мастер-пароля, как в настоящем хранилище
+	h.sendText("duress password")
+	h.expectText("Отправьте новый пароль под принуждением")
+	h.sendText("another password")
+	h.expectText("Пароль под принуждением задан")
+	h.press("Сменить реакцию")
+	h.expectText("При входе: заблокировать основное хранилище на сутки")
+
+	user, err = h.repos.Users.GetByTelegramID(h.user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if !crypto.CheckDuressPassword("duress password", user.DuressPasswordHash) || user.DuressAction != actions.DuressActionNone {
+		t.Fatal("decoy vault changed the duress settings")
+	}
+}
+
+func TestLockedVaultPasswordIsNotAFailure(t *testing.T) {
+	h := newBotHarness(t)
+
+	user, err := h.repos.Users.GetByTelegramID(h.user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	user.VaultLockedUntil = time.Now().Unix() + actions.DURESS_LOCK_DURATION
+	if err := h.repos.Users.Update(user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+
+	h.sendText("/start")
+	h.sendText(testMasterPassword)
+	h.expectText("Неверный пароль.")
+
+	if _, err := h.repos.LoginAttempts.Get(h.user.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("master password of a locked vault was counted as a failed login: %v", err)
+	}
+}
+
+func TestEnglishDialogs(t *testing.T) {
+	h := newBotHarness(t)
+
+	err := h.repos.Settings.Save(&models.UserSettings{TelegramID: h.user.ID, Language: i18n.LangEn})
+	if err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+
+	h.sendText("/wipe")
+	h.expectText("Emergency wipe")
+	h.sendText(testMasterPassword)
+	h.expectText("DELETE EVERYTHING")
+	h.sendText("something else")
+	h.expectText("The phrase does not match")
+
+	h.sendText("/export")
+	h.expectText("Choose a format: /export csv or /export json.\n\n⚠️ WARNING")
+
+	h.sendText("/import")
+	h.expectText("Send an export file")
+	h.sendDocument("export.csv", []byte("name,login_username,login_password,type\nCard,,,card\n"))
+	h.expectText("entries")
+	h.sendText(testMasterPassword)
+	h.expectText("Skipped entries:\n2: entry type card is not supported")
+
+	h.sendText("/start")
+	h.sendText("wrong password")
+	h.expectText("Wrong password.\n\nGo away.")
+}
+
+// addSecret кладет секрет прямо в базу, зашифровав его так же, как бот
+func (h *botHarness) addSecret(title, login, password string) *models.Secrets {
+	h.t.Helper()
+
+	encryptedLogin, _ := crypto.Encrypt(login, testMasterPassword)
+	encryptedPassword, _ := crypto.Encrypt(password, testMasterPassword)
+
+	secret := &models.Secrets{UserID: h.user.ID, Title: title, Login: encryptedLogin, Password: encryptedPassword}
+	if err := h.repos.Secrets.Create(secret); err != nil {
+		h.t.Fatalf("create secret: %v", err)
+	}
+
+	return secret
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	h := newBotHarness(t)
+
+	github := h.addSecret("GitHub", "octocat", "hunter2")
+	mail := h.addSecret("Mail", "me", "letters")
+
+	h.sendText("/backup")
+	h.expectText("Введите мастер-пароль")
+	h.sendText(testMasterPassword)
+	h.expectText("Придумайте фразу-пароль")
+	h.sendText("short")
+	h.expectText("не короче")
+	h.sendText("backup passphrase")
+	h.expectText("Повторите фразу-пароль")
+	h.sendText("backup passphrase")
+
+	backup := h.expectText("2 секретов")
+	if backup.Document == nil {
+		t.Fatal("backup was not sent as a document")
+	}
+	if strings.Contains(string(backup.Document.Data), "hunter2") {
+		t.Fatal("backup contains a plaintext password")
+	}
+
+	// После копии хранилище расходится с ней во всем: пароль изменен, секрет удален, появился новый
+	newPassword, _ := crypto.Encrypt("changed", testMasterPassword)
+	github.Password = newPassword
+	err := h.repos.Secrets.Apply(h.user.ID, false, repository.SecretChanges{
+		Update: []models.Secrets{*github},
+		Delete: []int64{mail.ID},
+	})
+	if err != nil {
+		t.Fatalf("change vault: %v", err)
+	}
+	h.addSecret("Extra", "someone", "extra")
+
+	h.sendText("/restore")
+	h.expectText("Введите мастер-пароль")
+	h.sendText(testMasterPassword)
+	h.expectText("Отправьте файл резервной копии")
+	h.sendDocument(backup.Document.Name, backup.Document.Data)
+	h.expectText("Введите фразу-пароль")
+	h.sendText("wrong passphrase")
+	h.expectText("Неверная фраза-пароль")
+
+	h.sendText("/restore")
+	h.sendText(testMasterPassword)
+	h.sendDocument(backup.Document.Name, backup.Document.Data)
+	h.sendText("backup passphrase")
+	h.expectText("Новых: 1\nИзменившихся: 1\nБез изменений: 0\nЕсть только в хранилище: 1")
+	h.sendText("заменить")
+	h.expectText("Добавлено: 1\nОбновлено: 1\nУдалено: 1")
+
+	stored, err := h.repos.Secrets.List(h.user.ID, false, repository.SortOrderTitle, 0, 0)
+	if err != nil {
+		t.Fatalf("select secrets: %v", err)
+	}
+	if len(stored) != 2 || stored[0].Title != "GitHub" || stored[1].Title != "Mail" {
+		t.Fatalf("restored secrets = %+v, want GitHub and Mail", stored)
+	}
+	if password, _ := crypto.Decrypt(stored[0].Password, testMasterPassword); password != "hunter2" {
+		t.Fatalf("restored password = %q, want hunter2", password)
+	}
+
+	events, _, err := controllers.GetAuditEvents(h.repos.Audit, h.user.ID, false, 0, 20)
+	if err != nil {
+		t.Fatalf("get audit events: %v", err)
+	}
+
+	logged := map[string]bool{}
+	for _, event := range events {
+		logged[event.EventType] = true
+	}
+	if !logged[controllers.AuditExport] || !logged[controllers.AuditRestore] {
+		t.Fatalf("audit events = %+v, want an export and a restore", events)
+	}
+}
+
+func TestWipeBackupRestores(t *testing.T) {
+	h := newBotHarness(t)
+	t.Setenv("WIPE_BACKUP_TO_ADMIN", "true")
+
+	h.addSecret("GitHub", "octocat", "hunter2")
+
+	// Шаг, ожидающий пароль в другом чате, не должен пережить удаление данных
+	otherChat := controllers.NextStepKey{ChatID: h.user.ID + 1, UserID: h.user.ID}
+	controllers.GetNextStepManager().RegisterNextStepAction(otherChat, controllers.NextStepAction{
+		Func: func(client telegram.Messenger, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+			t.Error("step in another chat survived the wipe")
+
+			return nil
+		},
+		CreatedAtTS: time.Now().Unix(),
+	})
+	t.Cleanup(func() { controllers.GetNextStepManager().RemoveNextStepAction(otherChat, nil, false) })
+
+	h.sendText("/wipe")
+	h.expectText("Введите мастер-пароль")
+	h.sendText(testMasterPassword)
+	h.expectText("Администратору будет отправлена резервная копия")
+	h.sendText(actions.WIPE_CONFIRMATION_PHRASE)
+	h.expectText("Все данные удалены")
+
+	h.deliver(h.server.SendText(otherChat.ChatID, h.user, testMasterPassword))
+
+	var backup *telegramtest.Message
+	for _, message := range h.server.Messages(h.user.ID) {
+		if message.Document != nil && strings.HasPrefix(message.Document.Name, "wipe-backup-") {
+			backup = &message
+		}
+	}
+	if backup == nil {
+		t.Fatal("wipe backup was not sent to the admin")
+	}
+
+	h.sendText("/restore")
+	h.sendText(testMasterPassword)
+	h.sendDocument(backup.Document.Name, backup.Document.Data)
+	h.sendText(testMasterPassword)
+	h.expectText("Новых: 1")
+	h.sendText("заменить")
+	h.expectText("Добавлено: 1")
+
+	stored, err := h.repos.Secrets.List(h.user.ID, false, repository.SortOrderTitle, 0, 0)
+	if err != nil {
+		t.Fatalf("select secrets: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Title != "GitHub" {
+		t.Fatalf("restored secrets = %+v, want GitHub", stored)
+	}
+}
+
+func TestExportKDBX(t *testing.T) {
+	h := newBotHarness(t)
+
+	h.addSecret("GitHub", "octocat", "hunter2")
+
+	h.sendText("/kdbx")
+	h.expectText("Введите мастер-пароль")
+	h.sendText(testMasterPassword)
+	h.expectText("Придумайте пароль для файла")
+	h.sendText("file password")
+	h.expectText("Повторите пароль")
+	h.sendText("file password")
+
+	export := h.expectText("База KeePass: 1 секретов")
+	if export.Document == nil || !strings.HasSuffix(export.Document.Name, ".kdbx") {
+		t.Fatalf("export = %+v, want a .kdbx document", export)
+	}
+	if strings.Contains(string(export.Document.Data), "hunter2") || strings.Contains(string(export.Document.Data), "GitHub") {
+		t.Fatal("export contains plaintext secrets")
+	}
+
+	h.expectDeletion(export, actions.EXPORT_DOCUMENT_TTL)
+}
+
+func TestPlainExport(t *testing.T) {
+	h := newBotHarness(t)
+
+	h.addSecret("GitHub", "octocat", "hunter2")
+
+	h.sendText("/export")
+	h.expectText("/export csv или /export json")
+
+	h.sendText("/export csv")
+	h.expectText("в открытом виде")
+	h.sendText(testMasterPassword)
+
+	export := h.expectText("Экспорт в открытом виде: 1 секретов")
+	if export.Document == nil || !strings.HasSuffix(export.Document.Name, ".csv") {
+		t.Fatalf("export = %+v, want a .csv document", export)
+	}
+	if !strings.Contains(string(export.Document.Data), "login,GitHub,,,0,,octocat,hunter2,") {
+		t.Fatalf("export = %q, want a Bitwarden CSV row", export.Document.Data)
+	}
+
+	h.expectDeletion(export, actions.EXPORT_DOCUMENT_TTL)
+
+	events, _, err := controllers.GetAuditEvents(h.repos.Audit, h.user.ID, false, 0, 20)
+	if err != nil {
+		t.Fatalf("get audit events: %v", err)
+	}
+	if len(events) == 0 || events[0].EventType != controllers.AuditPlainExport {
+		t.Fatalf("audit events = %+v, want a plaintext export first", events)
+	}
+}
+
+func TestImportChromeCSV(t *testing.T) {
+	h := newBotHarness(t)
+
+	h.addSecret("github.com", "octocat", "hunter2")
+
+	h.sendText("/import")
+	h.expectText("Отправьте в этот чат файл экспорта")
+
+	export := "name,url,username,password\n" +
+		"github.com,https://github.com/login,octocat,hunter2\n" +
+		"example.com,https://example.com,me,secret\n" +
+		",,,\n"
+	h.sendDocument("Chrome Passwords.csv", []byte(export))
+
+	for _, message := range h.server.Messages(h.user.ID) {
+		if message.Document != nil {
+			t.Fatal("export file with plaintext passwords is still in the chat")
+		}
+	}
+
+	h.expectText("Файл Chrome CSV: записей 2, с ошибками 1")
+	h.sendText(testMasterPassword)
+	h.expectText("Добавлено: 1\nДубликаты (пропущены): 1\nОшибки: 1")
+
+	stored, err := h.repos.Secrets.List(h.user.ID, false, repository.SortOrderTitle, 0, 0)
+	if err != nil {
+		t.Fatalf("select secrets: %v", err)
+	}
+	if len(stored) != 2 || stored[0].Title != "example.com" {
+		t.Fatalf("secrets after import = %+v", stored)
+	}
+	if login, _ := crypto.Decrypt(stored[0].Login, testMasterPassword); login != "me" || stored[0].Login == "me" {
+		t.Fatalf("imported login is stored as %q", stored[0].Login)
+	}
+}
+
+// shareToken достает токен из сообщения со ссылкой на секрет
+// Файл, которого ждет шаг диалога, не должен доходить до обработчика файлов:
+// тот начал бы импорт и сбросил шаг, например ввод фразы-пароля после файла для /restore
+func TestStepAnswerSkipsHandlers(t *testing.T) {
+	h := newBotHarness(t)
+
+	key := controllers.NextStepKey{ChatID: h.user.ID, UserID: h.user.ID}
+	received := []string{}
+	controllers.GetNextStepManager().RegisterNextStepAction(key, controllers.NextStepAction{
+		Func: func(client telegram.Messenger, stepUpdate tgbotapi.Update, stepParams map[string]any) error {
+			received = append(received, stepUpdate.Message.Document.FileName)
+
+			return nil
+		},
+		CreatedAtTS: time.Now().Unix(),
+	})
+	t.Cleanup(func() { controllers.GetNextStepManager().RemoveNextStepAction(key, nil, false) })
+
+	h.sendDocument("first.csv", []byte("name,url,username,password\n"))
+	h.sendDocument("second.csv", []byte("name,url,username,password\n"))
+
+	if !slices.Equal(received, []string{"first.csv", "second.csv"}) {
+		t.Fatalf("step received %v, want both files", received)
+	}
+	if calls := h.server.CallsTo("sendMessage"); len(calls) != 0 {
+		t.Fatalf("handlers answered a step reply: %+v", calls)
+	}
+}
+
+func shareToken(t *testing.T, message telegramtest.Message) string {
+	t.Helper()
+
+	_, link, ok := strings.Cut(message.Text, "https://t.me/test_bot?start="+controllers.SharePrefix)
+	if !ok {
+		t.Fatalf("message %q has no share link", message.Text)
+	}
+
+	return strings.Fields(link)[0]
+}
+
+func TestShareLink(t *testing.T) {
+	h := newBotHarness(t)
+
+	github := h.addSecret("GitHub", "octocat", "hunter2")
+
+	h.sendText("/start")
+	h.sendText(testMasterPassword)
+	h.press("GitHub")
+	h.press("Поделиться")
+	h.expectText("Активных ссылок: 0")
+	h.press("Создать ссылку")
+	token := shareToken(t, h.expectText("Просмотров: 1"))
+
+	shares, err := h.repos.Shares.ListBySecret(h.user.ID, false, github.ID, time.Now().Unix())
+	if err != nil || len(shares) != 1 {
+		t.Fatalf("shares = %+v, %v, want one", shares, err)
+	}
+	for _, share := range shares {
+		if strings.Contains(share.Payload, "hunter2") || share.TokenHash == token {
+			t.Fatalf("share is stored in the clear: %+v", share)
+		}
+	}
+
+	stranger := tgbotapi.User{ID: h.user.ID + 1, FirstName: "Colleague"}
+	open := func() telegramtest.Message {
+		t.Helper()
+		h.deliver(h.server.SendText(stranger.ID, stranger, "/start "+controllers.SharePrefix+token))
+
+		message, ok := h.server.LastBotMessage(stranger.ID)
+		if !ok {
+			t.Fatal("bot did not answer the share link")
+		}
+
+		return message
+	}
+
+	view := open()
+	if !strings.Contains(view.Text, "octocat") || !strings.Contains(view.Text, "hunter2") {
+		t.Fatalf("shared secret view is %q", view.Text)
+	}
+	h.expectDeletion(view, actions.SHARE_MESSAGE_TTL)
+
+	if view := open(); !strings.Contains(view.Text, "недействительна") {
+		t.Fatalf("second view of a one-time link is %q", view.Text)
+	}
+
+	// Кроме ссылок, бот не отвечает чужим
+	last, _ := h.server.LastBotMessage(stranger.ID)
+	h.deliver(h.server.SendText(stranger.ID, stranger, "/start"))
+	if message, _ := h.server.LastBotMessage(stranger.ID); message.MessageID != last.MessageID {
+		t.Fatalf("stranger got %q", message.Text)
+	}
+
+	h.press("Назад")
+	h.press("Создать ссылку")
+	token = shareToken(t, h.lastMessage())
+	h.press("Назад")
+	h.press("Отозвать ссылки (1)")
+	h.expectText("Активных ссылок: 0")
+
+	if view := open(); !strings.Contains(view.Text, "недействительна") {
+		t.Fatalf("revoked link shows %q", view.Text)
+	}
+
+	events, _, err := controllers.GetAuditEvents(h.repos.Audit, h.user.ID, false, 0, 20)
+	if err != nil {
+		t.Fatalf("get audit events: %v", err)
+	}
+
+	logged := map[string]bool{}
+	for _, event := range events {
+		logged[event.EventType] = true
+	}
+	for _, eventType := range []string{controllers.AuditShareCreate, controllers.AuditShareView, controllers.AuditShareRevoke} {
+		if !logged[eventType] {
+			t.Errorf("audit event %q was not logged", eventType)
+		}
+	}
+}
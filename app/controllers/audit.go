@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"main/database/models"
+	"main/repository"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	AuditLogin          = "login"
+	AuditLoginFailed    = "login_failed"
+	AuditSecretView     = "secret_view"
+	AuditSecretCreate   = "secret_create"
+	AuditSecretEdit     = "secret_edit"
+	AuditSecretDelete   = "secret_delete"
+	AuditExport         = "export"
+	AuditSessionExpired = "session_expired"
+	AuditSessionRevoked = "session_revoked"
+	AuditLock           = "lock"
+	AuditWipe           = "wipe"
+	AuditRestore        = "restore"
+	AuditImport         = "import"
+	AuditPlainExport    = "export_plain"
+	AuditShareCreate    = "share_create"
+	AuditShareView      = "share_view"
+	AuditShareRevoke    = "share_revoke"
+
+	DEFAULT_AUDIT_HEAD_PATH = "audit_head.json"
+)
+
+var ErrNoAuditKey = errors.New("AUDIT_HMAC_KEY is not set")
+
+// auditHeadMu не дает записать в файл голову старше уже записанной
+var auditHeadMu sync.Mutex
+
+// AuditHead - последняя запись журнала. Она хранится в файле вне базы: по базе не отличить
+// журнал, из которого удалили последние записи, от журнала, в котором их не было.
+type AuditHead struct {
+	ID   int64  `json:"id"`
+	Hash string `json:"hash"`
+}
+
+// AuditEntry описывает событие для журнала
+type AuditEntry struct {
+	EventType string
+	UserID    int64
+	ChatID    int64
+	SecretID  int64
+	IsDuress  bool
+}
+
+// AuditKey возвращает ключ HMAC журнала из AUDIT_HMAC_KEY. Ключ хранится вне базы,
+// иначе тот, кто может писать в базу, изменил бы запись и пересчитал хеши всех следующих.
+func AuditKey() ([]byte, error) {
+	key := os.Getenv("AUDIT_HMAC_KEY")
+	if key == "" {
+		return nil, ErrNoAuditKey
+	}
+
+	return []byte(key), nil
+}
+
+func auditHeadPath() string {
+	if path := os.Getenv("AUDIT_HEAD_PATH"); path != "" {
+		return path
+	}
+
+	return DEFAULT_AUDIT_HEAD_PATH
+}
+
+// ReadAuditHead возвращает записанную голову журнала или nil, если в журнал еще ничего не писали
+func ReadAuditHead() (*AuditHead, error) {
+	data, err := os.ReadFile(auditHeadPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	head := &AuditHead{}
+	err = json.Unmarshal(data, head)
+	if err != nil {
+		return nil, err
+	}
+
+	return head, nil
+}
+
+// saveAuditHead заменяет файл головы целиком, чтобы при сбое не остался наполовину записанный файл
+func saveAuditHead(head AuditHead) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+
+	path := auditHeadPath()
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func auditFields(event *models.AuditEvents) string {
+	return fmt.Sprintf(
+		"%s|%d|%s|%d|%d|%d|%t",
+		event.PrevHash,
+		event.CreatedAt,
+		event.EventType,
+		event.UserID,
+		event.ChatID,
+		event.SecretID,
+		event.IsDuress,
+	)
+}
+
+// auditHash считает HMAC записи вместе с хешем предыдущей
+func auditHash(event *models.AuditEvents, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(auditFields(event)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LogAuditEvent добавляет событие в конец цепочки журнала и записывает новую голову
+func LogAuditEvent(repo repository.AuditRepo, entry AuditEntry) error {
+	key, err := AuditKey()
+	if err != nil {
+		return err
+	}
+
+	auditHeadMu.Lock()
+	defer auditHeadMu.Unlock()
+
+	var appended *models.AuditEvents
+	err = repo.Append(func(prevHash string) *models.AuditEvents {
+		event := &models.AuditEvents{
+			CreatedAt: time.Now().Unix(),
+			EventType: entry.EventType,
+			UserID:    entry.UserID,
+			ChatID:    entry.ChatID,
+			SecretID:  entry.SecretID,
+			IsDuress:  entry.IsDuress,
+			PrevHash:  prevHash,
+		}
+		event.Hash = auditHash(event, key)
+		appended = event
+
+		return event
+	})
+	if err != nil {
+		return err
+	}
+
+	return saveAuditHead(AuditHead{ID: appended.ID, Hash: appended.Hash})
+}
+
+// Audit пишет событие в журнал. Ошибка журнала не должна мешать работе с хранилищем, поэтому она только логируется.
+func Audit(repo repository.AuditRepo, entry AuditEntry) {
+	if err := LogAuditEvent(repo, entry); err != nil {
+		log.Printf("Failed to write audit event %s: %v", entry.EventType, err)
+	}
+}
+
+// GetAuditEvents возвращает страницу событий пользователя, начиная с новых
+func GetAuditEvents(repo repository.AuditRepo, userID int64, isDuress bool, offset, limit int) ([]models.AuditEvents, int, error) {
+	return repo.ListByUser(userID, isDuress, offset, limit)
+}
+
+// GetAllAuditEvents возвращает весь журнал в порядке записи
+func GetAllAuditEvents(repo repository.AuditRepo) ([]models.AuditEvents, error) {
+	return repo.ListAll()
+}
+
+// VerifyAuditChain проверяет цепочку хешей и возвращает ID первой испорченной записи или 0.
+// truncated означает, что в журнале нет головы head или она изменена, то есть последние записи удалены.
+func VerifyAuditChain(events []models.AuditEvents, key []byte, head *AuditHead) (brokenID int64, truncated bool) {
+	prevHash := ""
+	for _, event := range events {
+		if event.PrevHash != prevHash || !hmac.Equal([]byte(auditHash(&event, key)), []byte(event.Hash)) {
+			return event.ID, false
+		}
+
+		prevHash = event.Hash
+	}
+
+	if head != nil {
+		i := slices.IndexFunc(events, func(event models.AuditEvents) bool { return event.ID == head.ID })
+		if i == -1 || events[i].Hash != head.Hash {
+			return 0, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"main/database/models"
+	"testing"
+)
+
+var testAuditKey = []byte("test audit key")
+
+// auditChain строит цепочку из n записей
+func auditChain(n int) []models.AuditEvents {
+	events := make([]models.AuditEvents, n)
+	prevHash := ""
+	for i := range events {
+		event := &events[i]
+		event.ID = int64(i + 1)
+		event.CreatedAt = int64(1000 + i)
+		event.EventType = AuditLogin
+		event.UserID = 1
+		event.ChatID = 1
+		event.PrevHash = prevHash
+		event.Hash = auditHash(event, testAuditKey)
+
+		prevHash = event.Hash
+	}
+
+	return events
+}
+
+func headOf(events []models.AuditEvents) *AuditHead {
+	last := events[len(events)-1]
+
+	return &AuditHead{ID: last.ID, Hash: last.Hash}
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	events := auditChain(4)
+	if brokenID, truncated := VerifyAuditChain(events, testAuditKey, headOf(events)); brokenID != 0 || truncated {
+		t.Fatalf("valid chain: broken %d, truncated %t", brokenID, truncated)
+	}
+
+	edited := auditChain(4)
+	edited[1].EventType = AuditExport
+	if brokenID, _ := VerifyAuditChain(edited, testAuditKey, headOf(events)); brokenID != 2 {
+		t.Fatalf("edited row: broken %d, want 2", brokenID)
+	}
+
+	// Без ключа цепочку нельзя пересчитать после изменения записи
+	forged := auditChain(4)
+	forged[1].EventType = AuditExport
+	for i := 1; i < len(forged); i++ {
+		forged[i].PrevHash = forged[i-1].Hash
+		forged[i].Hash = auditHash(&forged[i], []byte("other key"))
+	}
+	if brokenID, _ := VerifyAuditChain(forged, testAuditKey, headOf(forged)); brokenID != 2 {
+		t.Fatalf("chain recomputed without the key: broken %d, want 2", brokenID)
+	}
+
+	if brokenID, truncated := VerifyAuditChain(events[:3], testAuditKey, headOf(events)); brokenID != 0 || !truncated {
+		t.Fatalf("truncated tail: broken %d, truncated %t", brokenID, truncated)
+	}
+	if _, truncated := VerifyAuditChain(nil, testAuditKey, headOf(events)); !truncated {
+		t.Fatal("emptied log is not reported as truncated")
+	}
+
+	if brokenID, truncated := VerifyAuditChain(events[:3], testAuditKey, nil); brokenID != 0 || truncated {
+		t.Fatalf("chain without a recorded head: broken %d, truncated %t", brokenID, truncated)
+	}
+}
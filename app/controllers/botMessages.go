@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"main/database/models"
+	"main/repository"
+	"main/telegram"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram не дает удалять сообщения бота старше 48 часов, дольше хранить их нет смысла
+const TrackedMessageTTL = 48 * 60 * 60
+
+// SendTracked отправляет сообщение и запоминает его, чтобы потом его можно было убрать из чата
+func SendTracked(client telegram.Messenger, repo repository.BotMessageRepo, msg tgbotapi.Chattable, userID int64) (tgbotapi.Message, error) {
+	sent, err := client.Send(msg)
+	if err != nil {
+		return sent, err
+	}
+
+	err = TrackMessage(repo, sent.Chat.ID, sent.MessageID, userID)
+
+	return sent, err
+}
+
+func TrackMessage(repo repository.BotMessageRepo, chatID int64, messageID int, userID int64) error {
+	return repo.Create(&models.BotMessages{
+		ChatID:    chatID,
+		MessageID: messageID,
+		UserID:    userID,
+	})
+}
+
+// GetTrackedMessages возвращает запомненные сообщения бота в чате
+func GetTrackedMessages(repo repository.BotMessageRepo, chatID int64) ([]models.BotMessages, error) {
+	return repo.ListByChat(chatID)
+}
+
+func ForgetTrackedMessages(repo repository.BotMessageRepo, chatID int64) error {
+	return repo.DeleteByChat(chatID)
+}
+
+// DeleteTrackedMessages удаляет из чата все запомненные сообщения бота и возвращает их количество
+func DeleteTrackedMessages(client telegram.Messenger, repo repository.BotMessageRepo, chatID int64) (int, error) {
+	messages, err := GetTrackedMessages(repo, chatID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range messages {
+		client.Request(tgbotapi.NewDeleteMessage(m.ChatID, m.MessageID))
+	}
+
+	return len(messages), ForgetTrackedMessages(repo, chatID)
+}
+
+// EditTrackedMessages заменяет текст всех запомненных сообщений бота в чате и убирает у них кнопки
+func EditTrackedMessages(client telegram.Messenger, repo repository.BotMessageRepo, chatID int64, text string) error {
+	messages, err := GetTrackedMessages(repo, chatID)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range messages {
+		client.Request(tgbotapi.NewEditMessageText(m.ChatID, m.MessageID, text))
+	}
+
+	return nil
+}
+
+func PruneTrackedMessages(repo repository.BotMessageRepo) error {
+	return repo.DeleteCreatedBefore(time.Now().Unix() - TrackedMessageTTL)
+}
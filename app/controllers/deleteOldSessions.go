@@ -1,14 +1,91 @@
 package controllers
 
 import (
-	"main/database"
-	"main/database/models"
+	"encoding/json"
+	"log"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"main/util"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	SessionWarningInterval = 30 // За сколько секунд до окончания сессии предупреждать пользователя
+
+	SessionExpiringText = "Сессия скоро завершится из-за неактивности."
+	SessionExpiredText  = "Сессия истекла.\n\nЧтобы продолжить работу, отправьте /start."
 )
 
-func DeleteOldSessions() error {
-	_, err := database.GetDB().Model(&models.Sessions{}).
-		Where("updated_at + reset_time_interval < extract(epoch from now())").
-		Delete()
+// WarnExpiringSessions предупреждает о сессиях, которые скоро закончатся, и предлагает их продлить
+func WarnExpiringSessions(client telegram.Messenger, repos repository.Repos) error {
+	sessions, err := repos.Sessions.ListExpiring(SessionWarningInterval)
+	if err != nil {
+		return err
+	}
+
+	extendDataJSON, err := json.Marshal(map[string]any{"a": "x"}) // action: extend
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		settings, err := GetUserSettings(repos.Settings, session.UserID)
+		if err != nil {
+			return err
+		}
+
+		msg := tgbotapi.NewMessage(session.ChatID, i18n.T(settings.Language, SessionExpiringText))
+		msg.ReplyMarkup = tgbotapi.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{
+				{Text: i18n.T(settings.Language, "Продлить"), CallbackData: util.StringPtr(string(extendDataJSON))},
+			}},
+		}
+
+		sent, err := SendTracked(client, repos.BotMessages, msg, session.UserID)
+		if err != nil {
+			log.Printf("Failed to warn about session %d expiry: %v", session.ID, err)
+
+			continue
+		}
+
+		session.WarningMessageID = sent.MessageID
+		err = repos.Sessions.Update(&session, "warning_message_id")
+		if err != nil {
+			return err
+		}
+
+		// Если сессию продлят, предупреждение уберется само. Если нет - его раньше заменит DeleteOldSessions.
+		err = ScheduleMessageExpiry(repos.ExpiringMessages, session.ChatID, sent.MessageID, session.UserID, 2*SessionWarningInterval, ExpireActionDelete)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteOldSessions удаляет истекшие сессии и помечает открытые сообщения бота как устаревшие
+func DeleteOldSessions(client telegram.Messenger, repos repository.Repos) error {
+	sessions, err := repos.Sessions.DeleteExpired()
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		Audit(repos.Audit, AuditEntry{
+			EventType: AuditSessionExpired,
+			UserID:    session.UserID,
+			ChatID:    session.ChatID,
+			IsDuress:  session.IsDuress,
+		})
+
+		err = closeSessionMessages(client, repos, &session, SessionExpiredText)
+		if err != nil {
+			return err
+		}
+	}
 
-	return err
+	return nil
 }
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"log"
+	"main/database/models"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	ExpireActionHide   = "hide"
+	ExpireActionDelete = "delete"
+
+	HiddenMessageText = "Данные скрыты по истечении времени.\n\nЧтобы посмотреть их снова, откройте хранилище через /start."
+)
+
+// ScheduleMessageExpiry запоминает, что сообщение нужно скрыть или удалить через timeout секунд
+func ScheduleMessageExpiry(repo repository.ExpiringMessageRepo, chatID int64, messageID int, userID int64, timeout int64, action string) error {
+	err := CancelMessageExpiry(repo, chatID, messageID)
+	if err != nil {
+		return err
+	}
+
+	return repo.Create(&models.ExpiringMessages{
+		ChatID:    chatID,
+		MessageID: messageID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Unix() + timeout,
+		Action:    action,
+	})
+}
+
+// CancelMessageExpiry снимает сообщение с учета, например когда в нем больше нет расшифрованных данных
+func CancelMessageExpiry(repo repository.ExpiringMessageRepo, chatID int64, messageID int) error {
+	return repo.DeleteByMessage(chatID, messageID)
+}
+
+// ExpireMessages скрывает или удаляет все сообщения, время жизни которых истекло
+func ExpireMessages(client telegram.Messenger, repos repository.Repos) error {
+	messages, err := repos.ExpiringMessages.ListDue(time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	for _, m := range messages {
+		var err error
+
+		switch m.Action {
+		case ExpireActionDelete:
+			_, err = client.Request(tgbotapi.NewDeleteMessage(m.ChatID, m.MessageID))
+		default:
+			text := HiddenMessageText
+			if settings, err := GetUserSettings(repos.Settings, m.UserID); err == nil {
+				text = i18n.T(settings.Language, text)
+			}
+
+			_, err = client.Request(tgbotapi.NewEditMessageText(m.ChatID, m.MessageID, text))
+		}
+
+		// Сообщение могли удалить вручную, повторять попытку бессмысленно
+		if err != nil {
+			log.Printf("Failed to expire message %d in chat %d: %v", m.MessageID, m.ChatID, err)
+		}
+
+		err = repos.ExpiringMessages.Delete(m.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"main/database/models"
+	"main/ratelimit"
+	"main/repository"
+	"time"
+)
+
+const (
+	LoginMaxFailures     = 5                // После стольких ошибок подряд вход блокируется
+	LoginLockoutDuration = 15 * time.Minute // Длительность первой блокировки, дальше удваивается
+	LoginMaxLockout      = 24 * time.Hour
+
+	PasswordAttemptsRate  = 0.2 // Ввод пароля или кода: один раз в 5 секунд
+	PasswordAttemptsBurst = 3
+)
+
+// PasswordLimiter ограничивает частоту ввода паролей и кодов независимо от того, верны ли они
+var PasswordLimiter = ratelimit.NewLimiter(PasswordAttemptsRate, PasswordAttemptsBurst)
+
+// loginDelay возвращает паузу, которую нужно выдержать после failures неудачных попыток
+func loginDelay(failures int64) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+
+	var delay time.Duration
+	if failures < LoginMaxFailures {
+		delay = time.Second << (failures - 1)
+	} else {
+		delay = LoginLockoutDuration << (failures - LoginMaxFailures)
+	}
+
+	if delay <= 0 || delay > LoginMaxLockout {
+		delay = LoginMaxLockout
+	}
+
+	return delay
+}
+
+func getLoginAttempts(repo repository.LoginAttemptRepo, telegramID int64) (*models.LoginAttempts, error) {
+	attempts, err := repo.Get(telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &models.LoginAttempts{TelegramID: telegramID}, nil
+	}
+
+	return attempts, err
+}
+
+// LoginWaitTime возвращает, сколько еще нужно ждать до следующей попытки входа (0 - можно пробовать)
+func LoginWaitTime(repo repository.LoginAttemptRepo, telegramID int64) (time.Duration, error) {
+	attempts, err := getLoginAttempts(repo, telegramID)
+	if err != nil {
+		return 0, err
+	}
+
+	wait := time.Until(time.Unix(attempts.BlockedUntil, 0))
+	if wait < 0 {
+		return 0, nil
+	}
+
+	return wait.Round(time.Second), nil
+}
+
+// RegisterFailedLogin учитывает неудачную попытку входа.
+// Возвращает время ожидания до следующей попытки и флаг, что пользователь только что заблокирован.
+func RegisterFailedLogin(repo repository.LoginAttemptRepo, telegramID int64) (time.Duration, bool, error) {
+	attempts, err := getLoginAttempts(repo, telegramID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	now := time.Now()
+	attempts.Failures++
+	attempts.LastFailureAt = now.Unix()
+	attempts.UpdatedAt = now.Unix()
+
+	delay := loginDelay(attempts.Failures)
+	attempts.BlockedUntil = now.Add(delay).Unix()
+
+	err = repo.Save(attempts)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return delay, attempts.Failures >= LoginMaxFailures, nil
+}
+
+// ResetLoginAttempts сбрасывает счетчик после успешного входа
+func ResetLoginAttempts(repo repository.LoginAttemptRepo, telegramID int64) error {
+	return repo.Delete(telegramID)
+}
@@ -3,6 +3,8 @@ package controllers
 import (
 	"errors"
 	"log"
+	"main/telegram"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -21,7 +23,7 @@ type NextStepKey struct {
 	UserID int64
 }
 
-type NextStepFunc func(client tgbotapi.BotAPI, stepUpdate tgbotapi.Update, stepParams map[string]any) error
+type NextStepFunc func(client telegram.Messenger, stepUpdate tgbotapi.Update, stepParams map[string]any) error
 
 type NextStepAction struct {
 	Func          NextStepFunc
@@ -31,7 +33,9 @@ type NextStepAction struct {
 	IsLastStep    bool // Флаг, указывающий, является ли этот шаг последним в цепочке
 }
 
+// Обновления обрабатываются параллельно, поэтому доступ к шагам защищен мьютексом
 type NextStepManager struct {
+	mu              sync.Mutex
 	nextStepActions map[NextStepKey]NextStepAction
 }
 
@@ -48,40 +52,51 @@ func GetNextStepManager() *NextStepManager {
 func (n *NextStepManager) RegisterNextStepAction(stepKey NextStepKey, action NextStepAction) {
 	log.Printf("RegisterNextStepAction: Registering new action for ChatID=%d, UserID=%d\n", stepKey.ChatID, stepKey.UserID)
 	log.Printf("RegisterNextStepAction: Action details: CreatedAtTS=%d, CancelMessage=%s\n", action.CreatedAtTS, action.CancelMessage)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	n.nextStepActions[stepKey] = action
-	log.Printf("RegisterNextStepAction: Current actions map after registration: %+v\n", n.nextStepActions)
+	// Параметры шагов не пишутся в лог: в них бывают мастер-пароль и пароли файлов
+	log.Printf("RegisterNextStepAction: Waiting steps after registration: %d\n", len(n.nextStepActions))
 }
 
-func (n *NextStepManager) RemoveNextStepAction(stepKey NextStepKey, bot tgbotapi.BotAPI, sendCancelMessage bool) {
+func (n *NextStepManager) RemoveNextStepAction(stepKey NextStepKey, bot telegram.Messenger, sendCancelMessage bool) {
 	log.Printf("RemoveNextStepAction: Removing action for ChatID=%d, UserID=%d\n", stepKey.ChatID, stepKey.UserID)
-	log.Printf("RemoveNextStepAction: Current actions before removal: %+v\n", n.nextStepActions)
-
-	if sendCancelMessage && n.nextStepActions[stepKey].CancelMessage != "" {
-		log.Printf("RemoveNextStepAction: Sending cancel message: %s\n", n.nextStepActions[stepKey].CancelMessage)
-		bot.Send(tgbotapi.NewMessage(stepKey.ChatID, n.nextStepActions[stepKey].CancelMessage))
-	}
 
+	n.mu.Lock()
+	cancelMessage := n.nextStepActions[stepKey].CancelMessage
 	delete(n.nextStepActions, stepKey)
-	log.Printf("RemoveNextStepAction: Actions after removal: %+v\n", n.nextStepActions)
+	log.Printf("RemoveNextStepAction: Waiting steps after removal: %d\n", len(n.nextStepActions))
+	n.mu.Unlock()
+
+	if sendCancelMessage && cancelMessage != "" {
+		log.Printf("RemoveNextStepAction: Sending cancel message: %s\n", cancelMessage)
+		bot.Send(tgbotapi.NewMessage(stepKey.ChatID, cancelMessage))
+	}
 }
 
-func (n *NextStepManager) RunUpdates(update tgbotapi.Update, client tgbotapi.BotAPI) error {
+// RunUpdates передает сообщение ожидающему его шагу диалога и сообщает, был ли такой шаг
+func (n *NextStepManager) RunUpdates(update tgbotapi.Update, client telegram.Messenger) (bool, error) {
 	if update.Message == nil {
 		log.Println("RunUpdates: Message is nil")
-		return nil
+		return false, nil
 	}
 
 	key := NextStepKey{ChatID: update.Message.Chat.ID, UserID: update.Message.From.ID}
 	log.Printf("RunUpdates: Processing key ChatID=%d, UserID=%d\n", key.ChatID, key.UserID)
-	log.Printf("RunUpdates: Current actions map: %+v\n", n.nextStepActions)
+
+	// Сам шаг выполняется без блокировки: он может зарегистрировать или удалить следующий шаг
+	n.mu.Lock()
 	action, ok := n.nextStepActions[key]
+	n.mu.Unlock()
 
 	if !ok {
-		return nil
+		return false, nil
 	}
 
 	if update.Message.IsCommand() {
-		return ErrMessageIsCommand
+		return false, ErrMessageIsCommand
 	}
 
 	err := action.Func(client, update, action.Params)
@@ -91,36 +106,59 @@ func (n *NextStepManager) RunUpdates(update tgbotapi.Update, client tgbotapi.Bot
 		GlobalNextStepManager.RemoveNextStepAction(key, client, false)
 	}
 
-	return err
+	return true, err
+}
+
+// RemoveUserSteps удаляет шаги пользователя во всех чатах, например после удаления его данных
+func (n *NextStepManager) RemoveUserSteps(userID int64, client telegram.Messenger, sendCancelMessage bool) {
+	n.mu.Lock()
+	keys := []NextStepKey{}
+	for key := range n.nextStepActions {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, key := range keys {
+		n.RemoveNextStepAction(key, client, sendCancelMessage)
+	}
 }
 
-func (n *NextStepManager) ClearOldSteps(client tgbotapi.BotAPI) (int, error) {
+func (n *NextStepManager) ClearOldSteps(client telegram.Messenger) (int, error) {
 	now := time.Now().Unix()
-	deleted := 0
 
+	n.mu.Lock()
+	expired := []NextStepKey{}
 	for key, action := range n.nextStepActions {
 		if now-action.CreatedAtTS > StepTimeout {
-			n.RemoveNextStepAction(key, client, true)
-			deleted++
+			expired = append(expired, key)
 		}
 	}
+	n.mu.Unlock()
+
+	for _, key := range expired {
+		n.RemoveNextStepAction(key, client, true)
+	}
 
-	return deleted, nil
+	return len(expired), nil
 }
 
-func RunStepUpdates(update tgbotapi.Update, stepManager *NextStepManager, client tgbotapi.BotAPI) {
-	err := stepManager.RunUpdates(update, client)
+func RunStepUpdates(update tgbotapi.Update, stepManager *NextStepManager, client telegram.Messenger) bool {
+	handled, err := stepManager.RunUpdates(update, client)
 
 	if err != nil {
 		log.Println("error running step updates: ", err)
 	}
+
+	return handled
 }
 
 // ClearNextStepForUser очищает следующий шаг для пользователя
 // update - обновление от Telegram API
 // client - экземпляр Telegram бота
 // sendCancelMessage - флаг, указывающий, нужно ли отправлять сообщение об отмене
-func ClearNextStepForUser(update tgbotapi.Update, client *tgbotapi.BotAPI, sendCancelMessage bool) {
+func ClearNextStepForUser(update tgbotapi.Update, client telegram.Messenger, sendCancelMessage bool) {
 	var user *tgbotapi.User
 	var chat *tgbotapi.Chat
 
@@ -143,5 +181,5 @@ func ClearNextStepForUser(update tgbotapi.Update, client *tgbotapi.BotAPI, sendC
 	GetNextStepManager().RemoveNextStepAction(NextStepKey{
 		ChatID: chat.ID,
 		UserID: user.ID,
-	}, *client, sendCancelMessage)
+	}, client, sendCancelMessage)
 }
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"main/database/models"
+	"main/i18n"
+	"main/repository"
+	"main/telegram"
+)
+
+const SessionRevokedText = "Сессия завершена с другого устройства.\n\nЧтобы продолжить работу, отправьте /start."
+
+// RevokeSession завершает сессию пользователя и убирает расшифрованные данные из ее чата
+func RevokeSession(client telegram.Messenger, repos repository.Repos, userID, sessionID int64) error {
+	sessions, err := repos.Sessions.Delete(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		Audit(repos.Audit, AuditEntry{
+			EventType: AuditSessionRevoked,
+			UserID:    session.UserID,
+			ChatID:    session.ChatID,
+			IsDuress:  session.IsDuress,
+		})
+
+		err = closeSessionMessages(client, repos, &session, SessionRevokedText)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// closeSessionMessages заменяет текст сообщений бота в чате завершенной сессии
+func closeSessionMessages(client telegram.Messenger, repos repository.Repos, session *models.Sessions, text string) error {
+	if session.ChatID == 0 {
+		return nil
+	}
+
+	settings, err := GetUserSettings(repos.Settings, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = EditTrackedMessages(client, repos.BotMessages, session.ChatID, i18n.T(settings.Language, text))
+	if err != nil {
+		return err
+	}
+
+	// Сообщения уже заменены, скрывать или удалять их больше не нужно
+	return repos.ExpiringMessages.DeleteByChat(session.ChatID)
+}
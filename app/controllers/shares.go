@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"main/crypto"
+	"main/repository"
+	"time"
+)
+
+// SharePrefix - начало параметра /start в ссылке на секрет: t.me/<бот>?start=share_<токен>
+const SharePrefix = "share_"
+
+var (
+	ShareViewLimits = []int{1, 3, 5}
+	ShareTTLs       = []int64{3600, 24 * 3600, 7 * 24 * 3600}
+)
+
+// NewShareToken возвращает случайный ключ ссылки и токен, под которым он передается в ссылке.
+// Токен занимает 43 символа и укладывается в ограничение Telegram на параметр /start.
+func NewShareToken() (string, []byte) {
+	key := crypto.NewKey()
+
+	return base64.RawURLEncoding.EncodeToString(key), key
+}
+
+// ShareKey достает ключ из токена ссылки
+func ShareKey(token string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(key) != crypto.KeySize {
+		return nil, crypto.ErrWrongKey
+	}
+
+	return key, nil
+}
+
+// ShareTokenHash - по этому хешу ссылка ищется в базе, сам токен не сохраняется
+func ShareTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func PruneShares(repo repository.ShareRepo) error {
+	return repo.DeleteExpired(time.Now().Unix())
+}
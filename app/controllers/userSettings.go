@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+	"main/database/models"
+	"main/i18n"
+	"main/repository"
+	"time"
+)
+
+const (
+	DefaultRevealTimeout  = 60
+	DefaultSessionTimeout = 1000
+	DefaultPageSize       = 6
+
+	DisplayModeCode    = "code"    // Пароль моноширинным текстом
+	DisplayModeSpoiler = "spoiler" // Пароль под спойлером
+	DisplayModeMasked  = "masked"  // Пароль скрыт маской и показывается по кнопке на несколько секунд
+
+	SortOrderOld   = repository.SortOrderOld
+	SortOrderNew   = repository.SortOrderNew
+	SortOrderTitle = repository.SortOrderTitle
+)
+
+var (
+	DisplayModes    = []string{DisplayModeCode, DisplayModeSpoiler, DisplayModeMasked}
+	SortOrders      = []string{SortOrderOld, SortOrderNew, SortOrderTitle}
+	RevealTimeouts  = []int64{0, 30, DefaultRevealTimeout, 120, 300}
+	SessionTimeouts = []int64{300, 600, DefaultSessionTimeout, 1800, 3600}
+	PageSizes       = []int{4, DefaultPageSize, 8, 10, 20}
+)
+
+// applyDefaults заполняет параметры, которые пользователь еще не менял
+func applyDefaults(settings *models.UserSettings) {
+	if settings.DisplayMode == "" {
+		settings.DisplayMode = DisplayModeCode
+	}
+	if settings.SessionTimeout <= 0 {
+		settings.SessionTimeout = DefaultSessionTimeout
+	}
+	if settings.PageSize <= 0 {
+		settings.PageSize = DefaultPageSize
+	}
+	if settings.SortOrder == "" {
+		settings.SortOrder = SortOrderOld
+	}
+	if settings.Language == "" {
+		settings.Language = i18n.LangRu
+	}
+}
+
+// GetUserSettings возвращает настройки пользователя или значения по умолчанию, если он их не менял
+func GetUserSettings(repo repository.SettingsRepo, telegramID int64) (*models.UserSettings, error) {
+	settings, err := repo.Get(telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		settings = &models.UserSettings{
+			TelegramID:    telegramID,
+			RevealTimeout: DefaultRevealTimeout,
+		}
+		err = nil
+	}
+
+	applyDefaults(settings)
+
+	return settings, err
+}
+
+func SaveUserSettings(repo repository.SettingsRepo, settings *models.UserSettings) error {
+	settings.UpdatedAt = time.Now().Unix()
+
+	return repo.Save(settings)
+}
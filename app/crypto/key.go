@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+)
+
+// KeySize - длина случайного ключа AES-256. Такой ключ не нужно растягивать через KDF, в отличие от пароля.
+const KeySize = 32
+
+var ErrWrongKey = errors.New("wrong key or damaged data")
+
+// NewKey возвращает случайный ключ для EncryptWithKey
+func NewKey() []byte {
+	return random(KeySize)
+}
+
+// EncryptWithKey шифрует plaintext ключом key через AES-256-GCM. Nonce записывается перед шифротекстом.
+func EncryptWithKey(plaintext, key []byte) ([]byte, error) {
+	aead, err := keyAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := random(aead.NonceSize())
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// DecryptWithKey расшифровывает результат EncryptWithKey. Неверный ключ или измененные данные дают ErrWrongKey.
+func DecryptWithKey(data, key []byte) ([]byte, error) {
+	aead, err := keyAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, ErrWrongKey
+	}
+
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+
+	return plaintext, nil
+}
+
+func keyAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrWrongKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,32 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncryptWithKey(t *testing.T) {
+	key := NewKey()
+
+	data, err := EncryptWithKey([]byte("secret"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := DecryptWithKey(data, key)
+	if err != nil || string(plaintext) != "secret" {
+		t.Fatalf("DecryptWithKey = %q, %v", plaintext, err)
+	}
+
+	if _, err := DecryptWithKey(data, NewKey()); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("DecryptWithKey with another key = %v, want ErrWrongKey", err)
+	}
+	if _, err := DecryptWithKey(data[:5], key); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("DecryptWithKey of truncated data = %v, want ErrWrongKey", err)
+	}
+
+	data[len(data)-1] ^= 1
+	if _, err := DecryptWithKey(data, key); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("DecryptWithKey of damaged data = %v, want ErrWrongKey", err)
+	}
+}
@@ -0,0 +1,100 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	KDFArgon2id = "argon2id"
+
+	// Параметры Argon2id для новых контейнеров, рекомендованные RFC 9106 для ограниченной памяти
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024 // КиБ
+	argon2Threads = 4
+
+	// Контейнер может прийти от кого угодно, поэтому параметры из него ограничены сверху
+	maxArgon2Time    = 10
+	maxArgon2Memory  = 256 * 1024
+	maxArgon2Threads = 16
+
+	sealedSaltSize = 16
+	sealedKeySize  = 32
+)
+
+var ErrWrongPassphrase = errors.New("wrong passphrase or damaged data")
+
+// Sealed - данные, зашифрованные AES-256-GCM ключом, выведенным из фразы-пароля через Argon2id.
+// Параметры KDF хранятся вместе с данными, чтобы их можно было поменять, не ломая старые контейнеры.
+type Sealed struct {
+	KDF     string `json:"kdf"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Seal шифрует plaintext фразой passphrase. additionalData не шифруется, но защищена от подмены:
+// открыть контейнер можно только с теми же additionalData.
+func Seal(plaintext []byte, passphrase string, additionalData []byte) (*Sealed, error) {
+	sealed := &Sealed{
+		KDF:     KDFArgon2id,
+		Time:    argon2Time,
+		Memory:  argon2Memory,
+		Threads: argon2Threads,
+		Salt:    random(sealedSaltSize),
+	}
+
+	aead, err := sealed.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed.Nonce = random(aead.NonceSize())
+	sealed.Data = aead.Seal(nil, sealed.Nonce, plaintext, additionalData)
+
+	return sealed, nil
+}
+
+// Open расшифровывает контейнер. Неверная фраза и поврежденные данные дают ErrWrongPassphrase.
+func (s *Sealed) Open(passphrase string, additionalData []byte) ([]byte, error) {
+	if s.KDF != KDFArgon2id {
+		return nil, fmt.Errorf("unsupported kdf %q", s.KDF)
+	}
+	if s.Time == 0 || s.Time > maxArgon2Time || s.Memory == 0 || s.Memory > maxArgon2Memory ||
+		s.Threads == 0 || s.Threads > maxArgon2Threads || len(s.Salt) < sealedSaltSize {
+		return nil, errors.New("kdf parameters are out of range")
+	}
+
+	aead, err := s.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+
+	plaintext, err := aead.Open(nil, s.Nonce, s.Data, additionalData)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return plaintext, nil
+}
+
+func (s *Sealed) aead(passphrase string) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), s.Salt, s.Time, s.Memory, s.Threads, sealedKeySize)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
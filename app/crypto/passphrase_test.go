@@ -0,0 +1,42 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	sealed, err := Seal([]byte("vault"), "long passphrase", []byte("header"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := sealed.Open("long passphrase", []byte("header"))
+	if err != nil || string(plaintext) != "vault" {
+		t.Fatalf("Open = %q, %v", plaintext, err)
+	}
+
+	if _, err := sealed.Open("other passphrase", []byte("header")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Open with a wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := sealed.Open("long passphrase", []byte("other header")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Open with other additional data = %v, want ErrWrongPassphrase", err)
+	}
+
+	sealed.Data[0] ^= 1
+	if _, err := sealed.Open("long passphrase", []byte("header")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Open of damaged data = %v, want ErrWrongPassphrase", err)
+	}
+}
+
+func TestOpenRejectsExpensiveKDF(t *testing.T) {
+	sealed, err := Seal([]byte("vault"), "long passphrase", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed.Memory = 4 * 1024 * 1024
+	if _, err := sealed.Open("long passphrase", nil); err == nil || errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Open with 4 GiB of memory = %v, want a parameters error", err)
+	}
+}
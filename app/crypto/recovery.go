@@ -0,0 +1,92 @@
+package crypto
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// В коде восстановления всего 40 бит случайности, а пароль под принуждением выбирает человек,
+	// поэтому их хеш считается медленно и с солью.
+	// Параметры Argon2id - минимальные из рекомендаций OWASP: хеш проверяется при каждом входе.
+	recoveryArgon2Time   = 2
+	recoveryArgon2Memory = 19 * 1024 // КиБ
+
+	recoveryHashPrefix = KDFArgon2id + "$"
+	recoveryCodeLength = 8
+	recoverySaltSize   = 16
+	recoveryKeySize    = 32
+)
+
+var recoveryEncoding = base64.RawStdEncoding
+
+// HashRecoveryCode возвращает хеш кода восстановления вида argon2id$<time>$<memory>$<соль>$<хеш>
+func HashRecoveryCode(code string) string {
+	return hashSalted(NormalizeRecoveryCode(code))
+}
+
+// CheckRecoveryCode сравнивает код с хешем HashRecoveryCode за постоянное время
+func CheckRecoveryCode(code, stored string) bool {
+	code = NormalizeRecoveryCode(code)
+	if len(code) != recoveryCodeLength {
+		return false
+	}
+
+	return checkSalted(code, stored)
+}
+
+// HashDuressPassword возвращает хеш пароля под принуждением в том же формате, что и HashRecoveryCode
+func HashDuressPassword(password string) string {
+	return hashSalted(password)
+}
+
+// CheckDuressPassword сравнивает пароль с хешем HashDuressPassword за постоянное время
+func CheckDuressPassword(password, stored string) bool {
+	return checkSalted(password, stored)
+}
+
+func hashSalted(value string) string {
+	salt := random(recoverySaltSize)
+	hash := argon2.IDKey([]byte(value), salt, recoveryArgon2Time, recoveryArgon2Memory, 1, recoveryKeySize)
+
+	return fmt.Sprintf("%s%d$%d$%s$%s", recoveryHashPrefix, recoveryArgon2Time, recoveryArgon2Memory,
+		recoveryEncoding.EncodeToString(salt), recoveryEncoding.EncodeToString(hash))
+}
+
+func checkSalted(value, stored string) bool {
+	encoded, ok := strings.CutPrefix(stored, recoveryHashPrefix)
+	if !ok {
+		return false
+	}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return false
+	}
+
+	time, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || time == 0 || time > maxArgon2Time {
+		return false
+	}
+	memory, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil || memory == 0 || memory > maxArgon2Memory {
+		return false
+	}
+	salt, err := recoveryEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	hash, err := recoveryEncoding.DecodeString(parts[3])
+	if err != nil || len(hash) == 0 {
+		return false
+	}
+
+	actual := argon2.IDKey([]byte(value), salt, uint32(time), uint32(memory), 1, uint32(len(hash)))
+
+	return subtle.ConstantTimeCompare(actual, hash) == 1
+}
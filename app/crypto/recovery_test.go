@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecoveryCodeHash(t *testing.T) {
+	code := GenerateRecoveryCodes(1)[0]
+
+	hash := HashRecoveryCode(code)
+	if strings.Contains(hash, NormalizeRecoveryCode(code)) || hash == HashRecoveryCode(code) {
+		t.Fatalf("hash %q is not salted", hash)
+	}
+
+	if !CheckRecoveryCode(strings.ToLower(code), hash) {
+		t.Fatal("code does not match its hash")
+	}
+	if CheckRecoveryCode("AAAA-AAAA", hash) {
+		t.Fatal("other code matches the hash")
+	}
+	damaged := hash[:len(hash)-4] + "AAAA"
+	if damaged == hash {
+		damaged = hash[:len(hash)-4] + "BBBB"
+	}
+	if CheckRecoveryCode(code, damaged) {
+		t.Fatal("code matches a damaged hash")
+	}
+
+	if CheckRecoveryCode(code, HashString(NormalizeRecoveryCode(code))) {
+		t.Fatal("code matches an unsalted hash")
+	}
+}
+
+func TestDuressPasswordHash(t *testing.T) {
+	hash := HashDuressPassword("duress password")
+	if hash == HashDuressPassword("duress password") {
+		t.Fatalf("hash %q is not salted", hash)
+	}
+
+	if !CheckDuressPassword("duress password", hash) {
+		t.Fatal("password does not match its hash")
+	}
+	if CheckDuressPassword("Duress password", hash) || CheckDuressPassword("", hash) {
+		t.Fatal("other password matches the hash")
+	}
+	if CheckDuressPassword("duress password", HashString("duress password")) {
+		t.Fatal("password matches an unsalted hash")
+	}
+}
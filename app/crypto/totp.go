@@ -0,0 +1,116 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30
+	TOTPSkew   = 1 // Сколько соседних интервалов принимаем из-за рассинхронизации часов
+
+	recoveryCodeCharset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret возвращает новый секрет в base32, пригодный для приложений-аутентификаторов
+func GenerateTOTPSecret() string {
+	return totpEncoding.EncodeToString(random(20))
+}
+
+// TOTPURI собирает otpauth:// ссылку для добавления секрета в приложение-аутентификатор
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(TOTPPeriod))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// TOTPCode вычисляет код (RFC 6238) для указанного момента времени
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(t.Unix()/TOTPPeriod))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+// ValidateTOTP проверяет код с учетом допустимого расхождения часов
+func ValidateTOTP(secret, code string, t time.Time) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return false
+	}
+
+	for i := -TOTPSkew; i <= TOTPSkew; i++ {
+		expected, err := TOTPCode(secret, t.Add(time.Duration(i*TOTPPeriod)*time.Second))
+		if err != nil {
+			return false
+		}
+
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GenerateRecoveryCodes возвращает одноразовые коды восстановления вида XXXX-XXXX
+func GenerateRecoveryCodes(count int) []string {
+	codes := make([]string, count)
+
+	for i := range codes {
+		randomBytes := random(8)
+		code := make([]byte, 0, 9)
+
+		for j, b := range randomBytes {
+			if j == 4 {
+				code = append(code, '-')
+			}
+			code = append(code, recoveryCodeCharset[int(b)%len(recoveryCodeCharset)])
+		}
+
+		codes[i] = string(code)
+	}
+
+	return codes
+}
+
+// NormalizeRecoveryCode приводит введенный код восстановления к виду, в котором хранится его хеш
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+
+	return code
+}
@@ -1,19 +1,45 @@
 package database
 
 import (
-	"main/database/models"
+	"database/sql"
+	"log"
+	"main/database/migrations"
+	"main/repository"
+	"net/url"
 	"os"
 	"sync"
 
 	"github.com/go-pg/pg/v10"
-	"github.com/go-pg/pg/v10/orm"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+
+	DEFAULT_SQLITE_PATH = "vault.db"
 )
 
 var (
 	db   *pg.DB
 	once sync.Once
+
+	sqliteDB   *sql.DB
+	sqliteOnce sync.Once
+
+	repos     repository.Repos
+	reposOnce sync.Once
 )
 
+// Driver возвращает базу, выбранную в DB_DRIVER: postgres (по умолчанию) или sqlite
+func Driver() string {
+	if os.Getenv("DB_DRIVER") == DriverSQLite {
+		return DriverSQLite
+	}
+
+	return DriverPostgres
+}
+
 // GetDB returns a singleton instance of the database connection
 func GetDB() *pg.DB {
 	once.Do(func() {
@@ -28,23 +54,83 @@ func GetDB() *pg.DB {
 	return db
 }
 
-func InitDb() error {
-	db := GetDB()
+// OpenSQLite открывает файл базы SQLite с настройками, которые нужны хранилищам
+func OpenSQLite(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	// Транзакции сразу берут блокировку записи, иначе журнал аудита может получить две записи с одним хешем
+	params.Add("_txlock", "immediate")
 
-	models := []interface{}{
-		&models.Users{},
-		&models.Secrets{},
-		&models.Sessions{},
+	sqlDB, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
 	}
 
-	for _, model := range models {
-		err := db.Model(model).CreateTable(&orm.CreateTableOptions{
-			Temp:        false, // Временные таблицы
-			IfNotExists: true,
-		})
+	// Файл пишет одно подключение, так запросы бота не мешают друг другу блокировками
+	sqlDB.SetMaxOpenConns(1)
+
+	return sqlDB, nil
+}
+
+// GetSQLite возвращает подключение к файлу базы SQLITE_PATH
+func GetSQLite() *sql.DB {
+	sqliteOnce.Do(func() {
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = DEFAULT_SQLITE_PATH
+		}
+
+		var err error
+		sqliteDB, err = OpenSQLite(path)
 		if err != nil {
-			return err
+			panic(err)
+		}
+	})
+	return sqliteDB
+}
+
+// GetRepos возвращает хранилища поверх базы, выбранной в DB_DRIVER
+func GetRepos() repository.Repos {
+	reposOnce.Do(func() {
+		if Driver() == DriverSQLite {
+			repos = repository.NewSQLiteRepos(GetSQLite())
+		} else {
+			repos = repository.NewPgRepos(GetDB())
 		}
+	})
+	return repos
+}
+
+// Migrations возвращает выбранную базу для применения миграций
+func Migrations() migrations.DB {
+	if Driver() == DriverSQLite {
+		return migrations.SQLite(GetSQLite())
+	}
+
+	return migrations.Postgres(GetDB())
+}
+
+// InitDb применяет к базе миграции, которые еще не применены
+func InitDb() error {
+	return Migrate(Migrations())
+}
+
+// Migrate применяет к db миграции, которые еще не применены
+func Migrate(db migrations.DB) error {
+	all, err := migrations.All(db.Dialect())
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrations.Up(db, all)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range applied {
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
 	}
 
 	return nil
@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const USAGE = "usage: bot migrate up | down [steps] | status"
+
+// Command выполняет подкоманду migrate: up применяет все миграции, down [steps] откатывает
+// последние steps (по умолчанию одну), status показывает, какие миграции применены
+func Command(db DB, args []string, out io.Writer) error {
+	migrations, err := All(db.Dialect())
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New(USAGE)
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errors.New(USAGE)
+		}
+
+		done, err := Up(db, migrations)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+		for _, migration := range done {
+			fmt.Fprintf(out, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "down":
+		steps := 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+		} else if len(args) > 2 {
+			return errors.New(USAGE)
+		}
+
+		done, err := Down(db, migrations, steps)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(out, "no applied migrations")
+		}
+		for _, migration := range done {
+			fmt.Fprintf(out, "rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "status":
+		if len(args) != 1 {
+			return errors.New(USAGE)
+		}
+
+		statuses, err := List(db, migrations)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != 0 {
+				applied = "applied " + time.Unix(status.AppliedAt, 0).UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+	default:
+		return errors.New(USAGE)
+	}
+
+	return nil
+}
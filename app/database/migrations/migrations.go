@@ -0,0 +1,219 @@
+// Package migrations применяет к базе пронумерованные SQL-миграции из каталога sql/<диалект>.
+// Файл NNNN_name.up.sql применяет миграцию, NNNN_name.down.sql откатывает ее.
+// Номера миграций у всех диалектов совпадают, примененные версии записываются в таблицу schema_migrations.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*/*.sql
+var files embed.FS
+
+var ErrIrreversible = errors.New("migration can't be rolled back")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string // Пустая строка, если миграцию нельзя откатить
+}
+
+// DB - база, к которой применяются миграции
+type DB interface {
+	// Dialect - имя каталога с миграциями для этой базы
+	Dialect() string
+	// Locked выполняет fn в транзакции, которую другие экземпляры бота не могут выполнять одновременно
+	Locked(fn func(tx Tx) error) error
+}
+
+type Tx interface {
+	Exec(query string, args ...any) error
+	// Applied возвращает время применения каждой записанной в schema_migrations версии
+	Applied() (map[int]int64, error)
+}
+
+type Status struct {
+	Migration
+	AppliedAt int64 // 0, если миграция еще не применена
+}
+
+// All возвращает встроенные в бинарник миграции диалекта
+func All(dialect string) ([]Migration, error) {
+	fsys, err := fs.Sub(files, path.Join("sql", dialect))
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(fsys)
+}
+
+// Load читает миграции из fsys и сортирует их по версии. У каждой версии должен быть up-файл.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, fileName := range names {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(path.Base(fileName), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: name must end with .up.sql or .down.sql", fileName)
+		}
+
+		number, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", fileName)
+		}
+
+		data, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// withLock выполняет fn в транзакции, взяв блокировку. Если fn вернет ошибку,
+// ни одна из миграций не останется примененной наполовину.
+func withLock(db DB, fn func(tx Tx, applied map[int]int64) error) error {
+	return db.Locked(func(tx Tx) error {
+		err := tx.Exec(`CREATE TABLE IF NOT EXISTS "schema_migrations" (
+			"version" bigint PRIMARY KEY,
+			"name" text NOT NULL,
+			"applied_at" bigint NOT NULL
+		)`)
+		if err != nil {
+			return err
+		}
+
+		applied, err := tx.Applied()
+		if err != nil {
+			return err
+		}
+
+		return fn(tx, applied)
+	})
+}
+
+// Up применяет все еще не примененные миграции и возвращает их
+func Up(db DB, migrations []Migration) ([]Migration, error) {
+	done := []Migration{}
+
+	err := withLock(db, func(tx Tx, applied map[int]int64) error {
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := tx.Exec(migration.Up)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			err = tx.Exec(`INSERT INTO "schema_migrations" ("version", "name", "applied_at") VALUES (?, ?, ?)`,
+				migration.Version, migration.Name, time.Now().Unix())
+			if err != nil {
+				return err
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return done, nil
+}
+
+// Down откатывает steps последних примененных миграций и возвращает их
+func Down(db DB, migrations []Migration, steps int) ([]Migration, error) {
+	done := []Migration{}
+
+	err := withLock(db, func(tx Tx, applied map[int]int64) error {
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, ErrIrreversible)
+			}
+
+			err := tx.Exec(migration.Down)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			err = tx.Exec(`DELETE FROM "schema_migrations" WHERE "version" = ?`, migration.Version)
+			if err != nil {
+				return err
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return done, nil
+}
+
+// List возвращает миграции вместе со временем их применения
+func List(db DB, migrations []Migration) ([]Status, error) {
+	statuses := []Status{}
+
+	err := withLock(db, func(tx Tx, applied map[int]int64) error {
+		for _, migration := range migrations {
+			statuses = append(statuses, Status{Migration: migration, AppliedAt: applied[migration.Version]})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
@@ -0,0 +1,303 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"main/database/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	_ "modernc.org/sqlite"
+)
+
+func TestLoadSortsAndPairsFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_indexes.up.sql":   {Data: []byte("CREATE INDEX")},
+		"0002_indexes.down.sql": {Data: []byte("DROP INDEX")},
+		"0010_later.up.sql":     {Data: []byte("SELECT 10")},
+		"0001_baseline.up.sql":  {Data: []byte("CREATE TABLE")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "baseline", Up: "CREATE TABLE"},
+		{Version: 2, Name: "indexes", Up: "CREATE INDEX", Down: "DROP INDEX"},
+		{Version: 10, Name: "later", Up: "SELECT 10"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("Load = %+v, want %+v", migrations, want)
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Fatalf("migration %d = %+v, want %+v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestLoadRejectsBadNames(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"no direction": {"0001_baseline.sql": {}},
+		"no version":   {"baseline.up.sql": {}},
+		"zero version": {"0000_baseline.up.sql": {}},
+		"only down":    {"0001_baseline.down.sql": {Data: []byte("DROP")}},
+		"two names": {
+			"0001_baseline.up.sql": {Data: []byte("CREATE")},
+			"0001_other.down.sql":  {Data: []byte("DROP")},
+		},
+	}
+
+	for name, fsys := range cases {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: Load succeeded, want an error", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	postgres, err := All("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := All("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, migration := range postgres {
+		if migration.Version != i+1 {
+			t.Fatalf("migration %d_%s breaks the numbering, want version %d", migration.Version, migration.Name, i+1)
+		}
+	}
+
+	// Версии в schema_migrations должны значить одно и то же в обеих базах
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("%d sqlite migrations, %d postgres migrations", len(sqlite), len(postgres))
+	}
+	for i := range postgres {
+		if sqlite[i].Version != postgres[i].Version || sqlite[i].Name != postgres[i].Name {
+			t.Fatalf("sqlite migration %d_%s doesn't match postgres %d_%s",
+				sqlite[i].Version, sqlite[i].Name, postgres[i].Version, postgres[i].Name)
+		}
+		if (sqlite[i].Down == "") != (postgres[i].Down == "") {
+			t.Fatalf("migration %d_%s can be rolled back only in one of the databases", postgres[i].Version, postgres[i].Name)
+		}
+	}
+}
+
+func testUpDown(t *testing.T, db DB) {
+	migrations, err := All(db.Dialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Up(db, migrations); err != nil {
+		t.Fatal(err)
+	}
+	if done, err := Up(db, migrations); err != nil || len(done) != 0 {
+		t.Fatalf("second Up = %+v, %v, want nothing to apply", done, err)
+	}
+
+	// Откатываем все, кроме базовой схемы, и применяем снова
+	done, err := Down(db, migrations, len(migrations)-1)
+	if err != nil || len(done) != len(migrations)-1 {
+		t.Fatalf("Down = %+v, %v", done, err)
+	}
+	if _, err := Down(db, migrations, 1); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("rolling back the baseline = %v, want ErrIrreversible", err)
+	}
+	if _, err := Up(db, migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := List(db, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == 0 {
+			t.Fatalf("migration %d_%s is not applied", status.Version, status.Name)
+		}
+	}
+}
+
+func TestUpDownSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "vault.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testUpDown(t, SQLite(db))
+
+	expectModelColumns(t, func(table string) ([]string, error) {
+		rows, err := db.Query(`SELECT "name" FROM pragma_table_info(?)`, table)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		columns := []string{}
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				return nil, err
+			}
+			columns = append(columns, column)
+		}
+
+		return columns, rows.Err()
+	})
+}
+
+// expectModelColumns проверяет, что в базе есть все колонки, которые бот читает и пишет через модели
+func expectModelColumns(t *testing.T, tableColumns func(table string) ([]string, error)) {
+	t.Helper()
+
+	for _, model := range []any{
+		models.Users{}, models.Secrets{}, models.Sessions{}, models.LoginAttempts{}, models.BotMessages{},
+		models.Wipes{}, models.UserSettings{}, models.ExpiringMessages{}, models.AuditEvents{}, models.Shares{},
+	} {
+		table := orm.GetTable(reflect.TypeOf(model))
+		name := strings.Trim(string(table.SQLName), `"`)
+
+		columns, err := tableColumns(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, field := range table.Fields {
+			if !slices.Contains(columns, field.SQLName) {
+				t.Errorf("table %s has no column %s", name, field.SQLName)
+			}
+		}
+	}
+}
+
+// Для TestUpDownPostgres нужна настоящая база, поэтому тест запускается, только если задан POSTGRES_HOST
+func TestUpDownPostgres(t *testing.T) {
+	if os.Getenv("POSTGRES_HOST") == "" {
+		t.Skip("POSTGRES_HOST is not set")
+	}
+
+	db := pg.Connect(&pg.Options{
+		Addr:     os.Getenv("POSTGRES_HOST") + ":" + os.Getenv("POSTGRES_PORT"),
+		User:     os.Getenv("POSTGRES_USER"),
+		Password: os.Getenv("POSTGRES_PASSWORD"),
+		Database: os.Getenv("POSTGRES_DB"),
+	})
+	defer db.Close()
+
+	testUpDown(t, Postgres(db))
+}
+
+// legacySchema - таблицы, которые создавал CreateTable до появления двухфакторной аутентификации,
+// пароля под принуждением и сессий по чатам. Базы таких версий должны обновиться до текущих моделей.
+const legacySchema = `
+CREATE TABLE "users" (
+    "id" bigserial PRIMARY KEY,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "updated_at" bigint DEFAULT extract(epoch from now()),
+    "telegram_id" bigint,
+    "password_hash" text
+);
+CREATE TABLE "secrets" (
+    "id" bigserial PRIMARY KEY,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "updated_at" bigint DEFAULT extract(epoch from now()),
+    "user_id" bigint,
+    "title" text,
+    "login" text,
+    "password" text,
+    "site_link" text,
+    "description" text
+);
+CREATE TABLE "sessions" (
+    "id" bigserial PRIMARY KEY,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "updated_at" bigint DEFAULT extract(epoch from now()),
+    "user_id" bigint,
+    "password" text,
+    "reset_time_interval" bigint DEFAULT 10
+);
+INSERT INTO "users" ("telegram_id", "password_hash") VALUES (1, 'hash');
+INSERT INTO "users" ("telegram_id", "password_hash") VALUES (1, 'duplicate'), (NULL, 'orphan');
+INSERT INTO "secrets" ("user_id", "title") VALUES (1, 'GitHub');
+INSERT INTO "sessions" ("user_id", "password") VALUES (1, 'encrypted');
+`
+
+// TestUpgradeLegacyPostgres обновляет базу старой версии в отдельной схеме, чтобы не задеть общие таблицы
+func TestUpgradeLegacyPostgres(t *testing.T) {
+	if os.Getenv("POSTGRES_HOST") == "" {
+		t.Skip("POSTGRES_HOST is not set")
+	}
+
+	options := &pg.Options{
+		Addr:     os.Getenv("POSTGRES_HOST") + ":" + os.Getenv("POSTGRES_PORT"),
+		User:     os.Getenv("POSTGRES_USER"),
+		Password: os.Getenv("POSTGRES_PASSWORD"),
+		Database: os.Getenv("POSTGRES_DB"),
+	}
+	schema := fmt.Sprintf("legacy_%d", time.Now().UnixNano())
+
+	admin := pg.Connect(options)
+	defer admin.Close()
+
+	if _, err := admin.Exec("CREATE SCHEMA ?", pg.Ident(schema)); err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Exec("DROP SCHEMA ? CASCADE", pg.Ident(schema))
+
+	options.OnConnect = func(ctx context.Context, cn *pg.Conn) error {
+		_, err := cn.Exec("SET search_path TO ?", pg.Ident(schema))
+
+		return err
+	}
+	db := pg.Connect(options)
+	defer db.Close()
+
+	if _, err := db.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := All("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Up(Postgres(db), migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	expectModelColumns(t, func(table string) ([]string, error) {
+		var columns []string
+		_, err := db.Query(&columns, `SELECT "column_name" FROM "information_schema"."columns" WHERE "table_schema" = ? AND "table_name" = ?`, schema, table)
+
+		return columns, err
+	})
+
+	// Старые строки читаются через текущие модели, из дублей остается первая запись
+	user := &models.Users{}
+	if err := db.Model(user).Where("telegram_id = ?", 1).Select(); err != nil || user.PasswordHash != "hash" || user.TOTPEnabled || user.DuressPasswordHash != "" {
+		t.Fatalf("legacy user = %+v, %v", user, err)
+	}
+	if count, err := db.Model(&models.Users{}).Count(); err != nil || count != 1 {
+		t.Fatalf("users after upgrade = %d, %v", count, err)
+	}
+	session := &models.Sessions{}
+	if err := db.Model(session).Where("user_id = ?", 1).Select(); err != nil || session.ChatID != 0 || session.IsDuress {
+		t.Fatalf("legacy session = %+v, %v", session, err)
+	}
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"github.com/go-pg/pg/v10"
+)
+
+// LOCK_ID - ключ advisory-блокировки, пока она взята, другие экземпляры бота ждут
+const LOCK_ID = 7_241_003_117
+
+type postgres struct {
+	db *pg.DB
+}
+
+// Postgres применяет миграции из каталога sql/postgres
+func Postgres(db *pg.DB) DB {
+	return postgres{db: db}
+}
+
+func (p postgres) Dialect() string {
+	return "postgres"
+}
+
+func (p postgres) Locked(fn func(tx Tx) error) error {
+	return p.db.RunInTransaction(p.db.Context(), func(tx *pg.Tx) error {
+		_, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", LOCK_ID)
+		if err != nil {
+			return err
+		}
+
+		return fn(postgresTx{tx: tx})
+	})
+}
+
+type appliedMigration struct {
+	Version   int
+	AppliedAt int64
+}
+
+type postgresTx struct {
+	tx *pg.Tx
+}
+
+func (t postgresTx) Exec(query string, args ...any) error {
+	_, err := t.tx.Exec(query, args...)
+
+	return err
+}
+
+func (t postgresTx) Applied() (map[int]int64, error) {
+	var rows []appliedMigration
+	_, err := t.tx.Query(&rows, `SELECT "version", "applied_at" FROM "schema_migrations"`)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int]int64{}
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+
+	return applied, nil
+}
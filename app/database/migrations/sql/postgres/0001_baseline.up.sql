@@ -0,0 +1,124 @@
+-- Схема в том виде, в каком ее создавал CreateTable IfNotExists. В базах, созданных до миграций,
+-- таблицы уже есть, но в старых таблицах может не хватать колонок, добавленных позже.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "updated_at" bigint DEFAULT extract(epoch from now()),
+    "telegram_id" bigint,
+    "password_hash" text,
+    PRIMARY KEY ("id")
+);
+
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "totp_secret" text,
+    ADD COLUMN IF NOT EXISTS "totp_enabled" boolean DEFAULT false,
+    ADD COLUMN IF NOT EXISTS "recovery_codes" text[],
+    ADD COLUMN IF NOT EXISTS "duress_password_hash" text,
+    ADD COLUMN IF NOT EXISTS "duress_totp_secret" text,
+    ADD COLUMN IF NOT EXISTS "duress_action" text,
+    ADD COLUMN IF NOT EXISTS "duress_alert" boolean DEFAULT false,
+    ADD COLUMN IF NOT EXISTS "vault_locked_until" bigint DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "secrets" (
+    "id" bigserial,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "updated_at" bigint DEFAULT extract(epoch from now()),
+    "user_id" bigint,
+    "title" text,
+    "login" text,
+    "password" text,
+    "site_link" text,
+    "description" text,
+    PRIMARY KEY ("id")
+);
+
+ALTER TABLE "secrets"
+    ADD COLUMN IF NOT EXISTS "is_decoy" boolean DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" bigserial,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "updated_at" bigint DEFAULT extract(epoch from now()),
+    "user_id" bigint,
+    "password" text,
+    "reset_time_interval" bigint DEFAULT 10,
+    PRIMARY KEY ("id")
+);
+
+ALTER TABLE "sessions"
+    ADD COLUMN IF NOT EXISTS "is_duress" boolean DEFAULT false,
+    ADD COLUMN IF NOT EXISTS "source" text DEFAULT 'telegram',
+    ADD COLUMN IF NOT EXISTS "chat_id" bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "warning_message_id" bigint DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+    "id" bigserial,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "updated_at" bigint DEFAULT extract(epoch from now()),
+    "telegram_id" bigint UNIQUE,
+    "failures" bigint,
+    "last_failure_at" bigint,
+    "blocked_until" bigint,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "bot_messages" (
+    "id" bigserial,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "chat_id" bigint,
+    "message_id" bigint,
+    "user_id" bigint,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "wipes" (
+    "id" bigserial,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "telegram_id" bigint,
+    "chat_id" bigint,
+    "secrets_deleted" bigint,
+    "sessions_deleted" bigint,
+    "messages_deleted" bigint,
+    "backup_sent" boolean,
+    "is_duress" boolean,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "user_settings" (
+    "id" bigserial,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "updated_at" bigint DEFAULT extract(epoch from now()),
+    "telegram_id" bigint UNIQUE,
+    "reveal_timeout" bigint,
+    "display_mode" text,
+    "session_timeout" bigint,
+    "page_size" bigint,
+    "sort_order" text,
+    "language" text,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "expiring_messages" (
+    "id" bigserial,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "chat_id" bigint,
+    "message_id" bigint,
+    "user_id" bigint,
+    "expires_at" bigint,
+    "action" text,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "audit_events" (
+    "id" bigserial,
+    "created_at" bigint,
+    "event_type" text,
+    "user_id" bigint,
+    "chat_id" bigint,
+    "secret_id" bigint,
+    "is_duress" boolean,
+    "prev_hash" text,
+    "hash" text,
+    PRIMARY KEY ("id")
+);
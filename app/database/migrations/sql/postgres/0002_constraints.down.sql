@@ -0,0 +1,12 @@
+DROP INDEX "audit_events_user_id_id_idx";
+DROP INDEX "expiring_messages_chat_id_message_id_idx";
+DROP INDEX "expiring_messages_expires_at_idx";
+DROP INDEX "bot_messages_chat_id_idx";
+DROP INDEX "sessions_user_id_chat_id_key";
+DROP INDEX "secrets_user_id_is_decoy_idx";
+
+ALTER TABLE "sessions" DROP CONSTRAINT "sessions_user_id_fkey";
+ALTER TABLE "secrets" DROP CONSTRAINT "secrets_user_id_fkey";
+
+ALTER TABLE "users" DROP CONSTRAINT "users_telegram_id_key";
+ALTER TABLE "users" ALTER COLUMN "telegram_id" DROP NOT NULL;
@@ -0,0 +1,33 @@
+-- Колонки user_id в secrets и sessions хранят Telegram ID, поэтому ссылаются на users.telegram_id
+
+-- Старые версии не запрещали дубли и пустой telegram_id. Без telegram_id войти нельзя, такие строки удаляются.
+-- Из дублей остается первая запись: ее и находил вход.
+DELETE FROM "users" WHERE "telegram_id" IS NULL;
+DELETE FROM "users" u
+USING "users" older
+WHERE u."telegram_id" = older."telegram_id" AND u."id" > older."id";
+
+ALTER TABLE "users" ALTER COLUMN "telegram_id" SET NOT NULL;
+ALTER TABLE "users" ADD CONSTRAINT "users_telegram_id_key" UNIQUE ("telegram_id");
+
+-- Старые строки могли остаться без пользователя, поэтому ограничение проверяется только для новых записей
+ALTER TABLE "secrets"
+    ADD CONSTRAINT "secrets_user_id_fkey" FOREIGN KEY ("user_id")
+    REFERENCES "users" ("telegram_id") ON DELETE CASCADE NOT VALID;
+ALTER TABLE "sessions"
+    ADD CONSTRAINT "sessions_user_id_fkey" FOREIGN KEY ("user_id")
+    REFERENCES "users" ("telegram_id") ON DELETE CASCADE NOT VALID;
+
+CREATE INDEX "secrets_user_id_is_decoy_idx" ON "secrets" ("user_id", "is_decoy");
+
+-- У пользователя в одном чате не больше одной сессии. Лишние могли остаться после гонок, оставляем новейшую.
+-- Сессии старых версий были без чата (chat_id = 0), на них ограничение не распространяется.
+DELETE FROM "sessions" s
+USING "sessions" newer
+WHERE s."chat_id" != 0 AND s."user_id" = newer."user_id" AND s."chat_id" = newer."chat_id" AND s."id" < newer."id";
+CREATE UNIQUE INDEX "sessions_user_id_chat_id_key" ON "sessions" ("user_id", "chat_id") WHERE "chat_id" != 0;
+
+CREATE INDEX "bot_messages_chat_id_idx" ON "bot_messages" ("chat_id");
+CREATE INDEX "expiring_messages_expires_at_idx" ON "expiring_messages" ("expires_at");
+CREATE INDEX "expiring_messages_chat_id_message_id_idx" ON "expiring_messages" ("chat_id", "message_id");
+CREATE INDEX "audit_events_user_id_id_idx" ON "audit_events" ("user_id", "id");
@@ -0,0 +1,17 @@
+-- Одноразовые ссылки на секреты. Ссылка пропадает вместе с секретом, поэтому удаление секрета или хранилища отзывает ее.
+CREATE TABLE "shares" (
+    "id" bigserial,
+    "created_at" bigint DEFAULT extract(epoch from now()),
+    "user_id" bigint NOT NULL REFERENCES "users" ("telegram_id") ON DELETE CASCADE,
+    "secret_id" bigint NOT NULL REFERENCES "secrets" ("id") ON DELETE CASCADE,
+    "is_decoy" boolean NOT NULL DEFAULT false,
+    "token_hash" text NOT NULL,
+    "payload" text NOT NULL,
+    "views_left" integer NOT NULL,
+    "expires_at" bigint NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX "shares_token_hash_key" ON "shares" ("token_hash");
+CREATE INDEX "shares_secret_id_idx" ON "shares" ("secret_id");
+CREATE INDEX "shares_expires_at_idx" ON "shares" ("expires_at");
@@ -0,0 +1,110 @@
+-- Та же схема, что и для Postgres. Логические значения хранятся как 0 и 1, массивы - как JSON.
+-- Ограничения, которые в Postgres добавляет вторая миграция, SQLite не умеет добавлять к готовой таблице,
+-- поэтому они объявлены сразу.
+
+CREATE TABLE "users" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer DEFAULT (unixepoch()),
+    "updated_at" integer DEFAULT (unixepoch()),
+    "telegram_id" integer NOT NULL UNIQUE,
+    "password_hash" text,
+    "totp_secret" text,
+    "totp_enabled" integer DEFAULT 0,
+    "recovery_codes" text,
+    "duress_password_hash" text,
+    "duress_totp_secret" text,
+    "duress_action" text,
+    "duress_alert" integer DEFAULT 0,
+    "vault_locked_until" integer DEFAULT 0
+);
+
+CREATE TABLE "secrets" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer DEFAULT (unixepoch()),
+    "updated_at" integer DEFAULT (unixepoch()),
+    "user_id" integer REFERENCES "users" ("telegram_id") ON DELETE CASCADE,
+    "title" text,
+    "login" text,
+    "password" text,
+    "site_link" text,
+    "description" text,
+    "is_decoy" integer DEFAULT 0
+);
+
+CREATE TABLE "sessions" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer DEFAULT (unixepoch()),
+    "updated_at" integer DEFAULT (unixepoch()),
+    "user_id" integer REFERENCES "users" ("telegram_id") ON DELETE CASCADE,
+    "password" text,
+    "reset_time_interval" integer DEFAULT 10,
+    "is_duress" integer DEFAULT 0,
+    "source" text DEFAULT 'telegram',
+    "chat_id" integer DEFAULT 0,
+    "warning_message_id" integer DEFAULT 0
+);
+
+CREATE TABLE "login_attempts" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer DEFAULT (unixepoch()),
+    "updated_at" integer DEFAULT (unixepoch()),
+    "telegram_id" integer UNIQUE,
+    "failures" integer,
+    "last_failure_at" integer,
+    "blocked_until" integer
+);
+
+CREATE TABLE "bot_messages" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer DEFAULT (unixepoch()),
+    "chat_id" integer,
+    "message_id" integer,
+    "user_id" integer
+);
+
+CREATE TABLE "wipes" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer DEFAULT (unixepoch()),
+    "telegram_id" integer,
+    "chat_id" integer,
+    "secrets_deleted" integer,
+    "sessions_deleted" integer,
+    "messages_deleted" integer,
+    "backup_sent" integer,
+    "is_duress" integer
+);
+
+CREATE TABLE "user_settings" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer DEFAULT (unixepoch()),
+    "updated_at" integer DEFAULT (unixepoch()),
+    "telegram_id" integer UNIQUE,
+    "reveal_timeout" integer,
+    "display_mode" text,
+    "session_timeout" integer,
+    "page_size" integer,
+    "sort_order" text,
+    "language" text
+);
+
+CREATE TABLE "expiring_messages" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer DEFAULT (unixepoch()),
+    "chat_id" integer,
+    "message_id" integer,
+    "user_id" integer,
+    "expires_at" integer,
+    "action" text
+);
+
+CREATE TABLE "audit_events" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer,
+    "event_type" text,
+    "user_id" integer,
+    "chat_id" integer,
+    "secret_id" integer,
+    "is_duress" integer,
+    "prev_hash" text,
+    "hash" text
+);
@@ -0,0 +1,8 @@
+-- UNIQUE и NOT NULL для users.telegram_id заданы уже в 0001: баз SQLite старых версий нет, чистить нечего
+
+CREATE INDEX "secrets_user_id_is_decoy_idx" ON "secrets" ("user_id", "is_decoy");
+CREATE UNIQUE INDEX "sessions_user_id_chat_id_key" ON "sessions" ("user_id", "chat_id") WHERE "chat_id" != 0;
+CREATE INDEX "bot_messages_chat_id_idx" ON "bot_messages" ("chat_id");
+CREATE INDEX "expiring_messages_expires_at_idx" ON "expiring_messages" ("expires_at");
+CREATE INDEX "expiring_messages_chat_id_message_id_idx" ON "expiring_messages" ("chat_id", "message_id");
+CREATE INDEX "audit_events_user_id_id_idx" ON "audit_events" ("user_id", "id");
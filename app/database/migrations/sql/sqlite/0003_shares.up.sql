@@ -0,0 +1,16 @@
+-- Одноразовые ссылки на секреты. Ссылка пропадает вместе с секретом, поэтому удаление секрета или хранилища отзывает ее.
+CREATE TABLE "shares" (
+    "id" integer PRIMARY KEY,
+    "created_at" integer DEFAULT (unixepoch()),
+    "user_id" integer NOT NULL REFERENCES "users" ("telegram_id") ON DELETE CASCADE,
+    "secret_id" integer NOT NULL REFERENCES "secrets" ("id") ON DELETE CASCADE,
+    "is_decoy" integer NOT NULL DEFAULT 0,
+    "token_hash" text NOT NULL,
+    "payload" text NOT NULL,
+    "views_left" integer NOT NULL,
+    "expires_at" integer NOT NULL
+);
+
+CREATE UNIQUE INDEX "shares_token_hash_key" ON "shares" ("token_hash");
+CREATE INDEX "shares_secret_id_idx" ON "shares" ("secret_id");
+CREATE INDEX "shares_expires_at_idx" ON "shares" ("expires_at");
@@ -0,0 +1,77 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+type sqlite struct {
+	db *sql.DB
+}
+
+// SQLite применяет миграции из каталога sql/sqlite
+func SQLite(db *sql.DB) DB {
+	return sqlite{db: db}
+}
+
+func (s sqlite) Dialect() string {
+	return "sqlite"
+}
+
+// Locked открывает транзакцию через BEGIN IMMEDIATE: она сразу берет блокировку записи на файл базы,
+// и второй процесс ждет, пока первый не применит миграции
+func (s sqlite) Locked(fn func(tx Tx) error) (err error) {
+	ctx := context.Background()
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+	if err != nil {
+		return err
+	}
+
+	err = fn(sqliteTx{ctx: ctx, conn: conn})
+	if err != nil {
+		conn.ExecContext(ctx, "ROLLBACK")
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "COMMIT")
+
+	return err
+}
+
+type sqliteTx struct {
+	ctx  context.Context
+	conn *sql.Conn
+}
+
+func (t sqliteTx) Exec(query string, args ...any) error {
+	_, err := t.conn.ExecContext(t.ctx, query, args...)
+
+	return err
+}
+
+func (t sqliteTx) Applied() (map[int]int64, error) {
+	rows, err := t.conn.QueryContext(t.ctx, `SELECT "version", "applied_at" FROM "schema_migrations"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]int64{}
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
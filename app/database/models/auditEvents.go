@@ -0,0 +1,18 @@
+package models
+
+// AuditEvents - журнал доступа к хранилищу. Каждая запись хранит хеш предыдущей,
+// поэтому удаление или изменение записей задним числом обнаруживается при проверке цепочки.
+// Секретные данные в журнал не пишутся.
+type AuditEvents struct {
+	ID        int64 `pg:"id,pk"`
+	CreatedAt int64 `pg:"created_at,use_zero"`
+
+	EventType string `pg:"event_type"`
+	UserID    int64  `pg:"user_id,use_zero"`
+	ChatID    int64  `pg:"chat_id,use_zero"`
+	SecretID  int64  `pg:"secret_id,use_zero"`
+	IsDuress  bool   `pg:"is_duress,use_zero"`
+
+	PrevHash string `pg:"prev_hash,use_zero"`
+	Hash     string `pg:"hash"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// BotMessages - сообщения бота, которые нужно уметь найти и удалить или отредактировать позже
+type BotMessages struct {
+	ID        int64 `pg:"id,pk"`
+	CreatedAt int64 `pg:",default:extract(epoch from now())"`
+
+	ChatID    int64 `pg:"chat_id"`
+	MessageID int   `pg:"message_id"`
+	UserID    int64 `pg:"user_id"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// ExpiringMessages - сообщения с расшифрованными данными, которые нужно убрать из чата по таймауту
+type ExpiringMessages struct {
+	ID        int64 `pg:"id,pk"`
+	CreatedAt int64 `pg:",default:extract(epoch from now())"`
+
+	ChatID    int64  `pg:"chat_id"`
+	MessageID int    `pg:"message_id"`
+	UserID    int64  `pg:"user_id"`
+	ExpiresAt int64  `pg:"expires_at"`
+	Action    string `pg:"action"` // hide - заменить текст заглушкой, delete - удалить сообщение
+}
@@ -0,0 +1,12 @@
+package models
+
+type LoginAttempts struct {
+	ID        int64 `pg:"id,pk"`
+	CreatedAt int64 `pg:",default:extract(epoch from now())"`
+	UpdatedAt int64 `pg:",default:extract(epoch from now())"`
+
+	TelegramID    int64 `pg:"telegram_id,unique"`
+	Failures      int64 `pg:"failures,use_zero"`
+	LastFailureAt int64 `pg:"last_failure_at,use_zero"`
+	BlockedUntil  int64 `pg:"blocked_until,use_zero"`
+}
@@ -13,4 +13,6 @@ type Secrets struct {
 	Password  string `pg:"password"`
 	SiteLink  string `pg:"site_link"`
 	Description string `pg:"description"`
+
+	IsDecoy bool `pg:"is_decoy,use_zero"` // Секрет из хранилища-приманки (пароль под принуждением)
 }
@@ -1,5 +1,7 @@
 package models
 
+const SessionSourceTelegram = "telegram"
+
 type Sessions struct {
 	ID        int64  `pg:"id,pk"`
 	CreatedAt int64 `pg:",default:extract(epoch from now())"`
@@ -10,4 +12,13 @@ type Sessions struct {
 
 	EncryptedPassword string `pg:"password"`
 	ResetTimeInterval int64 `pg:"reset_time_interval,default:10"`
+
+	IsDuress bool `pg:"is_duress,use_zero"` // Сессия открыта паролем под принуждением
+
+	// Откуда открыта сессия. У пользователя может быть несколько сессий, но не больше одной на чат.
+	Source string `pg:"source,default:'telegram'"`
+	ChatID int64  `pg:"chat_id,use_zero"`
+
+	// Отправленное в чат предупреждение о скором окончании сессии
+	WarningMessageID int `pg:"warning_message_id,use_zero"`
 }
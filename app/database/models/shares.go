@@ -0,0 +1,16 @@
+package models
+
+// Shares - одноразовые ссылки на секреты. Данные секрета зашифрованы случайным ключом, который есть только в ссылке,
+// а в базе хранится хеш ключа: по нему ссылка находится, но расшифровать ее без самой ссылки нельзя.
+type Shares struct {
+	ID        int64 `pg:"id,pk"`
+	CreatedAt int64 `pg:",default:extract(epoch from now())"`
+
+	UserID    int64  `pg:"user_id"` // Кто поделился секретом
+	SecretID  int64  `pg:"secret_id"`
+	IsDecoy   bool   `pg:"is_decoy,use_zero"`
+	TokenHash string `pg:"token_hash"`
+	Payload   string `pg:"payload"`
+	ViewsLeft int    `pg:"views_left,use_zero"`
+	ExpiresAt int64  `pg:"expires_at"`
+}
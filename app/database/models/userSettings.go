@@ -0,0 +1,15 @@
+package models
+
+type UserSettings struct {
+	ID        int64 `pg:"id,pk"`
+	CreatedAt int64 `pg:",default:extract(epoch from now())"`
+	UpdatedAt int64 `pg:",default:extract(epoch from now())"`
+
+	TelegramID     int64  `pg:"telegram_id,unique"`
+	RevealTimeout  int64  `pg:"reveal_timeout,use_zero"` // Через сколько секунд скрывать расшифрованные данные (0 - не скрывать)
+	DisplayMode    string `pg:"display_mode"`            // Как показывать пароль: code, spoiler или masked
+	SessionTimeout int64  `pg:"session_timeout"`         // Через сколько секунд бездействия завершается сессия
+	PageSize       int    `pg:"page_size"`               // Сколько секретов показывать на странице
+	SortOrder      string `pg:"sort_order"`              // Порядок секретов: old, new или title
+	Language       string `pg:"language"`
+}
@@ -7,4 +7,18 @@ type Users struct {
 
 	TelegramID int64  `pg:"telegram_id"`
 	PasswordHash string `pg:"password_hash"`
+
+	// Второй фактор: секрет TOTP хранится зашифрованным мастер-паролем,
+	// коды восстановления - только в виде хешей
+	TOTPSecret    string   `pg:"totp_secret"`
+	TOTPEnabled   bool     `pg:"totp_enabled,use_zero"`
+	RecoveryCodes []string `pg:"recovery_codes,array"`
+
+	// Пароль под принуждением открывает отдельное хранилище-приманку.
+	// DuressTOTPSecret - копия секрета TOTP, зашифрованная паролем под принуждением.
+	DuressPasswordHash string `pg:"duress_password_hash"`
+	DuressTOTPSecret   string `pg:"duress_totp_secret"`
+	DuressAction       string `pg:"duress_action"`
+	DuressAlert        bool   `pg:"duress_alert,use_zero"`
+	VaultLockedUntil   int64  `pg:"vault_locked_until,use_zero"`
 }
@@ -0,0 +1,15 @@
+package models
+
+// Wipes - журнал экстренных удалений данных, хранится для администратора
+type Wipes struct {
+	ID        int64 `pg:"id,pk"`
+	CreatedAt int64 `pg:",default:extract(epoch from now())"`
+
+	TelegramID      int64 `pg:"telegram_id"`
+	ChatID          int64 `pg:"chat_id"`
+	SecretsDeleted  int   `pg:"secrets_deleted,use_zero"`
+	SessionsDeleted int   `pg:"sessions_deleted,use_zero"`
+	MessagesDeleted int   `pg:"messages_deleted,use_zero"`
+	BackupSent      bool  `pg:"backup_sent,use_zero"`
+	IsDuress        bool  `pg:"is_duress,use_zero"`
+}
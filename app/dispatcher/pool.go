@@ -0,0 +1,109 @@
+package dispatcher
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type HandleFunc func(update tgbotapi.Update)
+
+// Pool обрабатывает обновления параллельно. Обновления с одним ключом (чат или пользователь)
+// всегда попадают к одному и тому же обработчику, поэтому шаги диалога не перемешиваются.
+type Pool struct {
+	queues []chan tgbotapi.Update
+	handle HandleFunc
+	wg     sync.WaitGroup
+
+	closeOnce sync.Once
+}
+
+// NewPool запускает workers обработчиков, у каждого очередь на queueSize обновлений
+func NewPool(workers, queueSize int, handle HandleFunc) *Pool {
+	p := &Pool{
+		queues: make([]chan tgbotapi.Update, workers),
+		handle: handle,
+	}
+
+	for i := range p.queues {
+		p.queues[i] = make(chan tgbotapi.Update, queueSize)
+
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+
+	return p
+}
+
+// UpdateKey возвращает ключ, по которому обновления упорядочиваются: чат, а если его нет - пользователь
+func UpdateKey(update tgbotapi.Update) int64 {
+	if chat := update.FromChat(); chat != nil {
+		return chat.ID
+	}
+
+	if user := update.SentFrom(); user != nil {
+		return user.ID
+	}
+
+	return 0
+}
+
+func (p *Pool) shard(key int64) int {
+	h := fnv.New32a()
+	h.Write([]byte(strconv.FormatInt(key, 10)))
+
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// Submit ставит обновление в очередь. Если очередь заполнена, вызов ждет, пока в ней освободится место,
+// и тем самым притормаживает получение новых обновлений.
+func (p *Pool) Submit(update tgbotapi.Update) {
+	p.queues[p.shard(UpdateKey(update))] <- update
+}
+
+// Shutdown перестает принимать обновления и ждет, пока обработаются уже поставленные в очередь.
+// После Shutdown вызывать Submit нельзя.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work(queue chan tgbotapi.Update) {
+	defer p.wg.Done()
+
+	for update := range queue {
+		p.safeHandle(update)
+	}
+}
+
+// safeHandle не дает панике в одном обновлении остановить обработчик очереди
+func (p *Pool) safeHandle(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic while processing update %d: %v\n%s", update.UpdateID, r, debug.Stack())
+		}
+	}()
+
+	p.handle(update)
+}
@@ -0,0 +1,128 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func chatUpdate(updateID int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: updateID,
+		Message: &tgbotapi.Message{
+			From: &tgbotapi.User{ID: chatID},
+			Chat: &tgbotapi.Chat{ID: chatID},
+		},
+	}
+}
+
+func TestPoolKeepsOrderPerChat(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[int64][]int{}
+
+	pool := NewPool(4, 8, func(update tgbotapi.Update) {
+		// Разная задержка перемешала бы обновления, если бы порядок не соблюдался
+		time.Sleep(time.Duration(update.UpdateID%3) * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		seen[update.Message.Chat.ID] = append(seen[update.Message.Chat.ID], update.UpdateID)
+	})
+
+	for i := 0; i < 100; i++ {
+		pool.Submit(chatUpdate(i, int64(i%5)))
+	}
+
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for chatID, ids := range seen {
+		total += len(ids)
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Fatalf("chat %d: updates processed out of order: %v", chatID, ids)
+			}
+		}
+	}
+
+	if total != 100 {
+		t.Fatalf("processed %d updates, want 100", total)
+	}
+}
+
+func TestPoolProcessesChatsConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	fastDone := make(chan struct{})
+
+	pool := NewPool(2, 1, func(update tgbotapi.Update) {
+		if update.Message.Chat.ID == 1 {
+			<-release
+
+			return
+		}
+
+		close(fastDone)
+	})
+
+	// Ищем чат, который попадает к другому обработчику, чем медленный чат 1
+	fastChat := int64(2)
+	for pool.shard(fastChat) == pool.shard(1) {
+		fastChat++
+	}
+
+	pool.Submit(chatUpdate(1, 1))
+	pool.Submit(chatUpdate(2, fastChat))
+
+	select {
+	case <-fastDone:
+	case <-time.After(time.Second):
+		t.Fatal("slow chat blocked another chat")
+	}
+
+	close(release)
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolShutdownTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	pool := NewPool(1, 1, func(update tgbotapi.Update) { <-release })
+	pool.Submit(chatUpdate(1, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := pool.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown returned %v, want deadline exceeded", err)
+	}
+}
+
+func TestPoolSurvivesPanics(t *testing.T) {
+	processed := 0
+
+	pool := NewPool(1, 4, func(update tgbotapi.Update) {
+		processed++
+		if update.UpdateID == 1 {
+			panic("boom")
+		}
+	})
+
+	pool.Submit(chatUpdate(1, 1))
+	pool.Submit(chatUpdate(2, 1))
+
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if processed != 2 {
+		t.Fatalf("processed %d updates, want 2", processed)
+	}
+}
@@ -0,0 +1,139 @@
+// Package exporter записывает секреты в открытом виде в формате экспорта Bitwarden (CSV и JSON),
+// который понимают Bitwarden и большинство других менеджеров паролей.
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+)
+
+// Entry - секрет в открытом виде
+type Entry struct {
+	Title       string
+	Login       string
+	Password    string
+	SiteLink    string
+	Description string
+}
+
+// isNote - запись без логина, пароля и ссылки сохраняется как заметка
+func (e Entry) isNote() bool {
+	return e.Login == "" && e.Password == "" && e.SiteLink == ""
+}
+
+var csvHeader = []string{"folder", "favorite", "type", "name", "notes", "fields", "reprompt", "login_uri", "login_username", "login_password", "login_totp"}
+
+// spreadsheetSafe не дает табличному редактору принять название или заметку за формулу.
+// Логин, пароль и ссылка не меняются, иначе менеджер паролей импортирует их с лишним апострофом,
+// поэтому открывать экспорт в табличном редакторе все равно нельзя.
+func spreadsheetSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// BitwardenCSV записывает секреты в CSV с колонками экспорта Bitwarden
+func BitwardenCSV(entries []Entry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+
+	w.Write(csvHeader)
+	for _, e := range entries {
+		if e.isNote() {
+			w.Write([]string{"", "", "note", spreadsheetSafe(e.Title), spreadsheetSafe(e.Description), "", "0", "", "", "", ""})
+			continue
+		}
+
+		w.Write([]string{"", "", "login", spreadsheetSafe(e.Title), spreadsheetSafe(e.Description), "", "0", e.SiteLink, e.Login, e.Password, ""})
+	}
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+type bitwardenExport struct {
+	Encrypted bool            `json:"encrypted"`
+	Folders   []any           `json:"folders"`
+	Items     []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	Type       int                 `json:"type"`
+	Name       string              `json:"name"`
+	Notes      *string             `json:"notes"`
+	Favorite   bool                `json:"favorite"`
+	Reprompt   int                 `json:"reprompt"`
+	Login      *bitwardenLoginData `json:"login,omitempty"`
+	SecureNote *struct {
+		Type int `json:"type"`
+	} `json:"secureNote,omitempty"`
+}
+
+type bitwardenLoginData struct {
+	URIs     []bitwardenURI `json:"uris"`
+	Username *string        `json:"username"`
+	Password *string        `json:"password"`
+	TOTP     *string        `json:"totp"`
+}
+
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+// optional - Bitwarden пишет пустые поля как null
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+// BitwardenJSON записывает секреты в незашифрованный JSON экспорта Bitwarden
+func BitwardenJSON(entries []Entry) ([]byte, error) {
+	export := bitwardenExport{
+		Folders: []any{},
+		Items:   []bitwardenItem{},
+	}
+
+	for _, e := range entries {
+		item := bitwardenItem{
+			Name:  e.Title,
+			Notes: optional(e.Description),
+		}
+
+		if e.isNote() {
+			item.Type = bitwardenSecureNote
+			item.SecureNote = &struct {
+				Type int `json:"type"`
+			}{}
+		} else {
+			item.Type = bitwardenLogin
+			item.Login = &bitwardenLoginData{
+				URIs:     []bitwardenURI{},
+				Username: optional(e.Login),
+				Password: optional(e.Password),
+			}
+			if e.SiteLink != "" {
+				item.Login.URIs = append(item.Login.URIs, bitwardenURI{URI: e.SiteLink})
+			}
+		}
+
+		export.Items = append(export.Items, item)
+	}
+
+	return json.MarshalIndent(export, "", "  ")
+}
@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"main/importer"
+	"testing"
+)
+
+func TestExportIsReadByImporter(t *testing.T) {
+	entries := []Entry{
+		{Title: "GitHub", Login: "octocat", Password: "hunter2, \"quoted\"", SiteLink: "https://github.com", Description: "work\nsecond line"},
+		{Title: "Wi-Fi", Description: "guest network"},
+	}
+
+	cases := []struct {
+		write  func([]Entry) ([]byte, error)
+		format string
+	}{
+		{BitwardenCSV, importer.FormatBitwardenCSV},
+		{BitwardenJSON, importer.FormatBitwardenJSON},
+	}
+
+	for _, c := range cases {
+		data, err := c.write(entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := importer.Parse(data)
+		if err != nil {
+			t.Fatalf("%s: %v", c.format, err)
+		}
+		if result.Format != c.format {
+			t.Fatalf("format %q, want %q", result.Format, c.format)
+		}
+		if len(result.Errors) != 0 || len(result.Entries) != len(entries) {
+			t.Fatalf("%s: entries %+v, errors %+v", c.format, result.Entries, result.Errors)
+		}
+
+		for i, want := range entries {
+			got := result.Entries[i]
+			if got.Title != want.Title || got.Login != want.Login || got.Password != want.Password ||
+				got.SiteLink != want.SiteLink || got.Description != want.Description {
+				t.Fatalf("%s: entry %d = %+v, want %+v", c.format, i, got, want)
+			}
+		}
+	}
+}
+
+func TestBitwardenCSVLayout(t *testing.T) {
+	data, err := BitwardenCSV([]Entry{
+		{Title: "GitHub", Login: "octocat", Password: "=hunter2", SiteLink: "https://github.com", Description: "work"},
+		{Title: "=HYPERLINK(\"https://evil.example\")", Description: "@note"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "folder,favorite,type,name,notes,fields,reprompt,login_uri,login_username,login_password,login_totp\n" +
+		",,login,GitHub,work,,0,https://github.com,octocat,=hunter2,\n" +
+		",,note,\"'=HYPERLINK(\"\"https://evil.example\"\")\",'@note,,0,,,,\n"
+	if string(data) != want {
+		t.Fatalf("csv:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestBitwardenJSONLayout(t *testing.T) {
+	data, err := BitwardenJSON([]Entry{
+		{Title: "GitHub", Login: "octocat", Password: "hunter2", SiteLink: "https://github.com"},
+		{Title: "Wi-Fi", Description: "guest network"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  "encrypted": false,
+  "folders": [],
+  "items": [
+    {
+      "type": 1,
+      "name": "GitHub",
+      "notes": null,
+      "favorite": false,
+      "reprompt": 0,
+      "login": {
+        "uris": [
+          {
+            "match": null,
+            "uri": "https://github.com"
+          }
+        ],
+        "username": "octocat",
+        "password": "hunter2",
+        "totp": null
+      }
+    },
+    {
+      "type": 2,
+      "name": "Wi-Fi",
+      "notes": "guest network",
+      "favorite": false,
+      "reprompt": 0,
+      "secureNote": {
+        "type": 0
+      }
+    }
+  ]
+}`
+	if string(data) != want {
+		t.Fatalf("json:\n%s\nwant:\n%s", data, want)
+	}
+}
@@ -7,18 +7,28 @@ require (
 	github.com/google/uuid v1.6.0
 )
 
-require github.com/joho/godotenv v1.5.1
+require (
+	github.com/go-pg/pg/v10 v10.14.0
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.31.0
+	modernc.org/sqlite v1.34.5
+)
 
 require (
-	github.com/go-pg/pg/v10 v10.14.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/go-pg/zerochecker v0.2.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/tmthrgd/go-hex v0.0.0-20190904060850-447a3041c3bc // indirect
 	github.com/vmihailenco/bufpool v0.1.11 // indirect
 	github.com/vmihailenco/msgpack/v5 v5.3.4 // indirect
 	github.com/vmihailenco/tagparser v0.1.2 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 	mellium.im/sasl v0.3.1 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
 )
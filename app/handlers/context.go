@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"main/database/models"
+	"main/util"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Context - обновление вместе с данными, которые подготовили middleware
+type Context struct {
+	Update      tgbotapi.Update
+	HandlerName string
+
+	User *tgbotapi.User
+	Chat *tgbotapi.Chat
+
+	// Разобранные данные нажатой кнопки, для сообщений nil
+	CallbackData map[string]any
+
+	// Код действия кнопки и ее данные, разобранные кодеком маршрута; заполняет Router
+	Action  string
+	Payload any
+
+	// Сессия пользователя в этом чате, ее заполняют LoadSession и RequireSession
+	Session *models.Sessions
+}
+
+func NewContext(update tgbotapi.Update, handlerName string) *Context {
+	ctx := &Context{
+		Update:      update,
+		HandlerName: handlerName,
+	}
+
+	if message := util.GetMessage(update); message != nil {
+		ctx.User = message.From
+		ctx.Chat = message.Chat
+	}
+
+	if update.CallbackQuery != nil {
+		var data map[string]any
+		if json.Unmarshal([]byte(update.CallbackQuery.Data), &data) == nil {
+			ctx.CallbackData = data
+		}
+	}
+
+	return ctx
+}
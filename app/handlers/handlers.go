@@ -1,36 +1,67 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+	"runtime/debug"
+	"slices"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/google/uuid"
 )
 
+// Текст, который пользователь видит, если обработчик завершился ошибкой
+const ErrorReplyText = "Что-то пошло не так. Попробуйте еще раз или отправьте /start."
+
 type Filter func(update tgbotapi.Update) bool
 
 type Callback interface {
-	Run(update tgbotapi.Update) error
+	Run(ctx *Context) error
 	GetName() string
 }
 
 type Handler interface {
 	checkType(update tgbotapi.Update) bool
 	checkFilters(update tgbotapi.Update) bool
-	run(update tgbotapi.Update) (bool, error)
+	run(update tgbotapi.Update, middleware []Middleware) (bool, error)
 	getId() uuid.UUID
+	getName() string
+}
+
+// ErrorSink получает ошибки обработчиков, например чтобы отправить их в систему мониторинга
+type ErrorSink interface {
+	Report(update tgbotapi.Update, handlerName string, err error)
+}
+
+// LogErrorSink пишет ошибки обработчиков в лог вместе с контекстом обновления
+type LogErrorSink struct{}
+
+func (LogErrorSink) Report(update tgbotapi.Update, handlerName string, err error) {
+	log.Printf("Handler %s failed (%s): %v", handlerName, describeUpdate(update), err)
+}
+
+// Requester - часть клиента Telegram, через которую пользователю сообщается об ошибке
+type Requester interface {
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
 }
 
 type BaseHandler struct {
-	uuid      uuid.UUID
-	queryType string
-	callback  Callback
-	filters   []Filter
+	uuid       uuid.UUID
+	queryType  string
+	callback   Callback
+	filters    []Filter
+	middleware []Middleware
 }
 
 func (h BaseHandler) getId() uuid.UUID {
 	return h.uuid
 }
 
+func (h BaseHandler) getName() string {
+	return h.callback.GetName()
+}
+
 func (h BaseHandler) checkType(update tgbotapi.Update) bool {
 	switch h.queryType {
 	case "message":
@@ -39,6 +70,8 @@ func (h BaseHandler) checkType(update tgbotapi.Update) bool {
 		return update.CallbackQuery != nil
 	case "command":
 		return update.Message != nil && update.Message.IsCommand()
+	case "document":
+		return update.Message != nil && update.Message.Document != nil
 	default:
 		fmt.Printf("WARNING! Unsupported query type: %s\nYou can edit handlers in handlers.go file", h.queryType)
 		return false
@@ -55,26 +88,101 @@ func (h BaseHandler) checkFilters(update tgbotapi.Update) bool {
 	return true
 }
 
-func (h BaseHandler) run(update tgbotapi.Update) (bool, error) {
+// run запускает действие, если обновление подходит обработчику. Общие middleware
+// выполняются раньше middleware самого обработчика.
+func (h BaseHandler) run(update tgbotapi.Update, middleware []Middleware) (bool, error) {
 	if h.checkType(update) && h.checkFilters(update) {
-		return true, h.callback.Run(update)
+		handler := Chain(h.callback.Run, append(slices.Clone(middleware), h.middleware...)...)
+
+		return true, handler(NewContext(update, h.callback.GetName()))
 	}
 
 	return false, nil
 }
 
 type ActiveHandlers struct {
-	Handlers []Handler
+	Handlers   []Handler
+	Middleware []Middleware // Оборачивают все обработчики
+	Client     Requester    // Если не задан, пользователю об ошибке не сообщается
+	ErrorSink  ErrorSink    // Если не задан, используется LogErrorSink
+}
+
+// safeRun запускает обработчик и превращает его панику в ошибку
+func safeRun(h Handler, update tgbotapi.Update, middleware []Middleware) (runResult bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			runResult = true
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return h.run(update, middleware)
 }
 
+// describeUpdate возвращает контекст обновления для лога. Текст сообщений не пишется, в нем может быть пароль,
+// а из данных кнопки пишется только код действия: в них лежит ключ сессии.
+func describeUpdate(update tgbotapi.Update) string {
+	switch {
+	case update.CallbackQuery != nil:
+		var data struct {
+			Action string `json:"a"`
+		}
+		json.Unmarshal([]byte(update.CallbackQuery.Data), &data)
+
+		return fmt.Sprintf("update %d, callback from user %d in chat %d, action %q",
+			update.UpdateID, update.CallbackQuery.From.ID, update.CallbackQuery.Message.Chat.ID, data.Action)
+	case update.Message != nil && update.Message.IsCommand():
+		return fmt.Sprintf("update %d, command /%s from user %d in chat %d",
+			update.UpdateID, update.Message.Command(), update.Message.From.ID, update.Message.Chat.ID)
+	case update.Message != nil:
+		return fmt.Sprintf("update %d, message from user %d in chat %d",
+			update.UpdateID, update.Message.From.ID, update.Message.Chat.ID)
+	default:
+		return fmt.Sprintf("update %d", update.UpdateID)
+	}
+}
+
+// replyWithError сообщает пользователю, что действие не удалось
+func (hl ActiveHandlers) replyWithError(update tgbotapi.Update) {
+	if hl.Client == nil {
+		return
+	}
+
+	var err error
+	switch {
+	case update.CallbackQuery != nil:
+		_, err = hl.Client.Request(tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, ErrorReplyText))
+	case update.Message != nil:
+		_, err = hl.Client.Request(tgbotapi.NewMessage(update.Message.Chat.ID, ErrorReplyText))
+	}
+
+	if err != nil {
+		log.Printf("Failed to send error reply (%s): %v", describeUpdate(update), err)
+	}
+}
+
+// HandleAll передает обновление всем подходящим обработчикам. Ошибка или паника одного
+// обработчика не мешает остальным и не останавливает бота.
 func (hl ActiveHandlers) HandleAll(update tgbotapi.Update) map[uuid.UUID]bool {
 	result := make(map[uuid.UUID]bool)
+	replied := false
+
+	sink := hl.ErrorSink
+	if sink == nil {
+		sink = LogErrorSink{}
+	}
 
 	for _, h := range hl.Handlers {
-		runResult, err := h.run(update)
+		runResult, err := safeRun(h, update, hl.Middleware)
 
 		if err != nil {
-			panic(err)
+			sink.Report(update, h.getName(), err)
+
+			// На одно обновление пользователю достаточно одного сообщения об ошибке
+			if !replied {
+				hl.replyWithError(update)
+				replied = true
+			}
 		}
 
 		result[h.getId()] = runResult
@@ -87,19 +195,22 @@ type handlerProducer struct {
 	handlerType string
 }
 
-func (p handlerProducer) Product(callback Callback, filters []Filter) BaseHandler {
+func (p handlerProducer) Product(callback Callback, filters []Filter, middleware ...Middleware) BaseHandler {
 	return BaseHandler{
-		uuid:      uuid.New(),
-		queryType: p.handlerType,
-		callback:  callback,
-		filters:   filters,
+		uuid:       uuid.New(),
+		queryType:  p.handlerType,
+		callback:   callback,
+		filters:    filters,
+		middleware: middleware,
 	}
 }
 
 const messageType = "message"
 const commandType = "command"
 const callbackQueryType = "callbackQuery"
+const documentType = "document"
 
 var MessageHandler = handlerProducer{messageType}
 var CommandHandler = handlerProducer{commandType}
 var CallbackQueryHandler = handlerProducer{callbackQueryType}
+var DocumentHandler = handlerProducer{documentType}
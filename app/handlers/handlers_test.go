@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type fakeCallback struct {
+	name  string
+	calls *int
+	err   error
+	panic bool
+}
+
+func (c fakeCallback) Run(ctx *Context) error {
+	*c.calls++
+	if c.panic {
+		panic("boom")
+	}
+
+	return c.err
+}
+
+func (c fakeCallback) GetName() string {
+	return c.name
+}
+
+type fakeRequester struct {
+	sent []tgbotapi.Chattable
+}
+
+func (r *fakeRequester) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	r.sent = append(r.sent, c)
+
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+type fakeSink struct {
+	reports []string
+}
+
+func (s *fakeSink) Report(update tgbotapi.Update, handlerName string, err error) {
+	s.reports = append(s.reports, handlerName)
+}
+
+func callbackUpdate(id int) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: id,
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "query",
+			From:    &tgbotapi.User{ID: 1},
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}},
+			Data:    `{"a":"c"}`,
+		},
+	}
+}
+
+func messageUpdate(id int) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: id,
+		Message: &tgbotapi.Message{
+			From: &tgbotapi.User{ID: 1},
+			Chat: &tgbotapi.Chat{ID: 1},
+			Text: "hello",
+		},
+	}
+}
+
+func TestHandleAllSurvivesFailingHandlers(t *testing.T) {
+	var failingCalls, panickingCalls, healthyCalls int
+
+	client := &fakeRequester{}
+	sink := &fakeSink{}
+	act := ActiveHandlers{
+		Client:    client,
+		ErrorSink: sink,
+		Handlers: []Handler{
+			CallbackQueryHandler.Product(fakeCallback{name: "failing", calls: &failingCalls, err: errors.New("telegram is down")}, nil),
+			CallbackQueryHandler.Product(fakeCallback{name: "panicking", calls: &panickingCalls, panic: true}, nil),
+			CallbackQueryHandler.Product(fakeCallback{name: "healthy", calls: &healthyCalls}, nil),
+		},
+	}
+
+	act.HandleAll(callbackUpdate(1))
+	act.HandleAll(callbackUpdate(2))
+
+	if healthyCalls != 2 {
+		t.Fatalf("healthy handler ran %d times, want 2", healthyCalls)
+	}
+	if failingCalls != 2 || panickingCalls != 2 {
+		t.Fatalf("failing handlers ran %d and %d times, want 2 each", failingCalls, panickingCalls)
+	}
+
+	if len(sink.reports) != 4 {
+		t.Fatalf("sink got %d reports, want 4: %v", len(sink.reports), sink.reports)
+	}
+
+	// Один ответ об ошибке на каждое обновление
+	if len(client.sent) != 2 {
+		t.Fatalf("sent %d error replies, want 2", len(client.sent))
+	}
+
+	alert, ok := client.sent[0].(tgbotapi.CallbackConfig)
+	if !ok || !alert.ShowAlert || alert.Text != ErrorReplyText {
+		t.Fatalf("unexpected error reply for callback: %#v", client.sent[0])
+	}
+}
+
+func TestHandleAllRepliesToMessages(t *testing.T) {
+	var calls int
+
+	client := &fakeRequester{}
+	act := ActiveHandlers{
+		Client:    client,
+		ErrorSink: &fakeSink{},
+		Handlers: []Handler{
+			MessageHandler.Product(fakeCallback{name: "failing", calls: &calls, err: errors.New("decrypt failed")}, nil),
+		},
+	}
+
+	act.HandleAll(messageUpdate(1))
+
+	if len(client.sent) != 1 {
+		t.Fatalf("sent %d error replies, want 1", len(client.sent))
+	}
+
+	reply, ok := client.sent[0].(tgbotapi.MessageConfig)
+	if !ok || reply.ChatID != 1 || reply.Text != ErrorReplyText {
+		t.Fatalf("unexpected error reply for message: %#v", client.sent[0])
+	}
+}
+
+func TestHandleAllSkipsReplyWithoutErrors(t *testing.T) {
+	var calls int
+
+	client := &fakeRequester{}
+	act := ActiveHandlers{
+		Client:   client,
+		Handlers: []Handler{CallbackQueryHandler.Product(fakeCallback{name: "healthy", calls: &calls}, nil)},
+	}
+
+	act.HandleAll(callbackUpdate(1))
+
+	if calls != 1 || len(client.sent) != 0 {
+		t.Fatalf("calls = %d, replies = %d; want 1 and 0", calls, len(client.sent))
+	}
+}
+
+func TestDocumentHandlerRunsOnlyForFiles(t *testing.T) {
+	var calls int
+
+	act := ActiveHandlers{
+		Handlers: []Handler{DocumentHandler.Product(fakeCallback{name: "import", calls: &calls}, nil)},
+	}
+
+	act.HandleAll(messageUpdate(1))
+	act.HandleAll(callbackUpdate(2))
+
+	document := messageUpdate(3)
+	document.Message.Text = ""
+	document.Message.Document = &tgbotapi.Document{FileID: "file", FileName: "export.csv"}
+	act.HandleAll(document)
+
+	if calls != 1 {
+		t.Fatalf("document handler ran %d times, want 1", calls)
+	}
+}
+
+func TestLogErrorSinkHidesSessionKey(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	update := callbackUpdate(1)
+	update.CallbackQuery.Data = `{"a":"v","k":"session-key-123","o":0}`
+
+	LogErrorSink{}.Report(update, "router", errors.New("failed"))
+
+	line := buf.String()
+	if strings.Contains(line, "session-key-123") {
+		t.Fatalf("log line %q contains the session key", line)
+	}
+	if !strings.Contains(line, `action "v"`) {
+		t.Fatalf("log line %q does not name the action", line)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"log"
+	"main/ratelimit"
+	"main/repository"
+	"main/util"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const ThrottledReplyText = "Слишком много запросов. Подождите немного."
+
+type HandlerFunc func(ctx *Context) error
+
+// Middleware оборачивает обработчик: может подготовить контекст, прервать обработку или выполнить что-то после нее
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain оборачивает обработчик в middleware. Первый middleware в списке выполняется первым.
+func Chain(handler HandlerFunc, middleware ...Middleware) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return handler
+}
+
+// AdminOnly пропускает только обновления из чата администратора, остальные молча игнорируются.
+// Обновления, подходящие под один из public, пропускаются от кого угодно, например открытие ссылки на секрет.
+func AdminOnly(adminID int64, public ...Filter) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			for _, f := range public {
+				if f(ctx.Update) {
+					return next(ctx)
+				}
+			}
+
+			if ctx.Chat == nil || ctx.Chat.ID != adminID {
+				return nil
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// Timing пишет в лог обработчики, которые выполнялись дольше threshold
+func Timing(threshold time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			start := time.Now()
+			err := next(ctx)
+
+			if elapsed := time.Since(start); elapsed > threshold {
+				log.Printf("Handler %s took %s", ctx.HandlerName, elapsed)
+			}
+
+			return err
+		}
+	}
+}
+
+// LoadSession кладет в контекст сессию пользователя в этом чате, если она есть
+func LoadSession(sessions repository.SessionRepo) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			if session, err := util.GetSession(sessions, ctx.Update); err == nil {
+				ctx.Session = session
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// RequireSession пропускает обновление дальше только при активной сессии, иначе вызывает onMissing
+func RequireSession(sessions repository.SessionRepo, onMissing HandlerFunc) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return LoadSession(sessions)(func(ctx *Context) error {
+			if ctx.Session == nil {
+				return onMissing(ctx)
+			}
+
+			return next(ctx)
+		})
+	}
+}
+
+// RateLimit ограничивает частоту сообщений и нажатий кнопок для каждого пользователя.
+// Лишние сообщения молча отбрасываются, на лишние нажатия отвечается всплывающей подсказкой.
+func RateLimit(messages, callbacks *ratelimit.Limiter, client Requester) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			if ctx.User == nil {
+				return next(ctx)
+			}
+
+			if ctx.Update.CallbackQuery != nil {
+				if !callbacks.Allow(ctx.User.ID) {
+					_, err := client.Request(tgbotapi.NewCallback(ctx.Update.CallbackQuery.ID, ThrottledReplyText))
+
+					return err
+				}
+			} else if !messages.Allow(ctx.User.ID) {
+				log.Printf("Dropped message from user %d: rate limit exceeded", ctx.User.ID)
+
+				return nil
+			}
+
+			return next(ctx)
+		}
+	}
+}
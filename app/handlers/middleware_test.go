@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"main/ratelimit"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func recordingMiddleware(name string, trace *[]string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			*trace = append(*trace, name)
+
+			return next(ctx)
+		}
+	}
+}
+
+func TestChainRunsMiddlewareInOrder(t *testing.T) {
+	trace := []string{}
+	handler := Chain(func(ctx *Context) error {
+		trace = append(trace, "handler")
+
+		return nil
+	}, recordingMiddleware("first", &trace), recordingMiddleware("second", &trace))
+
+	if err := handler(NewContext(callbackUpdate(1), "test")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trace) != 3 || trace[0] != "first" || trace[1] != "second" || trace[2] != "handler" {
+		t.Fatalf("unexpected order: %v", trace)
+	}
+}
+
+func TestAdminOnlySkipsOtherChats(t *testing.T) {
+	calls := 0
+	handler := Chain(func(ctx *Context) error {
+		calls++
+
+		return nil
+	}, AdminOnly(1))
+
+	handler(NewContext(callbackUpdate(1), "test"))
+
+	other := callbackUpdate(2)
+	other.CallbackQuery.Message = &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 2}}
+	handler(NewContext(other, "test"))
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestAdminOnlyLetsPublicUpdatesThrough(t *testing.T) {
+	calls := 0
+	handler := Chain(func(ctx *Context) error {
+		calls++
+
+		return nil
+	}, AdminOnly(1, func(update tgbotapi.Update) bool { return update.CallbackQuery.Data == "public" }))
+
+	for _, data := range []string{"public", "private"} {
+		other := callbackUpdate(2)
+		other.CallbackQuery.Message = &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 2}}
+		other.CallbackQuery.Data = data
+		handler(NewContext(other, "test"))
+	}
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want only for the public update", calls)
+	}
+}
+
+func TestNewContextDecodesCallbackData(t *testing.T) {
+	ctx := NewContext(callbackUpdate(1), "test")
+
+	if ctx.User == nil || ctx.User.ID != 1 || ctx.Chat == nil || ctx.Chat.ID != 1 {
+		t.Fatalf("user or chat not resolved: %#v", ctx)
+	}
+	if ctx.CallbackData["a"] != "c" {
+		t.Fatalf("callback data not decoded: %#v", ctx.CallbackData)
+	}
+
+	if NewContext(messageUpdate(1), "test").CallbackData != nil {
+		t.Fatal("messages must not have callback data")
+	}
+}
+
+func TestRateLimitAnswersThrottledCallbacks(t *testing.T) {
+	calls := 0
+	client := &fakeRequester{}
+	handler := Chain(func(ctx *Context) error {
+		calls++
+
+		return nil
+	}, RateLimit(ratelimit.NewLimiter(0, 1), ratelimit.NewLimiter(0, 1), client))
+
+	handler(NewContext(callbackUpdate(1), "test"))
+	handler(NewContext(callbackUpdate(2), "test"))
+	handler(NewContext(messageUpdate(3), "test"))
+	handler(NewContext(messageUpdate(4), "test"))
+
+	if calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", calls)
+	}
+
+	if len(client.sent) != 1 {
+		t.Fatalf("sent %d replies, want 1 for the throttled callback", len(client.sent))
+	}
+	if answer, ok := client.sent[0].(tgbotapi.CallbackConfig); !ok || answer.Text != ThrottledReplyText {
+		t.Fatalf("unexpected reply: %#v", client.sent[0])
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
+)
+
+// Ответ на нажатие кнопки, действие которой бот не знает, например из старого сообщения
+const StaleButtonText = "Кнопка устарела. Отправьте /start, чтобы открыть хранилище заново."
+
+// Codec разбирает данные кнопки в значение, которое действие получит в Context.Payload
+type Codec interface {
+	Decode(data string) (any, error)
+}
+
+// JSONCodec разбирает JSON данные кнопки в *T
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Decode(data string) (any, error) {
+	value := new(T)
+	err := json.Unmarshal([]byte(data), value)
+
+	return value, err
+}
+
+// Route связывает коды действий (поле "a" данных кнопки) с действием
+type Route struct {
+	Actions    []string
+	Callback   Callback
+	Codec      Codec // Если не задан, действию доступны только Context.CallbackData
+	Middleware []Middleware
+}
+
+// Router передает нажатие кнопки сразу нужному действию по его коду вместо перебора фильтров
+type Router struct {
+	uuid   uuid.UUID
+	routes map[string]Route
+	client Requester
+}
+
+func NewRouter(client Requester) *Router {
+	return &Router{
+		uuid:   uuid.New(),
+		routes: make(map[string]Route),
+		client: client,
+	}
+}
+
+// Handle регистрирует маршруты. Один код действия нельзя отдать двум действиям.
+func (r *Router) Handle(routes ...Route) {
+	for _, route := range routes {
+		for _, action := range route.Actions {
+			if existing, ok := r.routes[action]; ok {
+				panic(fmt.Sprintf("action %q is already routed to %s", action, existing.Callback.GetName()))
+			}
+
+			r.routes[action] = route
+		}
+	}
+}
+
+func (r *Router) getId() uuid.UUID {
+	return r.uuid
+}
+
+func (r *Router) getName() string {
+	return "callback-router"
+}
+
+func (r *Router) checkType(update tgbotapi.Update) bool {
+	return update.CallbackQuery != nil
+}
+
+func (r *Router) checkFilters(update tgbotapi.Update) bool {
+	return true
+}
+
+// staleButton сообщает пользователю, что кнопка больше не работает
+func (r *Router) staleButton(ctx *Context) error {
+	_, err := r.client.Request(tgbotapi.NewCallbackWithAlert(ctx.Update.CallbackQuery.ID, StaleButtonText))
+
+	return err
+}
+
+func (r *Router) run(update tgbotapi.Update, middleware []Middleware) (bool, error) {
+	if !r.checkType(update) {
+		return false, nil
+	}
+
+	ctx := NewContext(update, r.getName())
+	ctx.Action, _ = ctx.CallbackData["a"].(string)
+
+	route, ok := r.routes[ctx.Action]
+	if !ok {
+		return true, Chain(r.staleButton, middleware...)(ctx)
+	}
+
+	if route.Codec != nil {
+		payload, err := route.Codec.Decode(update.CallbackQuery.Data)
+		if err != nil {
+			return true, Chain(r.staleButton, middleware...)(ctx)
+		}
+
+		ctx.Payload = payload
+	}
+
+	ctx.HandlerName = route.Callback.GetName()
+
+	return true, Chain(route.Callback.Run, append(slices.Clone(middleware), route.Middleware...)...)(ctx)
+}
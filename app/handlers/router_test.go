@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type pageData struct {
+	Action string `json:"a"`
+	Offset int    `json:"o"`
+}
+
+type payloadCallback struct {
+	payload *pageData
+}
+
+func (c *payloadCallback) Run(ctx *Context) error {
+	c.payload = ctx.Payload.(*pageData)
+
+	return nil
+}
+
+func (c *payloadCallback) GetName() string {
+	return "payload"
+}
+
+func routedUpdate(data string) tgbotapi.Update {
+	update := callbackUpdate(1)
+	update.CallbackQuery.Data = data
+
+	return update
+}
+
+func TestRouterDispatchesByAction(t *testing.T) {
+	var pageCalls, lockCalls int
+
+	router := NewRouter(&fakeRequester{})
+	router.Handle(
+		Route{Actions: []string{"n", "p"}, Callback: fakeCallback{name: "page", calls: &pageCalls}},
+		Route{Actions: []string{"l"}, Callback: fakeCallback{name: "lock", calls: &lockCalls}},
+	)
+
+	act := ActiveHandlers{Handlers: []Handler{router}}
+	act.HandleAll(routedUpdate(`{"a":"n"}`))
+	act.HandleAll(routedUpdate(`{"a":"p"}`))
+	act.HandleAll(routedUpdate(`{"a":"l"}`))
+
+	if pageCalls != 2 || lockCalls != 1 {
+		t.Fatalf("page ran %d times and lock %d times, want 2 and 1", pageCalls, lockCalls)
+	}
+}
+
+func TestRouterDecodesPayload(t *testing.T) {
+	callback := &payloadCallback{}
+
+	router := NewRouter(&fakeRequester{})
+	router.Handle(Route{Actions: []string{"n"}, Callback: callback, Codec: JSONCodec[pageData]{}})
+
+	ActiveHandlers{Handlers: []Handler{router}}.HandleAll(routedUpdate(`{"a":"n","o":12}`))
+
+	if callback.payload == nil || callback.payload.Offset != 12 {
+		t.Fatalf("payload not decoded: %#v", callback.payload)
+	}
+}
+
+func TestRouterAnswersStaleButtons(t *testing.T) {
+	client := &fakeRequester{}
+	router := NewRouter(client)
+	router.Handle(Route{Actions: []string{"n"}, Callback: &payloadCallback{}, Codec: JSONCodec[pageData]{}})
+
+	act := ActiveHandlers{Handlers: []Handler{router}}
+	act.HandleAll(routedUpdate(`{"a":"removed"}`))
+	act.HandleAll(routedUpdate(`not json`))
+	act.HandleAll(routedUpdate(`{"a":"n","o":"not a number"}`))
+
+	if len(client.sent) != 3 {
+		t.Fatalf("sent %d replies, want 3", len(client.sent))
+	}
+
+	for _, sent := range client.sent {
+		if answer, ok := sent.(tgbotapi.CallbackConfig); !ok || answer.Text != StaleButtonText {
+			t.Fatalf("unexpected reply: %#v", sent)
+		}
+	}
+}
+
+func TestRouterRejectsDuplicateActions(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering the same action twice should panic")
+		}
+	}()
+
+	var calls int
+	router := NewRouter(&fakeRequester{})
+	router.Handle(
+		Route{Actions: []string{"n"}, Callback: fakeCallback{name: "first", calls: &calls}},
+		Route{Actions: []string{"n"}, Callback: fakeCallback{name: "second", calls: &calls}},
+	)
+}
@@ -0,0 +1,255 @@
+package i18n
+
+const (
+	LangRu = "ru"
+	LangEn = "en"
+)
+
+var Languages = []string{LangRu, LangEn}
+
+var LanguageTitles = map[string]string{
+	LangRu: "Русский",
+	LangEn: "English",
+}
+
+// Тексты бота пишутся по-русски прямо в коде, здесь лежат только переводы.
+// Если перевода нет, показывается исходный текст.
+var translations = map[string]map[string]string{
+	LangEn: {
+		// Вход
+		"Введите пароль или отправьте реплай на сообщение, текст которого содержит пароль:": "Enter your password or reply to a message that contains it:",
+		"Тебе тут не место.\n\nGo away.": "You don't belong here.\n\nGo away.",
+		"Неверный пароль.":               "Wrong password.",
+		"Слишком много неудачных попыток входа.\n\nПовторите через %s.":          "Too many failed login attempts.\n\nTry again in %s.",
+		"Слишком частые попытки. Подождите несколько секунд и попробуйте снова.": "Too many attempts. Wait a few seconds and try again.",
+		"%s\n\nВход заблокирован на %s.":                                         "%s\n\nLogin is blocked for %s.",
+
+		// Главная страница
+		"Менеджер паролей Крови Весны\nСтраница: %d // %d\n\nВыберите сервис для просмотра пароля:": "Blood of Spring password manager\nPage: %d // %d\n\nChoose a service to view its password:",
+		"Назад":         "Back",
+		"Вперед":        "Next",
+		"Заблокировать": "Lock",
+		"Настройки":     "Settings",
+		"К секретам":    "To secrets",
+		"Отмена":        "Cancel",
+
+		// Создание секрета
+		"Отправьте название секрета ниже:":                         "Send the secret title below:",
+		"Создание секрета отменено":                                "Secret creation cancelled",
+		"Отправьте ваш логин:":                                     "Send your login:",
+		"Отправьте ваш пароль:":                                    "Send your password:",
+		"Отправьте ссылку на ресурс (Или \"-\" чтобы пропустить):": "Send the site link (or \"-\" to skip):",
+		"Отправьте описание секрета (Или \"-\" чтобы пропустить):": "Send the secret description (or \"-\" to skip):",
+		"Секрет успешно создан!":                                   "Secret created!",
+
+		// Просмотр секрета
+		"Логин":            "Login",
+		"Пароль":           "Password",
+		"Где использовать": "Where to use",
+		"Описание":         "Description",
+		"Удалить":          "Delete",
+		"Показать пароль":  "Show password",
+		"Секрет удален":    "Secret deleted",
+		"Данные скрыты по истечении времени.\n\nЧтобы посмотреть их снова, откройте хранилище через /start.": "The data was hidden after a timeout.\n\nTo see it again, open the vault with /start.",
+
+		// Ссылки на секреты
+		"Поделиться": "Share",
+		"Поделиться секретом «%s»\n\nПолучатель увидит логин и пароль без доступа к хранилищу. Ссылка перестанет работать после последнего просмотра или когда истечет срок.\n\nАктивных ссылок: %d": "Share «%s»\n\nThe recipient will see the login and password without access to the vault. The link stops working after the last view or when it expires.\n\nActive links: %d",
+		"Просмотров: %d":       "Views: %d",
+		"Срок: %s":             "Expires in: %s",
+		"1 час":                "1 hour",
+		"1 день":               "1 day",
+		"7 дней":               "7 days",
+		"Создать ссылку":       "Create link",
+		"Отозвать ссылки (%d)": "Revoke links (%d)",
+		"Отозвано ссылок: %d":  "Links revoked: %d",
+		"Ссылка на «%s» создана. Перешлите ее получателю:\n\n%s\n\nПросмотров: %d, действует до %s.\nСсылка показывается только сейчас: бот хранит не ее, а хеш.": "A link to «%s» was created. Forward it to the recipient:\n\n%s\n\nViews: %d, valid until %s.\nThe link is shown only now: the bot keeps only its hash.",
+		"Ссылка недействительна: ее уже открыли, отозвали или срок ее действия истек.":                                                                            "The link is invalid: it was already opened, revoked or has expired.",
+		"\n\nОсталось просмотров по ссылке: %d.":                      "\n\nViews left for this link: %d.",
+		"\n\nЭто был последний просмотр, ссылка больше не работает.":  "\n\nThis was the last view, the link no longer works.",
+		"\nСообщение будет удалено через %d минут, сохраните данные.": "\nThis message will be deleted in %d minutes, save the data.",
+
+		// Окончание сессии
+		"Сессия скоро завершится из-за неактивности.": "The session will end soon due to inactivity.",
+		"Продлить":        "Extend",
+		"Сессия продлена": "Session extended",
+		"Сессия истекла.\n\nЧтобы продолжить работу, отправьте /start.": "The session has expired.\n\nSend /start to continue.",
+
+		// Активные сессии
+		"Активные сессии": "Active sessions",
+		"%s, чат %d":      "%s, chat %d",
+		" (эта сессия)":   " (this session)",
+		"\n%d. %s\nОткрыта: %s\nАктивность: %s\n": "\n%d. %s\nOpened: %s\nLast activity: %s\n",
+		"Завершить сессию %d":                     "End session %d",
+		"Сессия завершена":                        "Session ended",
+		"Сессия завершена с другого устройства.\n\nЧтобы продолжить работу, отправьте /start.": "The session was ended from another device.\n\nSend /start to continue.",
+
+		// Журнал
+		"Журнал":            "Journal",
+		"Событий пока нет.": "No events yet.",
+		" (чат %d)":         " (chat %d)",
+		"Новее":             "Newer",
+		"Старее":            "Older",
+		"Вход":              "Login",
+		"Неудачная попытка входа":             "Failed login",
+		"Просмотр секрета":                    "Secret viewed",
+		"Создание секрета":                    "Secret created",
+		"Изменение секрета":                   "Secret edited",
+		"Удаление секрета":                    "Secret deleted",
+		"Экспорт":                             "Export",
+		"Сессия истекла":                      "Session expired",
+		"Блокировка":                          "Lock",
+		"Экстренное удаление данных":          "Emergency wipe",
+		"Восстановление из резервной копии":   "Restore from backup",
+		"Импорт из другого менеджера паролей": "Import from another password manager",
+		"Экспорт в открытом виде":             "Plaintext export",
+		"Создание ссылки на секрет":           "Share link created",
+		"Просмотр секрета по ссылке":          "Secret viewed via share link",
+		"Отзыв ссылок на секрет":              "Share links revoked",
+
+		// Блокировка
+		"Хранилище заблокировано": "Vault locked",
+		"Хранилище заблокировано.\n\nЧтобы продолжить работу, отправьте /start.": "The vault is locked.\n\nSend /start to continue.",
+
+		// Настройки
+		"Настройки\n\nНажмите на параметр, чтобы изменить его:": "Settings\n\nTap a setting to change it:",
+		"Таймаут сессии: %s":       "Session timeout: %s",
+		"Секретов на странице: %d": "Secrets per page: %d",
+		"Сортировка: %s":           "Sort order: %s",
+		"Язык: %s":                 "Language: %s",
+		"Пароли: %s":               "Passwords: %s",
+		"Скрывать секреты: %s":     "Hide secrets: %s",
+		"через %d сек.":            "after %d sec.",
+		"никогда":                  "never",
+		"%d сек.":                  "%d sec.",
+		"сначала старые":           "oldest first",
+		"сначала новые":            "newest first",
+		"по названию":              "by title",
+		"моноширинный текст":       "monospace text",
+		"под спойлером":            "under a spoiler",
+		"маска с кнопкой \"Показать пароль\"":                                             "mask with a \"Show password\" button",
+		"Сообщения с секретами скрываются через %d сек.":                                  "Secret messages are hidden after %d sec.",
+		"Автоматическое скрытие секретов отключено.":                                      "Automatic hiding of secrets is disabled.",
+		"\n\nИзменить: /autohide <секунды от %d до %d> или /autohide 0, чтобы отключить.": "\n\nChange: /autohide <seconds from %d to %d> or /autohide 0 to disable.",
+		"Укажите число секунд от %d до %d или 0, чтобы отключить скрытие.":                "Specify a number of seconds from %d to %d or 0 to disable hiding.",
+		"Готово! Сообщения с секретами будут скрываться через %d сек.":                    "Done! Secret messages will be hidden after %d sec.",
+		"Готово! Автоматическое скрытие секретов отключено.":                              "Done! Automatic hiding of secrets is disabled.",
+		"Сейчас пароли показываются так: %s.\n\nИзменить: /display %s":                    "Passwords are currently shown as: %s.\n\nChange: /display %s",
+		"Готово! Пароли будут показываться так: %s.":                                      "Done! Passwords will be shown as: %s.",
+
+		// Отказ во входе
+		"%s\n\nGo away.": "%s\n\nGo away.",
+		"Неверный код.":  "Wrong code.",
+		"Внимание! Вход для пользователя %d (@%s) заблокирован на %s из-за неудачных попыток ввода пароля.": "Warning! Login for user %d (@%s) is blocked for %s after failed password attempts.",
+
+		// Двухфакторная аутентификация
+		"Введите код из приложения-аутентификатора или код восстановления:":                                                                          "Enter the code from your authenticator app or a recovery code:",
+		"Добавьте ключ в приложение-аутентификатор по ссылке:\n\n%s\n\nИли введите ключ вручную: %s\n\nЗатем отправьте 6-значный код из приложения:": "Add the key to your authenticator app with this link:\n\n%s\n\nOr enter the key manually: %s\n\nThen send the 6-digit code from the app:",
+		"Подключение 2FA отменено":                                                                                   "2FA setup cancelled",
+		"Неверный код. Отправьте код из приложения еще раз:":                                                         "Wrong code. Send the code from the app again:",
+		"Двухфакторная аутентификация включена!":                                                                     "Two-factor authentication is enabled!",
+		"\n\nПароль под принуждением сброшен, задайте его заново командой /duress.":                                  "\n\nThe duress password was reset, set it again with /duress.",
+		"%s\n\nОдноразовые коды восстановления (сохраните их в надежном месте, больше они показаны не будут):\n\n%s": "%s\n\nOne-time recovery codes (keep them somewhere safe, they will not be shown again):\n\n%s",
+		"Отключить": "Disable",
+		"Двухфакторная аутентификация включена.\nОсталось кодов восстановления: %d": "Two-factor authentication is enabled.\nRecovery codes left: %d",
+		"Отправьте код из приложения или код восстановления для отключения 2FA:":    "Send the code from the app or a recovery code to disable 2FA:",
+		"Неверный код. Отправьте код из приложения или код восстановления еще раз:": "Wrong code. Send the code from the app or a recovery code again:",
+		"Отключение 2FA отменено":                 "Disabling 2FA cancelled",
+		"Двухфакторная аутентификация отключена.": "Two-factor authentication is disabled.",
+
+		// Пароль под принуждением
+		"Введите мастер-пароль для настройки пароля под принуждением:":                                     "Enter your master password to set up the duress password:",
+		"Отправьте новый пароль под принуждением (или \"-\" чтобы удалить его):":                           "Send a new duress password (or \"-\" to remove it):",
+		"Пароль под принуждением должен отличаться от мастер-пароля. Отправьте другой пароль:":             "The duress password must differ from the master password. Send another password:",
+		"Пароль под принуждением не задан.":                                                                "No duress password is set.",
+		"Пароль под принуждением задан. Вход с ним откроет отдельное хранилище-приманку.\n\nПри входе: %s": "The duress password is set. Logging in with it opens a separate decoy vault.\n\nOn login: %s",
+		"ничего не делать": "do nothing",
+		"заблокировать основное хранилище на сутки": "lock the main vault for a day",
+		"удалить основное хранилище":                "delete the main vault",
+		"Сменить реакцию":                           "Change reaction",
+		"\nУведомлять чат оповещений: %s":           "\nNotify the alert chat: %s",
+		"Уведомления вкл/выкл":                      "Alerts on/off",
+		"да":                                        "yes",
+		"нет":                                       "no",
+		"Готово":                                    "Done",
+		"Внимание! Пользователь %d (@%s) вошел с паролем под принуждением.\nРеакция: %s.": "Warning! User %d (@%s) logged in with the duress password.\nReaction: %s.",
+
+		// Экстренное удаление данных
+		"Экстренное удаление данных.\n\nВведите мастер-пароль:": "Emergency wipe.\n\nEnter your master password:",
+		"Удаление данных отменено":                              "Wipe cancelled",
+		"Будут безвозвратно удалены все секреты, сессии и незавершенные действия, а также сообщения бота в этом чате.": "All secrets, sessions and pending actions will be permanently deleted, as well as the bot's messages in this chat.",
+		"Администратору будет отправлена резервная копия секретов, зашифрованная мастер-паролем.":                      "A backup of the secrets encrypted with the master password will be sent to the administrator.",
+		"Хранилище-приманка тоже будет удалено, но в копию не попадет.":                                                "The decoy vault will be deleted too, but it will not be in the backup.",
+		"%s\n\nДля подтверждения отправьте фразу:\n%s":                                                                 "%s\n\nTo confirm, send the phrase:\n%s",
+		"УДАЛИТЬ ВСЁ": "DELETE EVERYTHING",
+		"Фраза не совпадает. Удаление данных отменено.": "The phrase does not match. Wipe cancelled.",
+		"Резервная копия перед экстренным удалением данных пользователя %d. Фраза-пароль - его мастер-пароль, восстановить копию можно командой /restore.": "Backup made before the emergency wipe of user %d. The passphrase is their master password, restore the backup with /restore.",
+		"Все данные удалены.": "All data deleted.",
+
+		// Резервная копия
+		"Резервная копия хранилища.\n\nВведите мастер-пароль:": "Vault backup.\n\nEnter your master password:",
+		"Резервное копирование отменено":                       "Backup cancelled",
+		"Придумайте фразу-пароль для резервной копии, не короче %d символов. Она не связана с мастер-паролем и понадобится для восстановления:": "Choose a backup passphrase of at least %d characters. It is not tied to the master password and will be needed to restore the backup:",
+		"Фраза-пароль должна быть не короче %d символов. Придумайте другую:":                                                                    "The passphrase must be at least %d characters long. Choose another one:",
+		"Повторите фразу-пароль:":                                    "Repeat the passphrase:",
+		"Фразы-пароли не совпадают. Резервное копирование отменено.": "The passphrases do not match. Backup cancelled.",
+		"Резервная копия хранилища: %d секретов.\n\nХраните фразу-пароль отдельно от файла. Восстановить копию можно командой /restore.": "Vault backup: %d secrets.\n\nKeep the passphrase separate from the file. Restore the backup with /restore.",
+
+		// Восстановление
+		"Восстановление из резервной копии.\n\nВведите мастер-пароль:":        "Restore from backup.\n\nEnter your master password:",
+		"Восстановление отменено":                                             "Restore cancelled",
+		"Восстановление отменено.":                                            "Restore cancelled.",
+		"Отправьте файл резервной копии:":                                     "Send the backup file:",
+		"Отправьте файл резервной копии документом:":                          "Send the backup file as a document:",
+		"Файл слишком большой. Восстановление отменено.":                      "The file is too large. Restore cancelled.",
+		"Это не резервная копия хранилища. Восстановление отменено.":          "This is not a vault backup. Restore cancelled.",
+		"Введите фразу-пароль резервной копии:":                               "Enter the backup passphrase:",
+		"Неверная фраза-пароль, или файл поврежден. Восстановление отменено.": "Wrong passphrase, or the file is damaged. Restore cancelled.",
+		"Файл резервной копии поврежден. Восстановление отменено.":            "The backup file is damaged. Restore cancelled.",
+		"Резервная копия от %s: %d секретов.\n\nНовых: %d\nИзменившихся: %d\nБез изменений: %d\nЕсть только в хранилище: %d\n\nОтправьте «%s», чтобы добавить новые и обновить изменившиеся секреты, или «%s», чтобы хранилище совпало с копией: секреты, которых нет в копии, будут удалены. Любой другой ответ отменит восстановление.": "Backup from %s: %d secrets.\n\nNew: %d\nChanged: %d\nUnchanged: %d\nOnly in the vault: %d\n\nSend «%s» to add new and update changed secrets, or «%s» to make the vault match the backup: secrets missing from the backup will be deleted. Any other answer cancels the restore.",
+		"объединить": "merge",
+		"заменить":   "replace",
+		"Хранилище восстановлено.\n\nДобавлено: %d\nОбновлено: %d\nУдалено: %d": "Vault restored.\n\nAdded: %d\nUpdated: %d\nDeleted: %d",
+
+		// Импорт
+		"Импорт секретов.\n\nОтправьте в этот чат файл экспорта другого менеджера паролей: Bitwarden (JSON или CSV без пароля), KeePass (XML), Chrome, Firefox или 1Password (CSV).\n\nФайл будет удален из чата сразу после чтения.": "Import secrets.\n\nSend an export file from another password manager to this chat: Bitwarden (JSON or CSV without a password), KeePass (XML), Chrome, Firefox or 1Password (CSV).\n\nThe file is deleted from the chat right after it is read.",
+		"Файл слишком большой.":      "The file is too large.",
+		"Не удалось прочитать файл.": "Could not read the file.",
+		"Формат файла не распознан.": "Unknown file format.",
+		"Файл зашифрован. Экспортируйте хранилище без пароля и отправьте файл снова.":               "The file is encrypted. Export the vault without a password and send the file again.",
+		"В файле больше %d записей. Разделите его на части.":                                        "The file has more than %d entries. Split it into parts.",
+		"Файл %s: записей %d, с ошибками %d.\n\nВведите мастер-пароль, чтобы импортировать записи:": "%s file: %d entries, %d with errors.\n\nEnter your master password to import the entries:",
+		"Импорт отменен": "Import cancelled",
+		"Импорт завершен.\n\nДобавлено: %d\nДубликаты (пропущены): %d\nОшибки: %d": "Import finished.\n\nAdded: %d\nDuplicates (skipped): %d\nErrors: %d",
+		"%d: %s - уже есть":                    "%d: %s - already exists",
+		"\n\nПропущенные записи:\n":            "\n\nSkipped entries:\n",
+		"\nи еще %d":                           "\nand %d more",
+		"тип записи %v не поддерживается":      "entry type %v is not supported",
+		"не удалось разобрать строку":          "could not parse the row",
+		"нет названия":                         "no title",
+		"нет ни логина, ни пароля, ни заметки": "no login, password or note",
+
+		// Экспорт
+		"Экспорт в KeePass (KDBX 4).\n\nВведите мастер-пароль:": "Export to KeePass (KDBX 4).\n\nEnter your master password:",
+		"Экспорт отменен": "Export cancelled",
+		"Придумайте пароль для файла KeePass, не короче %d символов:":  "Choose a password for the KeePass file of at least %d characters:",
+		"Пароль должен быть не короче %d символов. Придумайте другой:": "The password must be at least %d characters long. Choose another one:",
+		"Повторите пароль для файла:":                                  "Repeat the file password:",
+		"Пароли не совпадают. Экспорт отменен.":                        "The passwords do not match. Export cancelled.",
+		"Хранилище": "Vault",
+		"База KeePass: %d секретов. Откройте ее в KeePassXC, KeePass или другом совместимом менеджере.\n\nСохраните файл: через %d минут он будет удален из чата.": "KeePass database: %d secrets. Open it in KeePassXC, KeePass or another compatible manager.\n\nSave the file: it will be deleted from the chat in %d minutes.",
+		"⚠️ ВНИМАНИЕ: файл будет содержать все логины и пароли в открытом виде, без шифрования.\n\nЛюбой, кто получит доступ к файлу, этому чату или устройству, куда файл будет сохранен, увидит все ваши секреты. Не пересылайте файл и удалите его сразу после использования. Не открывайте CSV в табличных редакторах: логин или пароль, начинающийся с =, +, - или @, может выполниться как формула. Для переноса в KeePass лучше использовать зашифрованный экспорт /kdbx.": "⚠️ WARNING: the file will contain all logins and passwords in plain text, without encryption.\n\nAnyone with access to the file, this chat or the device it is saved to will see all your secrets. Do not forward the file and delete it right after use. Do not open the CSV in a spreadsheet: a login or password starting with =, +, - or @ may run as a formula. To move to KeePass, prefer the encrypted /kdbx export.",
+		"Экспорт в открытом виде в формате Bitwarden.\n\nУкажите формат: /export csv или /export json.": "Plaintext export in Bitwarden format.\n\nChoose a format: /export csv or /export json.",
+		"Чтобы продолжить, введите мастер-пароль:":                                                      "To continue, enter your master password:",
+		"Экспорт в открытом виде: %d секретов.\n\n⚠️ Все пароли в файле не зашифрованы. Через %d минут файл будет удален из чата, удалите и его копии после использования.": "Plaintext export: %d secrets.\n\n⚠️ None of the passwords in the file are encrypted. The file will be deleted from the chat in %d minutes, delete its copies after use too.",
+	},
+}
+
+// T возвращает перевод текста на язык lang
+func T(lang, text string) string {
+	if translated, ok := translations[lang][text]; ok {
+		return translated
+	}
+
+	return text
+}
@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"encoding/json"
+)
+
+const (
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+)
+
+type bitwardenExport struct {
+	Encrypted bool            `json:"encrypted"`
+	Items     []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	Type  int    `json:"type"`
+	Name  string `json:"name"`
+	Notes string `json:"notes"`
+	Login *struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		URIs     []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+	} `json:"login"`
+}
+
+func parseBitwardenJSON(data []byte) (*Result, error) {
+	export := bitwardenExport{}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, ErrUnknownFormat
+	}
+	if export.Encrypted {
+		return nil, ErrEncryptedExport
+	}
+	if export.Items == nil {
+		return nil, ErrUnknownFormat
+	}
+
+	result := &Result{Format: FormatBitwardenJSON}
+	for i, item := range export.Items {
+		entry := Entry{Row: i + 1, Title: item.Name, Description: item.Notes}
+
+		switch item.Type {
+		case bitwardenLogin:
+			if item.Login != nil {
+				entry.Login = item.Login.Username
+				entry.Password = item.Login.Password
+				if len(item.Login.URIs) > 0 {
+					entry.SiteLink = item.Login.URIs[0].URI
+				}
+			}
+		case bitwardenSecureNote:
+		default:
+			result.Errors = append(result.Errors, RowError{Row: entry.Row, Reason: "тип записи %v не поддерживается", Args: []any{item.Type}})
+			continue
+		}
+
+		result.add(entry)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+)
+
+// csvFormat описывает колонки CSV одного менеджера. Для каждого поля перечислены возможные названия колонки.
+type csvFormat struct {
+	name     string
+	required []string // По этим колонкам формат узнается
+	title    []string
+	login    []string
+	password []string
+	url      []string
+	notes    []string
+	// skip отбрасывает строки, которые нельзя сохранить как секрет, например банковские карты Bitwarden
+	skip func(row map[string]string) *RowError
+}
+
+// Порядок важен: более узнаваемые форматы проверяются раньше
+var csvFormats = []csvFormat{
+	{
+		name:     FormatBitwardenCSV,
+		required: []string{"login_username", "login_password", "name"},
+		title:    []string{"name"},
+		login:    []string{"login_username"},
+		password: []string{"login_password"},
+		url:      []string{"login_uri"},
+		notes:    []string{"notes"},
+		skip: func(row map[string]string) *RowError {
+			if kind := row["type"]; kind != "" && kind != "login" && kind != "note" {
+				return &RowError{Reason: "тип записи %v не поддерживается", Args: []any{kind}}
+			}
+			return nil
+		},
+	},
+	{
+		name:     FormatFirefoxCSV,
+		required: []string{"url", "username", "password", "guid"},
+		login:    []string{"username"},
+		password: []string{"password"},
+		url:      []string{"url"},
+	},
+	{
+		name:     Format1PasswordCSV,
+		required: []string{"title", "username", "password"},
+		title:    []string{"title"},
+		login:    []string{"username"},
+		password: []string{"password"},
+		url:      []string{"url", "website", "urls"},
+		notes:    []string{"notes", "notesplain"},
+	},
+	{
+		name:     FormatChromeCSV,
+		required: []string{"name", "url", "username", "password"},
+		title:    []string{"name"},
+		login:    []string{"username"},
+		password: []string{"password"},
+		url:      []string{"url"},
+		notes:    []string{"note"},
+	},
+}
+
+func parseCSV(data []byte) (*Result, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrUnknownFormat
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	format, ok := detectCSV(header)
+	if !ok {
+		return nil, ErrUnknownFormat
+	}
+
+	result := &Result{Format: format.name}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			// Кавычки сломаны, дальше строки не разобрать
+			result.Errors = append(result.Errors, RowError{Row: parseErr.StartLine, Reason: "не удалось разобрать строку"})
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := map[string]string{}
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+
+		if format.skip != nil {
+			if rowError := format.skip(row); rowError != nil {
+				rowError.Row = line
+				result.Errors = append(result.Errors, *rowError)
+				continue
+			}
+		}
+
+		result.add(Entry{
+			Row:         line,
+			Title:       firstColumn(row, format.title),
+			Login:       firstColumn(row, format.login),
+			Password:    firstColumn(row, format.password),
+			SiteLink:    firstColumn(row, format.url),
+			Description: firstColumn(row, format.notes),
+		})
+	}
+
+	return result, nil
+}
+
+func detectCSV(header []string) (csvFormat, bool) {
+	for _, format := range csvFormats {
+		found := true
+		for _, column := range format.required {
+			if !slices.Contains(header, column) {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			return format, true
+		}
+	}
+
+	return csvFormat{}, false
+}
+
+// firstColumn возвращает первое непустое значение из колонок names
+func firstColumn(row map[string]string, names []string) string {
+	for _, name := range names {
+		if value := row[name]; value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
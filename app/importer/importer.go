@@ -0,0 +1,118 @@
+// Package importer читает файлы экспорта других менеджеров паролей: Bitwarden (JSON и CSV),
+// KeePass (XML), Chrome, Firefox и 1Password (CSV). Формат определяется по содержимому файла.
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	FormatBitwardenJSON = "Bitwarden JSON"
+	FormatBitwardenCSV  = "Bitwarden CSV"
+	FormatKeePassXML    = "KeePass XML"
+	FormatChromeCSV     = "Chrome CSV"
+	FormatFirefoxCSV    = "Firefox CSV"
+	Format1PasswordCSV  = "1Password CSV"
+
+	// Больше записей за раз не импортируется, чтобы один файл не занял бота надолго
+	MaxEntries = 5000
+)
+
+var (
+	ErrUnknownFormat   = errors.New("unknown export format")
+	ErrEncryptedExport = errors.New("export is encrypted")
+	ErrTooManyItems    = fmt.Errorf("more than %d entries", MaxEntries)
+)
+
+// Entry - запись из файла экспорта в открытом виде
+type Entry struct {
+	Row         int // Номер строки CSV или порядковый номер записи в JSON и XML
+	Title       string
+	Login       string
+	Password    string
+	SiteLink    string
+	Description string
+}
+
+// RowError - запись, которую не удалось импортировать.
+// Reason - строка формата для Args, чтобы бот мог перевести ее на язык пользователя.
+type RowError struct {
+	Row    int
+	Reason string
+	Args   []any
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, fmt.Sprintf(e.Reason, e.Args...))
+}
+
+// Result - записи из файла и ошибки в отдельных записях. Ошибка в одной записи не мешает импорту остальных.
+type Result struct {
+	Format  string
+	Entries []Entry
+	Errors  []RowError
+}
+
+// Parse определяет формат файла и читает из него записи
+func Parse(data []byte) (*Result, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(data)
+
+	var (
+		result *Result
+		err    error
+	)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		result, err = parseBitwardenJSON(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		result, err = parseKeePassXML(trimmed)
+	default:
+		result, err = parseCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries)+len(result.Errors) > MaxEntries {
+		return nil, ErrTooManyItems
+	}
+
+	return result, nil
+}
+
+// add проверяет запись и добавляет ее в результат или в ошибки
+func (r *Result) add(entry Entry) {
+	entry.Title = strings.TrimSpace(entry.Title)
+	entry.SiteLink = strings.TrimSpace(entry.SiteLink)
+
+	// У Firefox названий нет, а в других менеджерах их иногда оставляют пустыми
+	if entry.Title == "" {
+		entry.Title = hostOf(entry.SiteLink)
+	}
+
+	switch {
+	case entry.Title == "":
+		r.Errors = append(r.Errors, RowError{Row: entry.Row, Reason: "нет названия"})
+	case entry.Login == "" && entry.Password == "" && entry.Description == "":
+		r.Errors = append(r.Errors, RowError{Row: entry.Row, Reason: "нет ни логина, ни пароля, ни заметки"})
+	default:
+		r.Entries = append(r.Entries, entry)
+	}
+}
+
+func hostOf(link string) string {
+	if link == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Hostname() == "" {
+		return link
+	}
+
+	return strings.TrimPrefix(parsed.Hostname(), "www.")
+}
@@ -0,0 +1,141 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFormats(t *testing.T) {
+	cases := []struct {
+		name   string
+		data   string
+		format string
+		want   []Entry
+	}{
+		{
+			name: "bitwarden json",
+			data: `{"encrypted": false, "items": [
+				{"type": 1, "name": "GitHub", "notes": "work", "login": {"username": "octocat", "password": "hunter2", "uris": [{"uri": "https://github.com"}]}},
+				{"type": 2, "name": "Wi-Fi", "notes": "guest network"}
+			]}`,
+			format: FormatBitwardenJSON,
+			want: []Entry{
+				{Row: 1, Title: "GitHub", Login: "octocat", Password: "hunter2", SiteLink: "https://github.com", Description: "work"},
+				{Row: 2, Title: "Wi-Fi", Description: "guest network"},
+			},
+		},
+		{
+			name: "bitwarden csv",
+			data: "folder,favorite,type,name,notes,fields,reprompt,login_uri,login_username,login_password,login_totp\n" +
+				",,login,GitHub,work,,0,https://github.com,octocat,hunter2,\n",
+			format: FormatBitwardenCSV,
+			want:   []Entry{{Row: 2, Title: "GitHub", Login: "octocat", Password: "hunter2", SiteLink: "https://github.com", Description: "work"}},
+		},
+		{
+			name: "keepass xml",
+			data: `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta><RecycleBinUUID>bin</RecycleBinUUID></Meta>
+	<Root><Group><UUID>root</UUID>
+		<Entry>
+			<String><Key>Title</Key><Value>GitHub</Value></String>
+			<String><Key>UserName</Key><Value>octocat</Value></String>
+			<String><Key>Password</Key><Value>hunter2</Value></String>
+			<String><Key>URL</Key><Value>https://github.com</Value></String>
+			<String><Key>Notes</Key><Value>work</Value></String>
+			<History><Entry><String><Key>Title</Key><Value>Old</Value></String></Entry></History>
+		</Entry>
+		<Group><UUID>bin</UUID>
+			<Entry><String><Key>Title</Key><Value>Deleted</Value></String><String><Key>Password</Key><Value>x</Value></String></Entry>
+		</Group>
+	</Group></Root>
+</KeePassFile>`,
+			format: FormatKeePassXML,
+			want:   []Entry{{Row: 1, Title: "GitHub", Login: "octocat", Password: "hunter2", SiteLink: "https://github.com", Description: "work"}},
+		},
+		{
+			name:   "chrome csv",
+			data:   "name,url,username,password,note\ngithub.com,https://github.com/login,octocat,hunter2,\n",
+			format: FormatChromeCSV,
+			want:   []Entry{{Row: 2, Title: "github.com", Login: "octocat", Password: "hunter2", SiteLink: "https://github.com/login"}},
+		},
+		{
+			name: "firefox csv",
+			data: `"url","username","password","httpRealm","formActionOrigin","guid","timeCreated","timePasswordChanged","timeLastUsed"` + "\n" +
+				`"https://www.github.com","octocat","hunter2",,"https://github.com","{1}","1","1","1"` + "\n",
+			format: FormatFirefoxCSV,
+			want:   []Entry{{Row: 2, Title: "github.com", Login: "octocat", Password: "hunter2", SiteLink: "https://www.github.com"}},
+		},
+		{
+			name: "1password csv",
+			data: "\xef\xbb\xbf" + `"Title","Url","Username","Password","OTPAuth","Favorite","Archived","Tags","Notes"` + "\n" +
+				`"GitHub","https://github.com","octocat","hunter2",,"false","false",,"work"` + "\n",
+			format: Format1PasswordCSV,
+			want:   []Entry{{Row: 2, Title: "GitHub", Login: "octocat", Password: "hunter2", SiteLink: "https://github.com", Description: "work"}},
+		},
+	}
+
+	for _, c := range cases {
+		result, err := Parse([]byte(c.data))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if result.Format != c.format {
+			t.Fatalf("%s: format %q, want %q", c.name, result.Format, c.format)
+		}
+		if len(result.Errors) != 0 {
+			t.Fatalf("%s: errors %v", c.name, result.Errors)
+		}
+		if len(result.Entries) != len(c.want) {
+			t.Fatalf("%s: entries %+v, want %+v", c.name, result.Entries, c.want)
+		}
+		for i := range c.want {
+			if result.Entries[i] != c.want[i] {
+				t.Fatalf("%s: entry %d = %+v, want %+v", c.name, i, result.Entries[i], c.want[i])
+			}
+		}
+	}
+}
+
+func TestParseReportsBadRows(t *testing.T) {
+	data := "name,url,username,password\n" +
+		"GitHub,,octocat,hunter2\n" +
+		",,,\n" +
+		"Empty,https://example.com,,\n"
+
+	result, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Entries) != 1 || result.Entries[0].Title != "GitHub" {
+		t.Fatalf("entries = %+v, want only GitHub", result.Entries)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Row != 3 || result.Errors[1].Row != 4 {
+		t.Fatalf("errors = %+v, want rows 3 and 4", result.Errors)
+	}
+}
+
+func TestParseRejectsUnknownFiles(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"just some text",
+		"a,b,c\n1,2,3\n",
+		"<html><body></body></html>",
+		`{"items": null}`,
+	} {
+		if _, err := Parse([]byte(data)); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("Parse(%q) = %v, want ErrUnknownFormat", data, err)
+		}
+	}
+
+	if _, err := Parse([]byte(`{"encrypted": true, "items": []}`)); !errors.Is(err, ErrEncryptedExport) {
+		t.Errorf("encrypted Bitwarden export = %v, want ErrEncryptedExport", err)
+	}
+
+	many := "name,url,username,password\n" + strings.Repeat("a,,b,c\n", MaxEntries+1)
+	if _, err := Parse([]byte(many)); !errors.Is(err, ErrTooManyItems) {
+		t.Errorf("Parse of %d rows = %v, want ErrTooManyItems", MaxEntries+1, err)
+	}
+}
@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"encoding/xml"
+)
+
+// keePassFile - XML-экспорт KeePass 2.x. Значения в нем не защищены, даже если в базе они помечены Protected.
+type keePassFile struct {
+	XMLName xml.Name `xml:"KeePassFile"`
+	Meta    struct {
+		RecycleBinUUID string `xml:"RecycleBinUUID"`
+	} `xml:"Meta"`
+	Root struct {
+		Groups []keePassGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type keePassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Entries []keePassEntry `xml:"Entry"`
+	Groups  []keePassGroup `xml:"Group"`
+}
+
+// keePassEntry не читает History: старые версии записи импортировать не нужно
+type keePassEntry struct {
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"String"`
+}
+
+func (e keePassEntry) value(key string) string {
+	for _, s := range e.Strings {
+		if s.Key == key {
+			return s.Value
+		}
+	}
+
+	return ""
+}
+
+func parseKeePassXML(data []byte) (*Result, error) {
+	file := keePassFile{}
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, ErrUnknownFormat
+	}
+
+	result := &Result{Format: FormatKeePassXML}
+	row := 0
+
+	var walk func(groups []keePassGroup)
+	walk = func(groups []keePassGroup) {
+		for _, group := range groups {
+			// Удаленные записи лежат в корзине, их не переносим
+			if file.Meta.RecycleBinUUID != "" && group.UUID == file.Meta.RecycleBinUUID {
+				continue
+			}
+
+			for _, entry := range group.Entries {
+				row++
+				result.add(Entry{
+					Row:         row,
+					Title:       entry.value("Title"),
+					Login:       entry.value("UserName"),
+					Password:    entry.value("Password"),
+					SiteLink:    entry.value("URL"),
+					Description: entry.value("Notes"),
+				})
+			}
+
+			walk(group.Groups)
+		}
+	}
+	walk(file.Root.Groups)
+
+	return result, nil
+}
@@ -0,0 +1,236 @@
+// Package kdbx записывает базы KeePass в формате KDBX 4: ключ выводится из пароля через Argon2id,
+// содержимое сжимается gzip и шифруется ChaCha20, целостность блоков проверяется HMAC-SHA256.
+// Пароли записей дополнительно защищены внутренним потоком ChaCha20, как это делает сам KeePass.
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// Параметры Argon2id те же, что у резервных копий бота (RFC 9106 для ограниченной памяти)
+	Argon2Iterations  = 3
+	Argon2Memory      = 64 * 1024 // КиБ
+	Argon2Parallelism = 4
+
+	blockSize = 1 << 20
+
+	signature1 = 0x9AA2D903
+	signature2 = 0xB54BFB67
+	version4   = 0x00040000
+
+	headerEnd              = 0
+	headerCipherID         = 2
+	headerCompressionFlags = 3
+	headerMasterSeed       = 4
+	headerEncryptionIV     = 7
+	headerKdfParameters    = 11
+
+	innerHeaderEnd            = 0
+	innerHeaderStreamID       = 1
+	innerHeaderStreamKey      = 2
+	innerRandomStreamChaCha20 = 3
+
+	compressionGzip = 1
+)
+
+var (
+	cipherChaCha20 = []byte{0xd6, 0x03, 0x8a, 0x2b, 0x8b, 0x6f, 0x4c, 0xb5, 0xa5, 0x24, 0x33, 0x9a, 0x31, 0xdb, 0xb5, 0x9a}
+	kdfArgon2id    = []byte{0x9e, 0x29, 0x8b, 0x19, 0x56, 0xdb, 0x47, 0x73, 0xb2, 0x3d, 0xfc, 0x3e, 0xc6, 0xf0, 0xa1, 0xe6}
+)
+
+// Entry - запись базы KeePass
+type Entry struct {
+	Title     string
+	UserName  string
+	Password  string
+	URL       string
+	Notes     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Database - база из одной группы с записями
+type Database struct {
+	Name    string
+	Entries []Entry
+}
+
+// keys - ключи, выведенные из пароля и случайной соли файла
+type keys struct {
+	encryption []byte
+	hmac       []byte
+}
+
+func deriveKeys(password string, masterSeed, salt []byte, iterations, memory uint32, parallelism uint8) keys {
+	passwordHash := sha256.Sum256([]byte(password))
+	composite := sha256.Sum256(passwordHash[:])
+	transformed := argon2.IDKey(composite[:], salt, iterations, memory, parallelism, 32)
+
+	encryption := sha256.Sum256(concat(masterSeed, transformed))
+	hmacKey := sha512.Sum512(concat(masterSeed, transformed, []byte{1}))
+
+	return keys{encryption: encryption[:], hmac: hmacKey[:]}
+}
+
+// blockHMAC подписывает блок index. Заголовок подписывается как блок с номером 2^64-1.
+func (k keys) blockHMAC(index uint64, data ...[]byte) []byte {
+	blockKey := sha512.Sum512(concat(binary.LittleEndian.AppendUint64(nil, index), k.hmac))
+
+	mac := hmac.New(sha256.New, blockKey[:])
+	for _, d := range data {
+		mac.Write(d)
+	}
+
+	return mac.Sum(nil)
+}
+
+// Write шифрует базу паролем password и записывает ее в w
+func Write(w io.Writer, db Database, password string) error {
+	masterSeed := random(32)
+	iv := random(12)
+	salt := random(32)
+	streamKey := random(64)
+
+	header := &bytes.Buffer{}
+	binary.Write(header, binary.LittleEndian, []uint32{signature1, signature2, version4})
+	writeField(header, headerCipherID, cipherChaCha20)
+	writeField(header, headerCompressionFlags, binary.LittleEndian.AppendUint32(nil, compressionGzip))
+	writeField(header, headerMasterSeed, masterSeed)
+	writeField(header, headerEncryptionIV, iv)
+	writeField(header, headerKdfParameters, kdfParameters(salt))
+	writeField(header, headerEnd, []byte("\r\n\r\n"))
+
+	k := deriveKeys(password, masterSeed, salt, Argon2Iterations, Argon2Memory, Argon2Parallelism)
+
+	headerHash := sha256.Sum256(header.Bytes())
+	out := &bytes.Buffer{}
+	out.Write(header.Bytes())
+	out.Write(headerHash[:])
+	out.Write(k.blockHMAC(^uint64(0), header.Bytes()))
+
+	document, err := marshalXML(db, newProtector(streamKey))
+	if err != nil {
+		return err
+	}
+
+	inner := &bytes.Buffer{}
+	writeField(inner, innerHeaderStreamID, binary.LittleEndian.AppendUint32(nil, innerRandomStreamChaCha20))
+	writeField(inner, innerHeaderStreamKey, streamKey)
+	writeField(inner, innerHeaderEnd, nil)
+	inner.Write(document)
+
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	if _, err := gz.Write(inner.Bytes()); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(k.encryption, iv)
+	if err != nil {
+		return err
+	}
+	payload := compressed.Bytes()
+	stream.XORKeyStream(payload, payload)
+
+	// Последний блок пустой, по нему читатель понимает, что файл не обрезан
+	index := uint64(0)
+	for {
+		n := min(len(payload), blockSize)
+		block := payload[:n]
+		payload = payload[n:]
+
+		size := binary.LittleEndian.AppendUint32(nil, uint32(n))
+		out.Write(k.blockHMAC(index, binary.LittleEndian.AppendUint64(nil, index), size, block))
+		out.Write(size)
+		out.Write(block)
+		index++
+
+		if n == 0 {
+			break
+		}
+	}
+
+	_, err = w.Write(out.Bytes())
+
+	return err
+}
+
+// writeField пишет поле заголовка: номер, длина и значение. У полей внешнего и внутреннего заголовков KDBX 4 формат одинаковый.
+func writeField(w *bytes.Buffer, id byte, data []byte) {
+	w.WriteByte(id)
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+}
+
+// kdfParameters - словарь параметров Argon2id (VariantDictionary формата KDBX 4)
+func kdfParameters(salt []byte) []byte {
+	const (
+		typeUInt32    = 0x04
+		typeUInt64    = 0x05
+		typeByteArray = 0x42
+	)
+
+	d := &bytes.Buffer{}
+	binary.Write(d, binary.LittleEndian, uint16(0x0100))
+
+	item := func(valueType byte, key string, value []byte) {
+		d.WriteByte(valueType)
+		binary.Write(d, binary.LittleEndian, uint32(len(key)))
+		d.WriteString(key)
+		binary.Write(d, binary.LittleEndian, uint32(len(value)))
+		d.Write(value)
+	}
+	item(typeByteArray, "$UUID", kdfArgon2id)
+	item(typeByteArray, "S", salt)
+	item(typeUInt32, "P", binary.LittleEndian.AppendUint32(nil, Argon2Parallelism))
+	item(typeUInt64, "M", binary.LittleEndian.AppendUint64(nil, Argon2Memory*1024))
+	item(typeUInt64, "I", binary.LittleEndian.AppendUint64(nil, Argon2Iterations))
+	item(typeUInt32, "V", binary.LittleEndian.AppendUint32(nil, 0x13))
+	d.WriteByte(0)
+
+	return d.Bytes()
+}
+
+// protector шифрует защищенные значения внутренним потоком в том порядке, в каком они идут в XML
+type protector struct {
+	stream *chacha20.Cipher
+}
+
+func newProtector(streamKey []byte) *protector {
+	hash := sha512.Sum512(streamKey)
+	stream, _ := chacha20.NewUnauthenticatedCipher(hash[:32], hash[32:44])
+
+	return &protector{stream: stream}
+}
+
+func (p *protector) protect(value string) []byte {
+	data := []byte(value)
+	p.stream.XORKeyStream(data, data)
+
+	return data
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func random(size int) []byte {
+	r := make([]byte, size)
+	rand.Read(r)
+	return r
+}
@@ -0,0 +1,227 @@
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+var errBadHMAC = errors.New("hmac mismatch")
+
+// read - минимальный читатель KDBX 4, достаточный для проверки того, что пишет Write
+func read(t *testing.T, data []byte, password string) (*xmlFile, error) {
+	t.Helper()
+
+	r := bytes.NewReader(data)
+	signatures := make([]uint32, 3)
+	binary.Read(r, binary.LittleEndian, signatures)
+	if signatures[0] != signature1 || signatures[1] != signature2 || signatures[2] != version4 {
+		t.Fatalf("signatures = %x", signatures)
+	}
+
+	fields := readFields(t, r)
+	headerLength := len(data) - r.Len()
+	header := data[:headerLength]
+
+	if !bytes.Equal(fields[headerCipherID], cipherChaCha20) {
+		t.Fatalf("cipher = %x", fields[headerCipherID])
+	}
+	if binary.LittleEndian.Uint32(fields[headerCompressionFlags]) != compressionGzip {
+		t.Fatal("content is not gzip compressed")
+	}
+
+	kdf := readKDFParameters(t, fields[headerKdfParameters])
+	if !bytes.Equal(kdf["$UUID"], kdfArgon2id) {
+		t.Fatalf("kdf = %x", kdf["$UUID"])
+	}
+
+	headerHash := make([]byte, 32)
+	headerMAC := make([]byte, 32)
+	io.ReadFull(r, headerHash)
+	io.ReadFull(r, headerMAC)
+	if hash := sha256.Sum256(header); !bytes.Equal(hash[:], headerHash) {
+		t.Fatal("header hash mismatch")
+	}
+
+	k := deriveKeys(password, fields[headerMasterSeed], kdf["S"],
+		uint32(binary.LittleEndian.Uint64(kdf["I"])),
+		uint32(binary.LittleEndian.Uint64(kdf["M"])/1024),
+		uint8(binary.LittleEndian.Uint32(kdf["P"])),
+	)
+	if !hmac.Equal(k.blockHMAC(^uint64(0), header), headerMAC) {
+		return nil, errBadHMAC
+	}
+
+	payload := &bytes.Buffer{}
+	for index := uint64(0); ; index++ {
+		mac := make([]byte, 32)
+		size := make([]byte, 4)
+		io.ReadFull(r, mac)
+		io.ReadFull(r, size)
+		block := make([]byte, binary.LittleEndian.Uint32(size))
+		if _, err := io.ReadFull(r, block); err != nil {
+			t.Fatalf("block %d: %v", index, err)
+		}
+		if !hmac.Equal(k.blockHMAC(index, binary.LittleEndian.AppendUint64(nil, index), size, block), mac) {
+			return nil, errBadHMAC
+		}
+		if len(block) == 0 {
+			break
+		}
+		payload.Write(block)
+	}
+	if r.Len() != 0 {
+		t.Fatalf("%d bytes after the last block", r.Len())
+	}
+
+	stream, _ := chacha20.NewUnauthenticatedCipher(k.encryption, fields[headerEncryptionIV])
+	compressed := payload.Bytes()
+	stream.XORKeyStream(compressed, compressed)
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	innerReader := bytes.NewReader(inner)
+	innerFields := readFields(t, innerReader)
+	if binary.LittleEndian.Uint32(innerFields[innerHeaderStreamID]) != innerRandomStreamChaCha20 {
+		t.Fatal("inner stream is not ChaCha20")
+	}
+
+	file := &xmlFile{}
+	document, _ := io.ReadAll(innerReader)
+	if err := xml.Unmarshal(document, file); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newProtector(innerFields[innerHeaderStreamKey])
+	for i := range file.Root.Group.Entries {
+		for j, s := range file.Root.Group.Entries[i].Strings {
+			if s.Value.Protected != "True" {
+				continue
+			}
+			protected, err := base64.StdEncoding.DecodeString(s.Value.Text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.Root.Group.Entries[i].Strings[j].Value.Text = string(p.protect(string(protected)))
+		}
+	}
+
+	return file, nil
+}
+
+func readFields(t *testing.T, r *bytes.Reader) map[byte][]byte {
+	t.Helper()
+
+	fields := map[byte][]byte{}
+	for {
+		id, err := r.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var length uint32
+		binary.Read(r, binary.LittleEndian, &length)
+		value := make([]byte, length)
+		io.ReadFull(r, value)
+		if id == headerEnd {
+			return fields
+		}
+		fields[id] = value
+	}
+}
+
+func readKDFParameters(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+
+	r := bytes.NewReader(data)
+	var version uint16
+	binary.Read(r, binary.LittleEndian, &version)
+	if version>>8 != 1 {
+		t.Fatalf("variant dictionary version = %x", version)
+	}
+
+	params := map[string][]byte{}
+	for {
+		valueType, err := r.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valueType == 0 {
+			return params
+		}
+		var length uint32
+		binary.Read(r, binary.LittleEndian, &length)
+		key := make([]byte, length)
+		io.ReadFull(r, key)
+		binary.Read(r, binary.LittleEndian, &length)
+		value := make([]byte, length)
+		io.ReadFull(r, value)
+		params[string(key)] = value
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	created := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	db := Database{
+		Name: "Хранилище",
+		Entries: []Entry{
+			{Title: "GitHub", UserName: "octocat", Password: "hunter2", URL: "https://github.com", Notes: "work", CreatedAt: created, UpdatedAt: created},
+			{Title: "Wi-Fi", Password: "пароль & <кавычки>", Notes: "гостевая сеть"},
+			{Title: "Empty"},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Write(buf, db, "file password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := read(t, buf.Bytes(), "wrong password"); !errors.Is(err, errBadHMAC) {
+		t.Fatalf("read with wrong password = %v, want hmac mismatch", err)
+	}
+
+	file, err := read(t, buf.Bytes(), "file password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Meta.DatabaseName != db.Name || file.Root.Group.Name != db.Name {
+		t.Fatalf("database name = %q, group = %q", file.Meta.DatabaseName, file.Root.Group.Name)
+	}
+	if len(file.Root.Group.Entries) != len(db.Entries) {
+		t.Fatalf("entries = %d, want %d", len(file.Root.Group.Entries), len(db.Entries))
+	}
+
+	for i, want := range db.Entries {
+		got := map[string]string{}
+		for _, s := range file.Root.Group.Entries[i].Strings {
+			got[s.Key] = s.Value.Text
+		}
+
+		if got["Title"] != want.Title || got["UserName"] != want.UserName || got["Password"] != want.Password ||
+			got["URL"] != want.URL || got["Notes"] != want.Notes {
+			t.Fatalf("entry %d = %v, want %+v", i, got, want)
+		}
+	}
+
+	creation, _ := base64.StdEncoding.DecodeString(file.Root.Group.Entries[0].Times.CreationTime)
+	if seconds := int64(binary.LittleEndian.Uint64(creation)); time.Unix(seconds-epochOffset, 0).UTC() != created {
+		t.Fatalf("creation time = %d seconds", seconds)
+	}
+}
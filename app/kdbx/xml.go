@@ -0,0 +1,145 @@
+package kdbx
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"time"
+)
+
+const generator = "Vault Telegram Bot"
+
+// В KDBX 4 время хранится как base64 от числа секунд с 1 января 1 года
+const epochOffset = 62135596800
+
+type xmlFile struct {
+	XMLName xml.Name `xml:"KeePassFile"`
+	Meta    xmlMeta  `xml:"Meta"`
+	Root    struct {
+		Group xmlGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type xmlMeta struct {
+	Generator        string `xml:"Generator"`
+	DatabaseName     string `xml:"DatabaseName"`
+	MemoryProtection struct {
+		ProtectTitle    string `xml:"ProtectTitle"`
+		ProtectUserName string `xml:"ProtectUserName"`
+		ProtectPassword string `xml:"ProtectPassword"`
+		ProtectURL      string `xml:"ProtectURL"`
+		ProtectNotes    string `xml:"ProtectNotes"`
+	} `xml:"MemoryProtection"`
+	RecycleBinEnabled string `xml:"RecycleBinEnabled"`
+}
+
+type xmlGroup struct {
+	UUID       string     `xml:"UUID"`
+	Name       string     `xml:"Name"`
+	Times      xmlTimes   `xml:"Times"`
+	IsExpanded string     `xml:"IsExpanded"`
+	Entries    []xmlEntry `xml:"Entry"`
+}
+
+type xmlEntry struct {
+	UUID    string      `xml:"UUID"`
+	Times   xmlTimes    `xml:"Times"`
+	Strings []xmlString `xml:"String"`
+}
+
+type xmlString struct {
+	Key   string `xml:"Key"`
+	Value struct {
+		Protected string `xml:"Protected,attr,omitempty"`
+		Text      string `xml:",chardata"`
+	} `xml:"Value"`
+}
+
+type xmlTimes struct {
+	CreationTime         string `xml:"CreationTime"`
+	LastModificationTime string `xml:"LastModificationTime"`
+	LastAccessTime       string `xml:"LastAccessTime"`
+	ExpiryTime           string `xml:"ExpiryTime"`
+	Expires              string `xml:"Expires"`
+	UsageCount           int    `xml:"UsageCount"`
+	LocationChanged      string `xml:"LocationChanged"`
+}
+
+func formatTime(t time.Time) string {
+	seconds := int64(0)
+	if !t.IsZero() {
+		seconds = max(t.Unix()+epochOffset, 0)
+	}
+
+	return base64.StdEncoding.EncodeToString(binary.LittleEndian.AppendUint64(nil, uint64(seconds)))
+}
+
+func newTimes(created, modified time.Time) xmlTimes {
+	return xmlTimes{
+		CreationTime:         formatTime(created),
+		LastModificationTime: formatTime(modified),
+		LastAccessTime:       formatTime(modified),
+		ExpiryTime:           formatTime(modified),
+		Expires:              "False",
+		LocationChanged:      formatTime(created),
+	}
+}
+
+func newUUID() string {
+	return base64.StdEncoding.EncodeToString(random(16))
+}
+
+func plainString(key, value string) xmlString {
+	s := xmlString{Key: key}
+	s.Value.Text = value
+
+	return s
+}
+
+// marshalXML собирает документ базы. Пароли шифруются потоком p по мере добавления записей,
+// поэтому порядок защищенных значений совпадает с порядком в документе.
+func marshalXML(db Database, p *protector) ([]byte, error) {
+	now := time.Now()
+
+	file := xmlFile{}
+	file.Meta.Generator = generator
+	file.Meta.DatabaseName = db.Name
+	file.Meta.MemoryProtection.ProtectTitle = "False"
+	file.Meta.MemoryProtection.ProtectUserName = "False"
+	file.Meta.MemoryProtection.ProtectPassword = "True"
+	file.Meta.MemoryProtection.ProtectURL = "False"
+	file.Meta.MemoryProtection.ProtectNotes = "False"
+	file.Meta.RecycleBinEnabled = "False"
+
+	file.Root.Group = xmlGroup{
+		UUID:       newUUID(),
+		Name:       db.Name,
+		Times:      newTimes(now, now),
+		IsExpanded: "True",
+	}
+
+	for _, entry := range db.Entries {
+		password := xmlString{Key: "Password"}
+		password.Value.Protected = "True"
+		password.Value.Text = base64.StdEncoding.EncodeToString(p.protect(entry.Password))
+
+		file.Root.Group.Entries = append(file.Root.Group.Entries, xmlEntry{
+			UUID:  newUUID(),
+			Times: newTimes(entry.CreatedAt, entry.UpdatedAt),
+			Strings: []xmlString{
+				plainString("Title", entry.Title),
+				plainString("UserName", entry.UserName),
+				password,
+				plainString("URL", entry.URL),
+				plainString("Notes", entry.Notes),
+			},
+		})
+	}
+
+	document, err := xml.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), document...), nil
+}
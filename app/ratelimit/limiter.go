@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter - token bucket для каждого ключа (Telegram ID пользователя). Ведро вмещает burst
+// токенов и пополняется на rate токенов в секунду, каждое действие тратит один токен.
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[int64]*bucket
+
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[int64]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow тратит токен ключа и возвращает false, если токенов не осталось
+func (l *Limiter) Allow(key int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updatedAt: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.updatedAt).Seconds()*l.rate)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+// Prune забывает ключи, ведра которых уже успели наполниться, чтобы карта не росла бесконечно
+func (l *Limiter) Prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updatedAt).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
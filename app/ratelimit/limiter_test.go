@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterRefillsOverTime(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(1, 2)
+	l.now = func() time.Time { return now }
+
+	if !l.Allow(1) || !l.Allow(1) {
+		t.Fatal("burst should be allowed")
+	}
+	if l.Allow(1) {
+		t.Fatal("bucket should be empty")
+	}
+	if !l.Allow(2) {
+		t.Fatal("other keys have their own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if !l.Allow(1) {
+		t.Fatal("one token should be refilled after a second")
+	}
+	if l.Allow(1) {
+		t.Fatal("only one token should be refilled")
+	}
+}
+
+func TestLimiterPrunesFullBuckets(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(1, 2)
+	l.now = func() time.Time { return now }
+
+	l.Allow(1)
+	l.Prune()
+	if len(l.buckets) != 1 {
+		t.Fatal("bucket that is still refilling must be kept")
+	}
+
+	now = now.Add(time.Second)
+	l.Prune()
+	if len(l.buckets) != 0 {
+		t.Fatal("full bucket should be pruned")
+	}
+}
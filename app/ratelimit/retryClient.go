@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	MaxRetries    = 3
+	MaxRetryAfter = 60 * time.Second // Дольше ждать в обработчике обновления нет смысла
+)
+
+// RetryClient - HTTP клиент для Telegram Bot API, который при ответе 429 ждет
+// указанное в retry_after время и повторяет запрос
+type RetryClient struct {
+	Client *http.Client
+	sleep  func(time.Duration)
+}
+
+func NewRetryClient() *RetryClient {
+	return &RetryClient{Client: &http.Client{}, sleep: time.Sleep}
+}
+
+type tooManyRequestsResponse struct {
+	Parameters struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+func (c *RetryClient) Do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.Client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt == MaxRetries {
+			return resp, err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		var parsed tooManyRequestsResponse
+		json.Unmarshal(body, &parsed)
+
+		wait := time.Duration(parsed.Parameters.RetryAfter) * time.Second
+		// Загрузку файла повторить нельзя: тело запроса уже прочитано
+		if wait > MaxRetryAfter || req.GetBody == nil {
+			return resp, nil
+		}
+
+		req.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Telegram rate limit hit, retrying %s in %s", req.URL.Path, wait)
+		c.sleep(wait)
+	}
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryClientRespectsRetryAfter(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "chat_id=1" {
+			t.Errorf("request %d lost its body: %q", requests, body)
+		}
+
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"ok":false,"error_code":429,"parameters":{"retry_after":3}}`))
+
+			return
+		}
+
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer server.Close()
+
+	slept := time.Duration(0)
+	client := NewRetryClient()
+	client.sleep = func(d time.Duration) { slept += d }
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("chat_id=1"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || requests != 2 {
+		t.Fatalf("status %d after %d requests, want 200 after 2", resp.StatusCode, requests)
+	}
+	if slept != 3*time.Second {
+		t.Fatalf("slept %s, want 3s", slept)
+	}
+}
+
+func TestRetryClientGivesUpOnLongWaits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"ok":false,"error_code":429,"parameters":{"retry_after":3600}}`))
+	}))
+	defer server.Close()
+
+	client := NewRetryClient()
+	client.sleep = func(d time.Duration) { t.Fatal("must not wait for an hour") }
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("chat_id=1"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusTooManyRequests || !strings.Contains(string(body), "retry_after") {
+		t.Fatalf("the 429 response should be passed through, got %d %q", resp.StatusCode, body)
+	}
+}
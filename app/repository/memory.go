@@ -0,0 +1,816 @@
+package repository
+
+import (
+	"main/database/models"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// NewMemoryRepos возвращает хранилища в памяти процесса. Данные пропадают при перезапуске,
+// поэтому они подходят только для тестов.
+func NewMemoryRepos() Repos {
+	shares := &memoryShareRepo{}
+	secrets := &memorySecretRepo{shares: shares}
+	sessions := &memorySessionRepo{}
+
+	return Repos{
+		Users:    &memoryUserRepo{},
+		Secrets:  secrets,
+		Sessions: sessions,
+
+		Settings:         &memorySettingsRepo{},
+		LoginAttempts:    &memoryLoginAttemptRepo{},
+		BotMessages:      &memoryBotMessageRepo{},
+		ExpiringMessages: &memoryExpiringMessageRepo{},
+		Audit:            &memoryAuditRepo{},
+		Wipes:            &memoryWipeRepo{secrets: secrets, sessions: sessions},
+		Shares:           shares,
+	}
+}
+
+// columnName повторяет правило go-pg: имя из тега pg, а без него - имя поля в snake_case
+func columnName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("pg"), ",")
+	if name != "" {
+		return name
+	}
+
+	var b strings.Builder
+	for i, r := range field.Name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// copyColumns копирует в dst перечисленные колонки src, а без них - запись целиком
+func copyColumns[T any](dst, src *T, columns []string) {
+	if len(columns) == 0 {
+		*dst = *src
+		return
+	}
+
+	dstValue := reflect.ValueOf(dst).Elem()
+	srcValue := reflect.ValueOf(src).Elem()
+	for i := 0; i < dstValue.NumField(); i++ {
+		if slices.Contains(columns, columnName(dstValue.Type().Field(i))) {
+			dstValue.Field(i).Set(srcValue.Field(i))
+		}
+	}
+}
+
+type memoryUserRepo struct {
+	mu     sync.Mutex
+	lastID int64
+	users  []models.Users
+}
+
+func (r *memoryUserRepo) GetByTelegramID(telegramID int64) (*models.Users, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.TelegramID == telegramID {
+			user.RecoveryCodes = slices.Clone(user.RecoveryCodes)
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepo) Create(user *models.Users) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	user.ID = r.lastID
+	now := time.Now().Unix()
+	if user.CreatedAt == 0 {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt == 0 {
+		user.UpdatedAt = now
+	}
+
+	stored := *user
+	stored.RecoveryCodes = slices.Clone(user.RecoveryCodes)
+	r.users = append(r.users, stored)
+
+	return nil
+}
+
+func (r *memoryUserRepo) Update(user *models.Users, columns ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.users {
+		if r.users[i].ID == user.ID {
+			copyColumns(&r.users[i], user, columns)
+			r.users[i].RecoveryCodes = slices.Clone(r.users[i].RecoveryCodes)
+		}
+	}
+
+	return nil
+}
+
+type memorySecretRepo struct {
+	mu      sync.Mutex
+	lastID  int64
+	secrets []models.Secrets
+	shares  *memoryShareRepo // Ссылки удаляются вместе с секретом, как по внешнему ключу в базе
+}
+
+func (r *memorySecretRepo) Get(userID int64, isDecoy bool, id int64) (*models.Secrets, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range r.secrets {
+		if secret.ID == id && secret.UserID == userID && secret.IsDecoy == isDecoy {
+			return &secret, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (r *memorySecretRepo) vault(userID int64, isDecoy bool) []models.Secrets {
+	secrets := []models.Secrets{}
+	for _, secret := range r.secrets {
+		if secret.UserID == userID && secret.IsDecoy == isDecoy {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	return secrets
+}
+
+func (r *memorySecretRepo) List(userID int64, isDecoy bool, sortOrder string, offset, limit int) ([]models.Secrets, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secrets := r.vault(userID, isDecoy)
+	slices.SortFunc(secrets, func(a, b models.Secrets) int {
+		switch sortOrder {
+		case SortOrderNew:
+			return int(b.ID - a.ID)
+		case SortOrderTitle:
+			if c := strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)); c != 0 {
+				return c
+			}
+		}
+
+		return int(a.ID - b.ID)
+	})
+
+	offset = min(max(offset, 0), len(secrets))
+	secrets = secrets[offset:]
+	if limit > 0 && limit < len(secrets) {
+		secrets = secrets[:limit]
+	}
+
+	return secrets, nil
+}
+
+func (r *memorySecretRepo) Count(userID int64, isDecoy bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.vault(userID, isDecoy)), nil
+}
+
+func (r *memorySecretRepo) Create(secret *models.Secrets) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.create(secret)
+
+	return nil
+}
+
+func (r *memorySecretRepo) create(secret *models.Secrets) {
+	r.lastID++
+	secret.ID = r.lastID
+	now := time.Now().Unix()
+	if secret.CreatedAt == 0 {
+		secret.CreatedAt = now
+	}
+	if secret.UpdatedAt == 0 {
+		secret.UpdatedAt = now
+	}
+
+	r.secrets = append(r.secrets, *secret)
+}
+
+func (r *memorySecretRepo) deleteWhere(match func(secret models.Secrets) bool) int {
+	deleted := []int64{}
+	r.secrets = slices.DeleteFunc(r.secrets, func(secret models.Secrets) bool {
+		if match(secret) {
+			deleted = append(deleted, secret.ID)
+			return true
+		}
+		return false
+	})
+
+	if r.shares != nil {
+		r.shares.deleteWhere(func(share models.Shares) bool {
+			return slices.Contains(deleted, share.SecretID)
+		})
+	}
+
+	return len(deleted)
+}
+
+func (r *memorySecretRepo) Delete(userID int64, isDecoy bool, id int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.deleteWhere(func(secret models.Secrets) bool {
+		return secret.ID == id && secret.UserID == userID && secret.IsDecoy == isDecoy
+	}), nil
+}
+
+func (r *memorySecretRepo) DeleteAll(userID int64, isDecoy bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.deleteWhere(func(secret models.Secrets) bool {
+		return secret.UserID == userID && secret.IsDecoy == isDecoy
+	}), nil
+}
+
+func (r *memorySecretRepo) Apply(userID int64, isDecoy bool, changes SecretChanges) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Сначала проверяем обновления, чтобы при ошибке ничего не поменять
+	indexes := []int{}
+	for _, update := range changes.Update {
+		i := slices.IndexFunc(r.secrets, func(secret models.Secrets) bool {
+			return secret.ID == update.ID && secret.UserID == userID && secret.IsDecoy == isDecoy
+		})
+		if i < 0 {
+			return ErrNotFound
+		}
+		indexes = append(indexes, i)
+	}
+
+	for j, i := range indexes {
+		update := changes.Update[j]
+		update.UserID = userID
+		update.IsDecoy = isDecoy
+		update.CreatedAt = r.secrets[i].CreatedAt
+		r.secrets[i] = update
+	}
+
+	r.deleteWhere(func(secret models.Secrets) bool {
+		return slices.Contains(changes.Delete, secret.ID) && secret.UserID == userID && secret.IsDecoy == isDecoy
+	})
+
+	for i := range changes.Create {
+		secret := &changes.Create[i]
+		secret.UserID = userID
+		secret.IsDecoy = isDecoy
+		r.create(secret)
+	}
+
+	return nil
+}
+
+type memorySessionRepo struct {
+	mu       sync.Mutex
+	lastID   int64
+	sessions []models.Sessions
+}
+
+func (r *memorySessionRepo) GetByChat(userID, chatID int64) (*models.Sessions, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.ChatID == chatID {
+			return &session, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (r *memorySessionRepo) ListByUser(userID int64, isDuress bool) ([]models.Sessions, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := []models.Sessions{}
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.IsDuress == isDuress {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortStableFunc(sessions, func(a, b models.Sessions) int {
+		return int(a.CreatedAt - b.CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+func (r *memorySessionRepo) ListExpiring(within int64) ([]models.Sessions, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().Unix()
+	sessions := []models.Sessions{}
+	for _, session := range r.sessions {
+		if session.ChatID != 0 && session.WarningMessageID == 0 && session.UpdatedAt+session.ResetTimeInterval-within < now {
+			sessions = append(sessions, session)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (r *memorySessionRepo) Create(session *models.Sessions) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	session.ID = r.lastID
+	now := time.Now().Unix()
+	if session.CreatedAt == 0 {
+		session.CreatedAt = now
+	}
+	if session.UpdatedAt == 0 {
+		session.UpdatedAt = now
+	}
+	// Значения по умолчанию те же, что у колонок в Postgres
+	if session.ResetTimeInterval == 0 {
+		session.ResetTimeInterval = 10
+	}
+	if session.Source == "" {
+		session.Source = models.SessionSourceTelegram
+	}
+
+	r.sessions = append(r.sessions, *session)
+
+	return nil
+}
+
+func (r *memorySessionRepo) Update(session *models.Sessions, columns ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.sessions {
+		if r.sessions[i].ID == session.ID {
+			copyColumns(&r.sessions[i], session, columns)
+		}
+	}
+
+	return nil
+}
+
+func (r *memorySessionRepo) deleteWhere(match func(session models.Sessions) bool) []models.Sessions {
+	deleted := []models.Sessions{}
+	r.sessions = slices.DeleteFunc(r.sessions, func(session models.Sessions) bool {
+		if match(session) {
+			deleted = append(deleted, session)
+			return true
+		}
+
+		return false
+	})
+
+	return deleted
+}
+
+func (r *memorySessionRepo) Delete(userID, sessionID int64) ([]models.Sessions, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.deleteWhere(func(session models.Sessions) bool {
+		return session.ID == sessionID && session.UserID == userID
+	}), nil
+}
+
+func (r *memorySessionRepo) DeleteByChat(userID, chatID int64) ([]models.Sessions, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.deleteWhere(func(session models.Sessions) bool {
+		return session.UserID == userID && session.ChatID == chatID
+	}), nil
+}
+
+func (r *memorySessionRepo) DeleteExpired() ([]models.Sessions, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().Unix()
+
+	return r.deleteWhere(func(session models.Sessions) bool {
+		return session.UpdatedAt+session.ResetTimeInterval < now
+	}), nil
+}
+
+type memorySettingsRepo struct {
+	mu       sync.Mutex
+	lastID   int64
+	settings []models.UserSettings
+}
+
+func (r *memorySettingsRepo) Get(telegramID int64) (*models.UserSettings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, settings := range r.settings {
+		if settings.TelegramID == telegramID {
+			return &settings, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (r *memorySettingsRepo) Save(settings *models.UserSettings) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if settings.ID == 0 {
+		r.lastID++
+		settings.ID = r.lastID
+		if settings.CreatedAt == 0 {
+			settings.CreatedAt = time.Now().Unix()
+		}
+		r.settings = append(r.settings, *settings)
+
+		return nil
+	}
+
+	for i := range r.settings {
+		if r.settings[i].ID == settings.ID {
+			r.settings[i] = *settings
+		}
+	}
+
+	return nil
+}
+
+type memoryLoginAttemptRepo struct {
+	mu       sync.Mutex
+	lastID   int64
+	attempts []models.LoginAttempts
+}
+
+func (r *memoryLoginAttemptRepo) Get(telegramID int64) (*models.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, attempts := range r.attempts {
+		if attempts.TelegramID == telegramID {
+			return &attempts, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (r *memoryLoginAttemptRepo) Save(attempts *models.LoginAttempts) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempts.ID == 0 {
+		r.lastID++
+		attempts.ID = r.lastID
+		if attempts.CreatedAt == 0 {
+			attempts.CreatedAt = time.Now().Unix()
+		}
+		r.attempts = append(r.attempts, *attempts)
+
+		return nil
+	}
+
+	for i := range r.attempts {
+		if r.attempts[i].ID == attempts.ID {
+			r.attempts[i] = *attempts
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryLoginAttemptRepo) Delete(telegramID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempts = slices.DeleteFunc(r.attempts, func(attempts models.LoginAttempts) bool {
+		return attempts.TelegramID == telegramID
+	})
+
+	return nil
+}
+
+type memoryBotMessageRepo struct {
+	mu       sync.Mutex
+	lastID   int64
+	messages []models.BotMessages
+}
+
+func (r *memoryBotMessageRepo) Create(message *models.BotMessages) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	message.ID = r.lastID
+	if message.CreatedAt == 0 {
+		message.CreatedAt = time.Now().Unix()
+	}
+	r.messages = append(r.messages, *message)
+
+	return nil
+}
+
+func (r *memoryBotMessageRepo) ListByChat(chatID int64) ([]models.BotMessages, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messages := []models.BotMessages{}
+	for _, message := range r.messages {
+		if message.ChatID == chatID {
+			messages = append(messages, message)
+		}
+	}
+	slices.SortStableFunc(messages, func(a, b models.BotMessages) int {
+		return a.MessageID - b.MessageID
+	})
+
+	return messages, nil
+}
+
+func (r *memoryBotMessageRepo) DeleteByChat(chatID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = slices.DeleteFunc(r.messages, func(message models.BotMessages) bool {
+		return message.ChatID == chatID
+	})
+
+	return nil
+}
+
+func (r *memoryBotMessageRepo) DeleteCreatedBefore(before int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = slices.DeleteFunc(r.messages, func(message models.BotMessages) bool {
+		return message.CreatedAt < before
+	})
+
+	return nil
+}
+
+type memoryExpiringMessageRepo struct {
+	mu       sync.Mutex
+	lastID   int64
+	messages []models.ExpiringMessages
+}
+
+func (r *memoryExpiringMessageRepo) Create(message *models.ExpiringMessages) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	message.ID = r.lastID
+	if message.CreatedAt == 0 {
+		message.CreatedAt = time.Now().Unix()
+	}
+	r.messages = append(r.messages, *message)
+
+	return nil
+}
+
+func (r *memoryExpiringMessageRepo) ListDue(now int64) ([]models.ExpiringMessages, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messages := []models.ExpiringMessages{}
+	for _, message := range r.messages {
+		if message.ExpiresAt <= now {
+			messages = append(messages, message)
+		}
+	}
+
+	return messages, nil
+}
+
+func (r *memoryExpiringMessageRepo) deleteWhere(match func(message models.ExpiringMessages) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = slices.DeleteFunc(r.messages, match)
+
+	return nil
+}
+
+func (r *memoryExpiringMessageRepo) Delete(id int64) error {
+	return r.deleteWhere(func(message models.ExpiringMessages) bool {
+		return message.ID == id
+	})
+}
+
+func (r *memoryExpiringMessageRepo) DeleteByMessage(chatID int64, messageID int) error {
+	return r.deleteWhere(func(message models.ExpiringMessages) bool {
+		return message.ChatID == chatID && message.MessageID == messageID
+	})
+}
+
+func (r *memoryExpiringMessageRepo) DeleteByChat(chatID int64) error {
+	return r.deleteWhere(func(message models.ExpiringMessages) bool {
+		return message.ChatID == chatID
+	})
+}
+
+type memoryAuditRepo struct {
+	mu     sync.Mutex
+	events []models.AuditEvents
+}
+
+func (r *memoryAuditRepo) Append(build func(prevHash string) *models.AuditEvents) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prevHash := ""
+	if len(r.events) > 0 {
+		prevHash = r.events[len(r.events)-1].Hash
+	}
+
+	event := build(prevHash)
+	event.ID = int64(len(r.events) + 1)
+	r.events = append(r.events, *event)
+
+	return nil
+}
+
+func (r *memoryAuditRepo) ListByUser(userID int64, isDuress bool, offset, limit int) ([]models.AuditEvents, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []models.AuditEvents{}
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if r.events[i].UserID == userID && r.events[i].IsDuress == isDuress {
+			events = append(events, r.events[i])
+		}
+	}
+	count := len(events)
+
+	events = events[min(max(offset, 0), count):]
+	if limit > 0 && limit < len(events) {
+		events = events[:limit]
+	}
+
+	return events, count, nil
+}
+
+func (r *memoryAuditRepo) ListAll() ([]models.AuditEvents, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.events), nil
+}
+
+// memoryWipeRepo удаляет данные прямо из хранилищ секретов и сессий, созданных вместе с ним
+type memoryWipeRepo struct {
+	mu       sync.Mutex
+	lastID   int64
+	wipes    []models.Wipes
+	secrets  *memorySecretRepo
+	sessions *memorySessionRepo
+}
+
+func (r *memoryWipeRepo) Wipe(wipe *models.Wipes) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secretsDeleted, _ := r.secrets.DeleteAll(wipe.TelegramID, true)
+	if !wipe.IsDuress {
+		realDeleted, _ := r.secrets.DeleteAll(wipe.TelegramID, false)
+		secretsDeleted += realDeleted
+	}
+
+	r.sessions.mu.Lock()
+	sessionsDeleted := r.sessions.deleteWhere(func(session models.Sessions) bool {
+		return session.UserID == wipe.TelegramID
+	})
+	r.sessions.mu.Unlock()
+
+	wipe.SecretsDeleted = secretsDeleted
+	wipe.SessionsDeleted = len(sessionsDeleted)
+
+	r.lastID++
+	wipe.ID = r.lastID
+	if wipe.CreatedAt == 0 {
+		wipe.CreatedAt = time.Now().Unix()
+	}
+	r.wipes = append(r.wipes, *wipe)
+
+	return nil
+}
+
+func (r *memoryWipeRepo) Update(wipe *models.Wipes, columns ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.wipes {
+		if r.wipes[i].ID == wipe.ID {
+			copyColumns(&r.wipes[i], wipe, columns)
+		}
+	}
+
+	return nil
+}
+
+type memoryShareRepo struct {
+	mu     sync.Mutex
+	lastID int64
+	shares []models.Shares
+}
+
+func (r *memoryShareRepo) Create(share *models.Shares) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	share.ID = r.lastID
+	if share.CreatedAt == 0 {
+		share.CreatedAt = time.Now().Unix()
+	}
+	r.shares = append(r.shares, *share)
+
+	return nil
+}
+
+func (r *memoryShareRepo) Consume(tokenHash string, now int64) (*models.Shares, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.shares, func(share models.Shares) bool {
+		return share.TokenHash == tokenHash && share.ViewsLeft > 0 && share.ExpiresAt > now
+	})
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+
+	r.shares[i].ViewsLeft--
+	share := r.shares[i]
+	if share.ViewsLeft == 0 {
+		r.shares = slices.Delete(r.shares, i, i+1)
+	}
+
+	return &share, nil
+}
+
+func (r *memoryShareRepo) ListBySecret(userID int64, isDecoy bool, secretID int64, now int64) ([]models.Shares, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	shares := []models.Shares{}
+	for _, share := range r.shares {
+		if share.UserID == userID && share.IsDecoy == isDecoy && share.SecretID == secretID && share.ExpiresAt > now {
+			shares = append(shares, share)
+		}
+	}
+
+	return shares, nil
+}
+
+// deleteWhere удаляет подходящие ссылки и возвращает их число
+func (r *memoryShareRepo) deleteWhere(match func(share models.Shares) bool) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := len(r.shares)
+	r.shares = slices.DeleteFunc(r.shares, match)
+
+	return before - len(r.shares)
+}
+
+func (r *memoryShareRepo) DeleteBySecret(userID int64, isDecoy bool, secretID int64) (int, error) {
+	return r.deleteWhere(func(share models.Shares) bool {
+		return share.UserID == userID && share.IsDecoy == isDecoy && share.SecretID == secretID
+	}), nil
+}
+
+func (r *memoryShareRepo) DeleteExpired(now int64) error {
+	r.deleteWhere(func(share models.Shares) bool {
+		return share.ExpiresAt <= now
+	})
+
+	return nil
+}
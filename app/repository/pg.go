@@ -0,0 +1,518 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"main/database/models"
+	"slices"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// NewPgRepos возвращает хранилища поверх Postgres
+func NewPgRepos(db *pg.DB) Repos {
+	return Repos{
+		Users:    pgUserRepo{db: db},
+		Secrets:  pgSecretRepo{db: db},
+		Sessions: pgSessionRepo{db: db},
+
+		Settings:         pgSettingsRepo{db: db},
+		LoginAttempts:    pgLoginAttemptRepo{db: db},
+		BotMessages:      pgBotMessageRepo{db: db},
+		ExpiringMessages: pgExpiringMessageRepo{db: db},
+		Audit:            pgAuditRepo{db: db},
+		Wipes:            pgWipeRepo{db: db},
+		Shares:           pgShareRepo{db: db},
+	}
+}
+
+func notFound(err error) error {
+	if errors.Is(err, pg.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+type pgUserRepo struct {
+	db *pg.DB
+}
+
+func (r pgUserRepo) GetByTelegramID(telegramID int64) (*models.Users, error) {
+	user := &models.Users{}
+	err := r.db.Model(user).Where("telegram_id = ?", telegramID).Select()
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return user, nil
+}
+
+func (r pgUserRepo) Create(user *models.Users) error {
+	_, err := r.db.Model(user).Insert()
+
+	return err
+}
+
+func (r pgUserRepo) Update(user *models.Users, columns ...string) error {
+	_, err := r.db.Model(user).Column(columns...).WherePK().Update()
+
+	return err
+}
+
+type pgSecretRepo struct {
+	db *pg.DB
+}
+
+func secretsOrderExpr(sortOrder string) string {
+	switch sortOrder {
+	case SortOrderNew:
+		return "id DESC"
+	case SortOrderTitle:
+		return "lower(title) ASC, id ASC"
+	default:
+		return "id ASC"
+	}
+}
+
+func (r pgSecretRepo) Get(userID int64, isDecoy bool, id int64) (*models.Secrets, error) {
+	secret := &models.Secrets{}
+	err := r.db.Model(secret).
+		Where("id = ?", id).
+		Where("user_id = ? AND is_decoy = ?", userID, isDecoy).
+		Select()
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return secret, nil
+}
+
+func (r pgSecretRepo) List(userID int64, isDecoy bool, sortOrder string, offset, limit int) ([]models.Secrets, error) {
+	secrets := []models.Secrets{}
+	err := r.db.Model(&secrets).
+		Where("user_id = ? AND is_decoy = ?", userID, isDecoy).
+		OrderExpr(secretsOrderExpr(sortOrder)).
+		Offset(offset).
+		Limit(limit).
+		Select()
+
+	return secrets, err
+}
+
+func (r pgSecretRepo) Count(userID int64, isDecoy bool) (int, error) {
+	return r.db.Model(&models.Secrets{}).Where("user_id = ? AND is_decoy = ?", userID, isDecoy).Count()
+}
+
+func (r pgSecretRepo) Create(secret *models.Secrets) error {
+	_, err := r.db.Model(secret).Insert()
+
+	return err
+}
+
+func (r pgSecretRepo) Delete(userID int64, isDecoy bool, id int64) (int, error) {
+	result, err := r.db.Model(&models.Secrets{}).
+		Where("id = ?", id).
+		Where("user_id = ? AND is_decoy = ?", userID, isDecoy).
+		Delete()
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r pgSecretRepo) DeleteAll(userID int64, isDecoy bool) (int, error) {
+	result, err := r.db.Model(&models.Secrets{}).Where("user_id = ? AND is_decoy = ?", userID, isDecoy).Delete()
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r pgSecretRepo) Apply(userID int64, isDecoy bool, changes SecretChanges) error {
+	return r.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if len(changes.Delete) > 0 {
+			_, err := tx.Model(&models.Secrets{}).
+				Where("id IN (?)", pg.In(changes.Delete)).
+				Where("user_id = ? AND is_decoy = ?", userID, isDecoy).
+				Delete()
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := range changes.Update {
+			result, err := tx.Model(&changes.Update[i]).
+				Column(secretUpdateColumns...).
+				Where("id = ?", changes.Update[i].ID).
+				Where("user_id = ? AND is_decoy = ?", userID, isDecoy).
+				Update()
+			if err != nil {
+				return err
+			}
+			if result.RowsAffected() == 0 {
+				return ErrNotFound
+			}
+		}
+
+		for i := range changes.Create {
+			changes.Create[i].UserID = userID
+			changes.Create[i].IsDecoy = isDecoy
+		}
+
+		// Пачки ссылаются на changes.Create, поэтому id новых секретов попадают и туда
+		for batch := range slices.Chunk(changes.Create, SecretsBatchSize) {
+			_, err := tx.Model(&batch).Insert()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+type pgSessionRepo struct {
+	db *pg.DB
+}
+
+func (r pgSessionRepo) GetByChat(userID, chatID int64) (*models.Sessions, error) {
+	session := &models.Sessions{}
+	err := r.db.Model(session).Where("user_id = ? AND chat_id = ?", userID, chatID).Select()
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return session, nil
+}
+
+func (r pgSessionRepo) ListByUser(userID int64, isDuress bool) ([]models.Sessions, error) {
+	sessions := []models.Sessions{}
+	err := r.db.Model(&sessions).
+		Where("user_id = ? AND is_duress = ?", userID, isDuress).
+		Order("created_at ASC", "id ASC").
+		Select()
+
+	return sessions, err
+}
+
+func (r pgSessionRepo) ListExpiring(within int64) ([]models.Sessions, error) {
+	sessions := []models.Sessions{}
+	err := r.db.Model(&sessions).
+		Where("chat_id != 0 AND warning_message_id = 0").
+		Where("updated_at + reset_time_interval - ? < extract(epoch from now())", within).
+		Select()
+
+	return sessions, err
+}
+
+func (r pgSessionRepo) Create(session *models.Sessions) error {
+	_, err := r.db.Model(session).Insert()
+
+	return err
+}
+
+func (r pgSessionRepo) Update(session *models.Sessions, columns ...string) error {
+	_, err := r.db.Model(session).Column(columns...).WherePK().Update()
+
+	return err
+}
+
+func (r pgSessionRepo) Delete(userID, sessionID int64) ([]models.Sessions, error) {
+	sessions := []models.Sessions{}
+	_, err := r.db.Model(&sessions).
+		Where("id = ? AND user_id = ?", sessionID, userID).
+		Returning("*").
+		Delete()
+
+	return sessions, err
+}
+
+func (r pgSessionRepo) DeleteByChat(userID, chatID int64) ([]models.Sessions, error) {
+	sessions := []models.Sessions{}
+	_, err := r.db.Model(&sessions).
+		Where("user_id = ? AND chat_id = ?", userID, chatID).
+		Returning("*").
+		Delete()
+
+	return sessions, err
+}
+
+func (r pgSessionRepo) DeleteExpired() ([]models.Sessions, error) {
+	sessions := []models.Sessions{}
+	_, err := r.db.Model(&sessions).
+		Where("updated_at + reset_time_interval < extract(epoch from now())").
+		Returning("*").
+		Delete()
+
+	return sessions, err
+}
+
+type pgSettingsRepo struct {
+	db *pg.DB
+}
+
+func (r pgSettingsRepo) Get(telegramID int64) (*models.UserSettings, error) {
+	settings := &models.UserSettings{}
+	err := r.db.Model(settings).Where("telegram_id = ?", telegramID).Select()
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return settings, nil
+}
+
+func (r pgSettingsRepo) Save(settings *models.UserSettings) error {
+	var err error
+	if settings.ID == 0 {
+		_, err = r.db.Model(settings).Insert()
+	} else {
+		_, err = r.db.Model(settings).WherePK().Update()
+	}
+
+	return err
+}
+
+type pgLoginAttemptRepo struct {
+	db *pg.DB
+}
+
+func (r pgLoginAttemptRepo) Get(telegramID int64) (*models.LoginAttempts, error) {
+	attempts := &models.LoginAttempts{}
+	err := r.db.Model(attempts).Where("telegram_id = ?", telegramID).Select()
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return attempts, nil
+}
+
+func (r pgLoginAttemptRepo) Save(attempts *models.LoginAttempts) error {
+	var err error
+	if attempts.ID == 0 {
+		_, err = r.db.Model(attempts).Insert()
+	} else {
+		_, err = r.db.Model(attempts).WherePK().Update()
+	}
+
+	return err
+}
+
+func (r pgLoginAttemptRepo) Delete(telegramID int64) error {
+	_, err := r.db.Model(&models.LoginAttempts{}).Where("telegram_id = ?", telegramID).Delete()
+
+	return err
+}
+
+type pgBotMessageRepo struct {
+	db *pg.DB
+}
+
+func (r pgBotMessageRepo) Create(message *models.BotMessages) error {
+	_, err := r.db.Model(message).Insert()
+
+	return err
+}
+
+func (r pgBotMessageRepo) ListByChat(chatID int64) ([]models.BotMessages, error) {
+	messages := []models.BotMessages{}
+	err := r.db.Model(&messages).Where("chat_id = ?", chatID).Order("message_id ASC").Select()
+
+	return messages, err
+}
+
+func (r pgBotMessageRepo) DeleteByChat(chatID int64) error {
+	_, err := r.db.Model(&models.BotMessages{}).Where("chat_id = ?", chatID).Delete()
+
+	return err
+}
+
+func (r pgBotMessageRepo) DeleteCreatedBefore(before int64) error {
+	_, err := r.db.Model(&models.BotMessages{}).Where("created_at < ?", before).Delete()
+
+	return err
+}
+
+type pgExpiringMessageRepo struct {
+	db *pg.DB
+}
+
+func (r pgExpiringMessageRepo) Create(message *models.ExpiringMessages) error {
+	_, err := r.db.Model(message).Insert()
+
+	return err
+}
+
+func (r pgExpiringMessageRepo) ListDue(now int64) ([]models.ExpiringMessages, error) {
+	messages := []models.ExpiringMessages{}
+	err := r.db.Model(&messages).Where("expires_at <= ?", now).Select()
+
+	return messages, err
+}
+
+func (r pgExpiringMessageRepo) Delete(id int64) error {
+	_, err := r.db.Model(&models.ExpiringMessages{}).Where("id = ?", id).Delete()
+
+	return err
+}
+
+func (r pgExpiringMessageRepo) DeleteByMessage(chatID int64, messageID int) error {
+	_, err := r.db.Model(&models.ExpiringMessages{}).
+		Where("chat_id = ? AND message_id = ?", chatID, messageID).
+		Delete()
+
+	return err
+}
+
+func (r pgExpiringMessageRepo) DeleteByChat(chatID int64) error {
+	_, err := r.db.Model(&models.ExpiringMessages{}).Where("chat_id = ?", chatID).Delete()
+
+	return err
+}
+
+type pgAuditRepo struct {
+	db *pg.DB
+}
+
+func (r pgAuditRepo) Append(build func(prevHash string) *models.AuditEvents) error {
+	return r.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		_, err := tx.Exec("LOCK TABLE audit_events IN SHARE ROW EXCLUSIVE MODE")
+		if err != nil {
+			return err
+		}
+
+		last := &models.AuditEvents{}
+		err = tx.Model(last).Order("id DESC").Limit(1).Select()
+		if err != nil && err != pg.ErrNoRows {
+			return err
+		}
+
+		_, err = tx.Model(build(last.Hash)).Insert()
+
+		return err
+	})
+}
+
+func (r pgAuditRepo) ListByUser(userID int64, isDuress bool, offset, limit int) ([]models.AuditEvents, int, error) {
+	events := []models.AuditEvents{}
+	count, err := r.db.Model(&events).
+		Where("user_id = ? AND is_duress = ?", userID, isDuress).
+		Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		SelectAndCount()
+
+	return events, count, err
+}
+
+func (r pgAuditRepo) ListAll() ([]models.AuditEvents, error) {
+	events := []models.AuditEvents{}
+	err := r.db.Model(&events).Order("id ASC").Select()
+
+	return events, err
+}
+
+type pgWipeRepo struct {
+	db *pg.DB
+}
+
+func (r pgWipeRepo) Wipe(wipe *models.Wipes) error {
+	return r.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		secretsQuery := tx.Model(&models.Secrets{}).Where("user_id = ?", wipe.TelegramID)
+		if wipe.IsDuress {
+			secretsQuery = secretsQuery.Where("is_decoy = ?", true)
+		}
+
+		secretsResult, err := secretsQuery.Delete()
+		if err != nil {
+			return err
+		}
+
+		sessionsResult, err := tx.Model(&models.Sessions{}).Where("user_id = ?", wipe.TelegramID).Delete()
+		if err != nil {
+			return err
+		}
+
+		wipe.SecretsDeleted = secretsResult.RowsAffected()
+		wipe.SessionsDeleted = sessionsResult.RowsAffected()
+
+		_, err = tx.Model(wipe).Insert()
+
+		return err
+	})
+}
+
+func (r pgWipeRepo) Update(wipe *models.Wipes, columns ...string) error {
+	_, err := r.db.Model(wipe).Column(columns...).WherePK().Update()
+
+	return err
+}
+
+type pgShareRepo struct {
+	db *pg.DB
+}
+
+func (r pgShareRepo) Create(share *models.Shares) error {
+	_, err := r.db.Model(share).Insert()
+
+	return err
+}
+
+func (r pgShareRepo) Consume(tokenHash string, now int64) (*models.Shares, error) {
+	share := &models.Shares{}
+	err := r.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		// Просмотр списывается одним запросом, поэтому одновременные получатели не увидят одно и то же число просмотров
+		result, err := tx.Model(share).
+			Set("views_left = views_left - 1").
+			Where("token_hash = ? AND views_left > 0 AND expires_at > ?", tokenHash, now).
+			Returning("*").
+			Update()
+		if err != nil {
+			return notFound(err)
+		}
+		if result.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		if share.ViewsLeft > 0 {
+			return nil
+		}
+
+		_, err = tx.Model(share).WherePK().Delete()
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (r pgShareRepo) ListBySecret(userID int64, isDecoy bool, secretID int64, now int64) ([]models.Shares, error) {
+	shares := []models.Shares{}
+	err := r.db.Model(&shares).
+		Where("user_id = ? AND is_decoy = ? AND secret_id = ? AND expires_at > ?", userID, isDecoy, secretID, now).
+		Order("id ASC").
+		Select()
+
+	return shares, err
+}
+
+func (r pgShareRepo) DeleteBySecret(userID int64, isDecoy bool, secretID int64) (int, error) {
+	result, err := r.db.Model(&models.Shares{}).
+		Where("user_id = ? AND is_decoy = ? AND secret_id = ?", userID, isDecoy, secretID).
+		Delete()
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r pgShareRepo) DeleteExpired(now int64) error {
+	_, err := r.db.Model(&models.Shares{}).Where("expires_at <= ?", now).Delete()
+
+	return err
+}
@@ -0,0 +1,148 @@
+// Package repository отделяет работу с данными бота от конкретной базы.
+// Действия получают хранилища через Repos: в боте это Postgres или SQLite, в тестах - память.
+package repository
+
+import (
+	"errors"
+	"main/database/models"
+)
+
+const (
+	SortOrderOld   = "old"
+	SortOrderNew   = "new"
+	SortOrderTitle = "title"
+
+	// SecretsBatchSize - сколько новых секретов Apply вставляет одним запросом
+	SecretsBatchSize = 500
+)
+
+var ErrNotFound = errors.New("record not found")
+
+type UserRepo interface {
+	// GetByTelegramID возвращает ErrNotFound, если пользователя нет
+	GetByTelegramID(telegramID int64) (*models.Users, error)
+	Create(user *models.Users) error
+	// Update сохраняет перечисленные колонки, а без них - все
+	Update(user *models.Users, columns ...string) error
+}
+
+// SecretRepo работает с секретами одного хранилища: основного или приманки (isDecoy)
+type SecretRepo interface {
+	Get(userID int64, isDecoy bool, id int64) (*models.Secrets, error)
+	// List возвращает страницу секретов в порядке sortOrder, limit 0 - без ограничения
+	List(userID int64, isDecoy bool, sortOrder string, offset, limit int) ([]models.Secrets, error)
+	Count(userID int64, isDecoy bool) (int, error)
+	Create(secret *models.Secrets) error
+	// Delete и DeleteAll возвращают число удаленных секретов
+	Delete(userID int64, isDecoy bool, id int64) (int, error)
+	DeleteAll(userID int64, isDecoy bool) (int, error)
+	// Apply применяет изменения в одной транзакции: если хоть одно не удалось, хранилище не меняется.
+	// Обновляемый секрет, которого нет в хранилище, дает ErrNotFound.
+	Apply(userID int64, isDecoy bool, changes SecretChanges) error
+}
+
+// SecretChanges - набор изменений хранилища, например при восстановлении из резервной копии
+type SecretChanges struct {
+	Create []models.Secrets
+	Update []models.Secrets // Сохраняются все поля, кроме created_at
+	Delete []int64
+}
+
+// secretUpdateColumns - колонки, которые меняет SecretChanges.Update
+var secretUpdateColumns = []string{"title", "login", "password", "site_link", "description", "updated_at"}
+
+type SessionRepo interface {
+	// GetByChat возвращает сессию пользователя в чате или ErrNotFound
+	GetByChat(userID, chatID int64) (*models.Sessions, error)
+	// ListByUser возвращает сессии пользователя от старых к новым
+	ListByUser(userID int64, isDuress bool) ([]models.Sessions, error)
+	// ListExpiring возвращает сессии в чатах, которые закончатся в ближайшие within секунд
+	// и о которых еще не предупреждали
+	ListExpiring(within int64) ([]models.Sessions, error)
+	Create(session *models.Sessions) error
+	// Update сохраняет перечисленные колонки, а без них - все
+	Update(session *models.Sessions, columns ...string) error
+	// Методы удаления возвращают удаленные сессии
+	Delete(userID, sessionID int64) ([]models.Sessions, error)
+	DeleteByChat(userID, chatID int64) ([]models.Sessions, error)
+	DeleteExpired() ([]models.Sessions, error)
+}
+
+type SettingsRepo interface {
+	// Get возвращает ErrNotFound, если пользователь еще не менял настройки
+	Get(telegramID int64) (*models.UserSettings, error)
+	// Save добавляет настройки, если у них еще нет ID, а иначе обновляет
+	Save(settings *models.UserSettings) error
+}
+
+type LoginAttemptRepo interface {
+	// Get возвращает ErrNotFound, если неудачных попыток не было
+	Get(telegramID int64) (*models.LoginAttempts, error)
+	// Save добавляет запись, если у нее еще нет ID, а иначе обновляет
+	Save(attempts *models.LoginAttempts) error
+	Delete(telegramID int64) error
+}
+
+type BotMessageRepo interface {
+	Create(message *models.BotMessages) error
+	// ListByChat возвращает сообщения чата в порядке отправки
+	ListByChat(chatID int64) ([]models.BotMessages, error)
+	DeleteByChat(chatID int64) error
+	// DeleteCreatedBefore забывает сообщения, запомненные раньше момента before
+	DeleteCreatedBefore(before int64) error
+}
+
+type ExpiringMessageRepo interface {
+	Create(message *models.ExpiringMessages) error
+	// ListDue возвращает сообщения, срок которых наступил к моменту now
+	ListDue(now int64) ([]models.ExpiringMessages, error)
+	Delete(id int64) error
+	DeleteByMessage(chatID int64, messageID int) error
+	DeleteByChat(chatID int64) error
+}
+
+type AuditRepo interface {
+	// Append добавляет в конец журнала запись, которую build строит по хешу последней записи.
+	// Пока запись добавляется, другие ждут, иначе две записи могут сослаться на один и тот же хеш.
+	Append(build func(prevHash string) *models.AuditEvents) error
+	// ListByUser возвращает страницу событий пользователя от новых к старым и общее число его событий
+	ListByUser(userID int64, isDuress bool, offset, limit int) ([]models.AuditEvents, int, error)
+	// ListAll возвращает весь журнал в порядке записи
+	ListAll() ([]models.AuditEvents, error)
+}
+
+type WipeRepo interface {
+	// Wipe одной транзакцией удаляет все секреты и все сессии пользователя, записывает их количество в wipe и сохраняет его.
+	// Удаление под принуждением (wipe.IsDuress) стирает только хранилище-приманку, основное остается.
+	Wipe(wipe *models.Wipes) error
+	// Update сохраняет перечисленные колонки, а без них - все
+	Update(wipe *models.Wipes, columns ...string) error
+}
+
+// ShareRepo хранит одноразовые ссылки на секреты. Ссылки удаляются вместе со своим секретом.
+type ShareRepo interface {
+	Create(share *models.Shares) error
+	// Consume списывает просмотр ссылки с хешем ключа tokenHash и возвращает ее с оставшимся числом просмотров.
+	// Ссылка, у которой просмотров не осталось, удаляется. Истекшая к моменту now или уже удаленная ссылка дает ErrNotFound.
+	// Один просмотр не достанется двум получателям, даже если они открыли ссылку одновременно.
+	Consume(tokenHash string, now int64) (*models.Shares, error)
+	// ListBySecret возвращает ссылки на секрет, действующие к моменту now, от старых к новым
+	ListBySecret(userID int64, isDecoy bool, secretID int64, now int64) ([]models.Shares, error)
+	// DeleteBySecret отзывает все ссылки на секрет и возвращает их число
+	DeleteBySecret(userID int64, isDecoy bool, secretID int64) (int, error)
+	// DeleteExpired удаляет ссылки, истекшие к моменту now
+	DeleteExpired(now int64) error
+}
+
+type Repos struct {
+	Users            UserRepo
+	Secrets          SecretRepo
+	Sessions         SessionRepo
+	Settings         SettingsRepo
+	LoginAttempts    LoginAttemptRepo
+	BotMessages      BotMessageRepo
+	ExpiringMessages ExpiringMessageRepo
+	Audit            AuditRepo
+	Wipes            WipeRepo
+	Shares           ShareRepo
+}
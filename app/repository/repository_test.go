@@ -0,0 +1,488 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"main/database/migrations"
+	"main/database/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// newSQLiteRepos создает хранилища во временном файле SQLite со всеми миграциями
+func newSQLiteRepos(t *testing.T) Repos {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "vault.db")+"?_pragma=foreign_keys(1)&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	all, err := migrations.All("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(migrations.SQLite(db), all); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSQLiteRepos(db)
+}
+
+// forEachBackend запускает test на каждой реализации хранилищ. Секреты и сессии ссылаются на пользователя,
+// поэтому пользователи 1 и 2 создаются заранее.
+func forEachBackend(t *testing.T, test func(t *testing.T, repos Repos)) {
+	backends := map[string]func(t *testing.T) Repos{
+		"memory": func(t *testing.T) Repos { return NewMemoryRepos() },
+		"sqlite": newSQLiteRepos,
+	}
+
+	for name, newRepos := range backends {
+		t.Run(name, func(t *testing.T) {
+			repos := newRepos(t)
+			for _, telegramID := range []int64{1, 2} {
+				if err := repos.Users.Create(&models.Users{TelegramID: telegramID}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			test(t, repos)
+		})
+	}
+}
+
+func TestSecretsListAndCount(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		secrets := repos.Secrets
+
+		for _, title := range []string{"beta", "Alpha", "gamma"} {
+			if err := secrets.Create(&models.Secrets{UserID: 1, Title: title}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		secrets.Create(&models.Secrets{UserID: 1, Title: "decoy", IsDecoy: true})
+		secrets.Create(&models.Secrets{UserID: 2, Title: "other"})
+
+		titles := func(sortOrder string, offset, limit int) []string {
+			list, err := secrets.List(1, false, sortOrder, offset, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result := []string{}
+			for _, secret := range list {
+				result = append(result, secret.Title)
+			}
+
+			return result
+		}
+
+		cases := []struct {
+			sortOrder     string
+			offset, limit int
+			want          []string
+		}{
+			{SortOrderOld, 0, 0, []string{"beta", "Alpha", "gamma"}},
+			{SortOrderNew, 0, 0, []string{"gamma", "Alpha", "beta"}},
+			{SortOrderTitle, 0, 0, []string{"Alpha", "beta", "gamma"}},
+			{SortOrderOld, 1, 1, []string{"Alpha"}},
+			{SortOrderOld, 5, 2, []string{}},
+		}
+		for _, c := range cases {
+			got := titles(c.sortOrder, c.offset, c.limit)
+			if len(got) != len(c.want) {
+				t.Fatalf("List(%s, %d, %d) = %v, want %v", c.sortOrder, c.offset, c.limit, got, c.want)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Fatalf("List(%s, %d, %d) = %v, want %v", c.sortOrder, c.offset, c.limit, got, c.want)
+				}
+			}
+		}
+
+		if count, _ := secrets.Count(1, false); count != 3 {
+			t.Fatalf("Count = %d, want 3", count)
+		}
+		if count, _ := secrets.Count(1, true); count != 1 {
+			t.Fatalf("decoy Count = %d, want 1", count)
+		}
+	})
+}
+
+func TestSecretsAreScopedToVault(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		secrets := repos.Secrets
+
+		secret := &models.Secrets{UserID: 1, Title: "real"}
+		secrets.Create(secret)
+
+		if _, err := secrets.Get(1, true, secret.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("decoy vault sees a real secret: %v", err)
+		}
+		if _, err := secrets.Get(2, false, secret.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("another user sees the secret: %v", err)
+		}
+		if deleted, _ := secrets.Delete(2, false, secret.ID); deleted != 0 {
+			t.Fatal("another user deleted the secret")
+		}
+
+		got, err := secrets.Get(1, false, secret.ID)
+		if err != nil || got.Title != "real" {
+			t.Fatalf("Get = %+v, %v", got, err)
+		}
+
+		// Изменение полученной копии не должно менять хранилище
+		got.Title = "changed"
+		if again, _ := secrets.Get(1, false, secret.ID); again.Title != "real" {
+			t.Fatal("stored secret was changed through a returned copy")
+		}
+
+		if deleted, _ := secrets.DeleteAll(1, false); deleted != 1 {
+			t.Fatalf("DeleteAll = %d, want 1", deleted)
+		}
+	})
+}
+
+func TestSecretsApply(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		secrets := repos.Secrets
+
+		kept := &models.Secrets{UserID: 1, Title: "kept", CreatedAt: 100}
+		changed := &models.Secrets{UserID: 1, Title: "changed", Password: "old", CreatedAt: 100}
+		removed := &models.Secrets{UserID: 1, Title: "removed"}
+		other := &models.Secrets{UserID: 2, Title: "other"}
+		for _, secret := range []*models.Secrets{kept, changed, removed, other} {
+			secrets.Create(secret)
+		}
+
+		// Секрет другого пользователя нельзя обновить, и вся транзакция откатывается
+		err := secrets.Apply(1, false, SecretChanges{
+			Create: []models.Secrets{{Title: "not created"}},
+			Update: []models.Secrets{{ID: other.ID, Title: "stolen"}},
+			Delete: []int64{removed.ID},
+		})
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Apply with a foreign secret = %v, want ErrNotFound", err)
+		}
+		if count, _ := secrets.Count(1, false); count != 3 {
+			t.Fatalf("Count after a failed Apply = %d, want 3", count)
+		}
+
+		update := *changed
+		update.Password = "new"
+		update.CreatedAt = 0
+		update.UpdatedAt = 200
+		err = secrets.Apply(1, false, SecretChanges{
+			Create: []models.Secrets{{UserID: 2, Title: "created", CreatedAt: 50}},
+			Update: []models.Secrets{update},
+			Delete: []int64{removed.ID, other.ID},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		list, _ := secrets.List(1, false, SortOrderTitle, 0, 0)
+		if len(list) != 3 || list[0].Title != "changed" || list[1].Title != "created" || list[2].Title != "kept" {
+			t.Fatalf("secrets after Apply = %+v", list)
+		}
+		if list[0].Password != "new" || list[0].CreatedAt != 100 || list[0].UpdatedAt != 200 {
+			t.Fatalf("updated secret = %+v", list[0])
+		}
+		if list[1].CreatedAt != 50 || list[1].UserID != 1 {
+			t.Fatalf("created secret = %+v", list[1])
+		}
+		if _, err := secrets.Get(2, false, other.ID); err != nil {
+			t.Fatalf("another user's secret was deleted: %v", err)
+		}
+	})
+}
+
+func TestUserUpdateColumns(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		users := repos.Users
+
+		user := &models.Users{TelegramID: 10, PasswordHash: "hash", DuressAction: "lock"}
+		users.Create(user)
+
+		user.DuressAction = "wipe"
+		user.PasswordHash = "not saved"
+		if err := users.Update(user, "duress_action", "updated_at"); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := users.GetByTelegramID(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.DuressAction != "wipe" || stored.PasswordHash != "hash" {
+			t.Fatalf("stored user = %+v, want only duress_action updated", stored)
+		}
+
+		if _, err := users.GetByTelegramID(11); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetByTelegramID of a missing user = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestSessions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		sessions := repos.Sessions
+		now := time.Now().Unix()
+
+		active := &models.Sessions{UserID: 1, ChatID: 100, ResetTimeInterval: 1000}
+		expiring := &models.Sessions{UserID: 1, ChatID: 200, ResetTimeInterval: 20, UpdatedAt: now}
+		expired := &models.Sessions{UserID: 1, ChatID: 300, ResetTimeInterval: 10, UpdatedAt: now - 60}
+		for _, session := range []*models.Sessions{active, expiring, expired} {
+			if err := sessions.Create(session); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if active.Source != models.SessionSourceTelegram {
+			t.Fatalf("Source = %q, want the column default", active.Source)
+		}
+
+		got, err := sessions.GetByChat(1, 200)
+		if err != nil || got.ID != expiring.ID {
+			t.Fatalf("GetByChat = %+v, %v", got, err)
+		}
+
+		warn, _ := sessions.ListExpiring(30)
+		if len(warn) != 2 {
+			t.Fatalf("ListExpiring = %d sessions, want 2", len(warn))
+		}
+
+		expiring.WarningMessageID = 5
+		sessions.Update(expiring, "warning_message_id")
+		if warn, _ := sessions.ListExpiring(30); len(warn) != 1 || warn[0].ID != expired.ID {
+			t.Fatalf("warned session is listed again: %+v", warn)
+		}
+
+		deleted, _ := sessions.DeleteExpired()
+		if len(deleted) != 1 || deleted[0].ID != expired.ID {
+			t.Fatalf("DeleteExpired = %+v", deleted)
+		}
+
+		deleted, _ = sessions.DeleteByChat(1, 100)
+		if len(deleted) != 1 || deleted[0].ID != active.ID {
+			t.Fatalf("DeleteByChat = %+v", deleted)
+		}
+
+		left, _ := sessions.ListByUser(1, false)
+		if len(left) != 1 || left[0].ID != expiring.ID {
+			t.Fatalf("ListByUser = %+v", left)
+		}
+	})
+}
+
+func TestUserRecoveryCodes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		user := &models.Users{TelegramID: 3, RecoveryCodes: []string{"a", "b"}}
+		if err := repos.Users.Create(user); err != nil {
+			t.Fatal(err)
+		}
+		if user.ID == 0 || user.CreatedAt == 0 {
+			t.Fatalf("Create didn't fill ID and created_at: %+v", user)
+		}
+
+		stored, err := repos.Users.GetByTelegramID(3)
+		if err != nil || len(stored.RecoveryCodes) != 2 || stored.RecoveryCodes[1] != "b" {
+			t.Fatalf("GetByTelegramID = %+v, %v", stored, err)
+		}
+	})
+}
+
+func TestSettingsAndLoginAttempts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		if _, err := repos.Settings.Get(1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get of missing settings = %v, want ErrNotFound", err)
+		}
+
+		settings := &models.UserSettings{TelegramID: 1, PageSize: 8}
+		repos.Settings.Save(settings)
+		settings.PageSize = 10
+		if err := repos.Settings.Save(settings); err != nil {
+			t.Fatal(err)
+		}
+		if stored, _ := repos.Settings.Get(1); stored == nil || stored.PageSize != 10 {
+			t.Fatalf("stored settings = %+v, want page size 10", stored)
+		}
+
+		attempts := &models.LoginAttempts{TelegramID: 1, Failures: 1}
+		repos.LoginAttempts.Save(attempts)
+		attempts.Failures++
+		repos.LoginAttempts.Save(attempts)
+		if stored, _ := repos.LoginAttempts.Get(1); stored == nil || stored.Failures != 2 {
+			t.Fatalf("stored attempts = %+v, want 2 failures", stored)
+		}
+
+		repos.LoginAttempts.Delete(1)
+		if _, err := repos.LoginAttempts.Get(1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("attempts after Delete = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestMessages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		now := time.Now().Unix()
+
+		repos.BotMessages.Create(&models.BotMessages{ChatID: 100, MessageID: 7})
+		repos.BotMessages.Create(&models.BotMessages{ChatID: 100, MessageID: 5, CreatedAt: now - 100})
+		repos.BotMessages.Create(&models.BotMessages{ChatID: 200, MessageID: 1})
+
+		list, _ := repos.BotMessages.ListByChat(100)
+		if len(list) != 2 || list[0].MessageID != 5 || list[1].MessageID != 7 {
+			t.Fatalf("ListByChat = %+v, want messages 5 and 7", list)
+		}
+
+		repos.BotMessages.DeleteCreatedBefore(now - 50)
+		if list, _ := repos.BotMessages.ListByChat(100); len(list) != 1 || list[0].MessageID != 7 {
+			t.Fatalf("after DeleteCreatedBefore = %+v", list)
+		}
+
+		repos.ExpiringMessages.Create(&models.ExpiringMessages{ChatID: 100, MessageID: 7, ExpiresAt: now - 1})
+		repos.ExpiringMessages.Create(&models.ExpiringMessages{ChatID: 100, MessageID: 8, ExpiresAt: now + 60})
+		repos.ExpiringMessages.Create(&models.ExpiringMessages{ChatID: 200, MessageID: 1, ExpiresAt: now})
+
+		due, _ := repos.ExpiringMessages.ListDue(now)
+		if len(due) != 2 {
+			t.Fatalf("ListDue = %+v, want 2 messages", due)
+		}
+
+		repos.ExpiringMessages.Delete(due[0].ID)
+		repos.ExpiringMessages.DeleteByMessage(200, 1)
+		if due, _ := repos.ExpiringMessages.ListDue(now + 60); len(due) != 1 || due[0].MessageID != 8 {
+			t.Fatalf("ListDue after deletes = %+v", due)
+		}
+
+		repos.ExpiringMessages.DeleteByChat(100)
+		if due, _ := repos.ExpiringMessages.ListDue(now + 60); len(due) != 0 {
+			t.Fatalf("ListDue after DeleteByChat = %+v", due)
+		}
+	})
+}
+
+func TestAuditAppend(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		for i, userID := range []int64{1, 2, 1} {
+			err := repos.Audit.Append(func(prevHash string) *models.AuditEvents {
+				return &models.AuditEvents{UserID: userID, PrevHash: prevHash, Hash: string(rune('a' + i))}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		all, _ := repos.Audit.ListAll()
+		if len(all) != 3 || all[0].PrevHash != "" || all[1].PrevHash != "a" || all[2].PrevHash != "b" {
+			t.Fatalf("ListAll = %+v, want a chain of 3 events", all)
+		}
+
+		page, count, err := repos.Audit.ListByUser(1, false, 0, 1)
+		if err != nil || count != 2 || len(page) != 1 || page[0].Hash != "c" {
+			t.Fatalf("ListByUser = %+v, %d, %v, want the newest of 2 events", page, count, err)
+		}
+	})
+}
+
+func TestWipe(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		repos.Secrets.Create(&models.Secrets{UserID: 1, Title: "real"})
+		repos.Secrets.Create(&models.Secrets{UserID: 1, Title: "decoy", IsDecoy: true})
+		repos.Sessions.Create(&models.Sessions{UserID: 1, ChatID: 100})
+		repos.Sessions.Create(&models.Sessions{UserID: 1, ChatID: 200, IsDuress: true})
+
+		wipe := &models.Wipes{TelegramID: 1, ChatID: 100, IsDuress: true}
+		if err := repos.Wipes.Wipe(wipe); err != nil {
+			t.Fatal(err)
+		}
+		if wipe.ID == 0 || wipe.SecretsDeleted != 1 || wipe.SessionsDeleted != 2 {
+			t.Fatalf("wipe = %+v, want 1 secret and 2 sessions deleted", wipe)
+		}
+
+		if count, _ := repos.Secrets.Count(1, false); count != 1 {
+			t.Fatal("duress wipe deleted the real vault")
+		}
+
+		wipe.MessagesDeleted = 3
+		if err := repos.Wipes.Update(wipe, "messages_deleted"); err != nil {
+			t.Fatal(err)
+		}
+
+		// С настоящим паролем удаляются оба хранилища
+		repos.Secrets.Create(&models.Secrets{UserID: 1, Title: "decoy", IsDecoy: true})
+		repos.Secrets.Create(&models.Secrets{UserID: 2, Title: "other user"})
+
+		wipe = &models.Wipes{TelegramID: 1, ChatID: 100}
+		if err := repos.Wipes.Wipe(wipe); err != nil {
+			t.Fatal(err)
+		}
+		if wipe.SecretsDeleted != 2 {
+			t.Fatalf("wipe = %+v, want both vaults deleted", wipe)
+		}
+		for _, isDecoy := range []bool{false, true} {
+			if count, _ := repos.Secrets.Count(1, isDecoy); count != 0 {
+				t.Fatalf("%d secrets left in vault isDecoy=%v", count, isDecoy)
+			}
+		}
+		if count, _ := repos.Secrets.Count(2, false); count != 1 {
+			t.Fatal("wipe deleted another user's secrets")
+		}
+	})
+}
+
+func TestShares(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repos) {
+		now := time.Now().Unix()
+
+		secret := &models.Secrets{UserID: 1, Title: "shared"}
+		other := &models.Secrets{UserID: 1, Title: "other"}
+		repos.Secrets.Create(secret)
+		repos.Secrets.Create(other)
+
+		twice := &models.Shares{UserID: 1, SecretID: secret.ID, TokenHash: "twice", Payload: "p", ViewsLeft: 2, ExpiresAt: now + 60}
+		expired := &models.Shares{UserID: 1, SecretID: secret.ID, TokenHash: "expired", Payload: "p", ViewsLeft: 1, ExpiresAt: now - 1}
+		doomed := &models.Shares{UserID: 1, SecretID: other.ID, TokenHash: "doomed", Payload: "p", ViewsLeft: 1, ExpiresAt: now + 60}
+		for _, share := range []*models.Shares{twice, expired, doomed} {
+			if err := repos.Shares.Create(share); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if list, _ := repos.Shares.ListBySecret(1, false, secret.ID, now); len(list) != 1 || list[0].ID != twice.ID {
+			t.Fatalf("ListBySecret = %+v, want only the active share", list)
+		}
+
+		for _, want := range []int{1, 0} {
+			share, err := repos.Shares.Consume("twice", now)
+			if err != nil || share.ViewsLeft != want || share.Payload != "p" {
+				t.Fatalf("Consume = %+v, %v, want %d views left", share, err, want)
+			}
+		}
+		if _, err := repos.Shares.Consume("twice", now); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("third Consume = %v, want ErrNotFound", err)
+		}
+		if _, err := repos.Shares.Consume("expired", now); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Consume of an expired share = %v, want ErrNotFound", err)
+		}
+
+		// Удаление секрета отзывает ссылки на него
+		repos.Secrets.Delete(1, false, other.ID)
+		if _, err := repos.Shares.Consume("doomed", now); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Consume after the secret was deleted = %v, want ErrNotFound", err)
+		}
+
+		if err := repos.Shares.DeleteExpired(now); err != nil {
+			t.Fatal(err)
+		}
+
+		repos.Shares.Create(&models.Shares{UserID: 1, SecretID: secret.ID, TokenHash: "revoked", Payload: "p", ViewsLeft: 1, ExpiresAt: now + 60})
+		if deleted, err := repos.Shares.DeleteBySecret(1, false, secret.ID); err != nil || deleted != 1 {
+			t.Fatalf("DeleteBySecret = %d, %v, want only the share left after DeleteExpired", deleted, err)
+		}
+	})
+}
@@ -0,0 +1,624 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/database/models"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewSQLiteRepos возвращает хранилища поверх SQLite. Таблицы те же, что в Postgres,
+// их создают миграции из database/migrations/sql/sqlite.
+func NewSQLiteRepos(db *sql.DB) Repos {
+	return Repos{
+		Users:    sqliteUserRepo{db: db},
+		Secrets:  sqliteSecretRepo{db: db},
+		Sessions: sqliteSessionRepo{db: db},
+
+		Settings:         sqliteSettingsRepo{db: db},
+		LoginAttempts:    sqliteLoginAttemptRepo{db: db},
+		BotMessages:      sqliteBotMessageRepo{db: db},
+		ExpiringMessages: sqliteExpiringMessageRepo{db: db},
+		Audit:            sqliteAuditRepo{db: db},
+		Wipes:            sqliteWipeRepo{db: db},
+		Shares:           sqliteShareRepo{db: db},
+	}
+}
+
+// sqliteQuerier - общее у *sql.DB и *sql.Tx
+type sqliteQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// sqliteStrings хранит []string в колонке text как JSON-массив
+type sqliteStrings struct {
+	value *[]string
+}
+
+func (s sqliteStrings) Value() (driver.Value, error) {
+	if *s.value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(*s.value)
+
+	return string(data), err
+}
+
+func (s sqliteStrings) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*s.value = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src), s.value)
+	case []byte:
+		return json.Unmarshal(src, s.value)
+	default:
+		return fmt.Errorf("can't scan %T into []string", src)
+	}
+}
+
+type sqliteColumn struct {
+	name  string
+	index int
+	// У колонки есть значение по умолчанию, и нулевое значение поля при вставке заменяется им, как в go-pg
+	hasDefault bool
+}
+
+var sqliteColumnsCache sync.Map
+
+// sqliteColumns возвращает колонки модели в порядке полей. Поля-связи вроде Secrets.User пропускаются.
+func sqliteColumns(t reflect.Type) []sqliteColumn {
+	if cached, ok := sqliteColumnsCache.Load(t); ok {
+		return cached.([]sqliteColumn)
+	}
+
+	columns := []sqliteColumn{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() == reflect.Pointer {
+			continue
+		}
+
+		columns = append(columns, sqliteColumn{
+			name:       columnName(field),
+			index:      i,
+			hasDefault: strings.Contains(field.Tag.Get("pg"), "default:"),
+		})
+	}
+	sqliteColumnsCache.Store(t, columns)
+
+	return columns
+}
+
+func sqliteColumnList(columns []sqliteColumn) string {
+	names := []string{}
+	for _, column := range columns {
+		names = append(names, `"`+column.name+`"`)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// sqliteField возвращает поле для передачи в запрос или для Scan
+func sqliteField(field reflect.Value) any {
+	if value, ok := field.Addr().Interface().(*[]string); ok {
+		return sqliteStrings{value: value}
+	}
+
+	return field.Addr().Interface()
+}
+
+func sqliteArg(field reflect.Value) any {
+	if value, ok := field.Addr().Interface().(*[]string); ok {
+		return sqliteStrings{value: value}
+	}
+
+	return field.Interface()
+}
+
+func sqliteScanTargets(model reflect.Value) []any {
+	targets := []any{}
+	for _, column := range sqliteColumns(model.Type()) {
+		targets = append(targets, sqliteField(model.Field(column.index)))
+	}
+
+	return targets
+}
+
+// sqliteInsert добавляет запись и заполняет в model ID и значения по умолчанию
+func sqliteInsert(q sqliteQuerier, table string, model any) error {
+	value := reflect.ValueOf(model).Elem()
+	columns := sqliteColumns(value.Type())
+
+	names := []string{}
+	placeholders := []string{}
+	args := []any{}
+	for _, column := range columns {
+		field := value.Field(column.index)
+		if column.name == "id" || (column.hasDefault && field.IsZero()) {
+			continue
+		}
+
+		names = append(names, `"`+column.name+`"`)
+		placeholders = append(placeholders, "?")
+		args = append(args, sqliteArg(field))
+	}
+
+	query := fmt.Sprintf(`INSERT INTO "%s" DEFAULT VALUES`, table)
+	if len(names) > 0 {
+		query = fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, table, strings.Join(names, ", "), strings.Join(placeholders, ", "))
+	}
+	query += " RETURNING " + sqliteColumnList(columns)
+
+	return q.QueryRow(query, args...).Scan(sqliteScanTargets(value)...)
+}
+
+// sqliteUpdate сохраняет перечисленные колонки записи, а без них - все
+func sqliteUpdate(q sqliteQuerier, table string, model any, columns ...string) error {
+	value := reflect.ValueOf(model).Elem()
+
+	assignments := []string{}
+	args := []any{}
+	var id any
+	for _, column := range sqliteColumns(value.Type()) {
+		field := value.Field(column.index)
+		if column.name == "id" {
+			id = field.Interface()
+			continue
+		}
+		if len(columns) > 0 && !slices.Contains(columns, column.name) {
+			continue
+		}
+
+		assignments = append(assignments, `"`+column.name+`" = ?`)
+		args = append(args, sqliteArg(field))
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf(`UPDATE "%s" SET %s WHERE "id" = ?`, table, strings.Join(assignments, ", "))
+	_, err := q.Exec(query, append(args, id)...)
+
+	return err
+}
+
+// sqliteSelect выбирает записи таблицы; tail - все, что идет после FROM: WHERE, ORDER BY, LIMIT
+func sqliteSelect[T any](q sqliteQuerier, table, tail string, args ...any) ([]T, error) {
+	var model T
+	columns := sqliteColumns(reflect.TypeOf(model))
+
+	query := fmt.Sprintf(`SELECT %s FROM "%s" %s`, sqliteColumnList(columns), table, tail)
+
+	return sqliteScanRows[T](q.Query(query, args...))
+}
+
+// sqliteDeleteReturning удаляет записи и возвращает их
+func sqliteDeleteReturning[T any](q sqliteQuerier, table, where string, args ...any) ([]T, error) {
+	var model T
+	columns := sqliteColumns(reflect.TypeOf(model))
+
+	query := fmt.Sprintf(`DELETE FROM "%s" WHERE %s RETURNING %s`, table, where, sqliteColumnList(columns))
+
+	return sqliteScanRows[T](q.Query(query, args...))
+}
+
+func sqliteScanRows[T any](rows *sql.Rows, err error) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []T{}
+	for rows.Next() {
+		var model T
+		if err := rows.Scan(sqliteScanTargets(reflect.ValueOf(&model).Elem())...); err != nil {
+			return nil, err
+		}
+		result = append(result, model)
+	}
+
+	return result, rows.Err()
+}
+
+// sqliteGet возвращает первую подходящую запись или ErrNotFound
+func sqliteGet[T any](q sqliteQuerier, table, where string, args ...any) (*T, error) {
+	list, err := sqliteSelect[T](q, table, "WHERE "+where+" LIMIT 1", args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &list[0], nil
+}
+
+func sqliteDelete(q sqliteQuerier, table, where string, args ...any) (int, error) {
+	result, err := q.Exec(fmt.Sprintf(`DELETE FROM "%s" WHERE %s`, table, where), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	return int(deleted), err
+}
+
+// sqliteLimit переводит limit 0 (без ограничения) в -1, как его понимает SQLite
+func sqliteLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+
+	return limit
+}
+
+// sqliteTransaction выполняет fn в транзакции и откатывает ее, если fn вернула ошибку
+func sqliteTransaction(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+type sqliteUserRepo struct {
+	db *sql.DB
+}
+
+func (r sqliteUserRepo) GetByTelegramID(telegramID int64) (*models.Users, error) {
+	return sqliteGet[models.Users](r.db, "users", `"telegram_id" = ?`, telegramID)
+}
+
+func (r sqliteUserRepo) Create(user *models.Users) error {
+	return sqliteInsert(r.db, "users", user)
+}
+
+func (r sqliteUserRepo) Update(user *models.Users, columns ...string) error {
+	return sqliteUpdate(r.db, "users", user, columns...)
+}
+
+type sqliteSecretRepo struct {
+	db *sql.DB
+}
+
+func (r sqliteSecretRepo) Get(userID int64, isDecoy bool, id int64) (*models.Secrets, error) {
+	return sqliteGet[models.Secrets](r.db, "secrets", `"id" = ? AND "user_id" = ? AND "is_decoy" = ?`, id, userID, isDecoy)
+}
+
+func (r sqliteSecretRepo) List(userID int64, isDecoy bool, sortOrder string, offset, limit int) ([]models.Secrets, error) {
+	return sqliteSelect[models.Secrets](r.db, "secrets",
+		`WHERE "user_id" = ? AND "is_decoy" = ? ORDER BY `+secretsOrderExpr(sortOrder)+` LIMIT ? OFFSET ?`,
+		userID, isDecoy, sqliteLimit(limit), max(offset, 0))
+}
+
+func (r sqliteSecretRepo) Count(userID int64, isDecoy bool) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT count(*) FROM "secrets" WHERE "user_id" = ? AND "is_decoy" = ?`, userID, isDecoy).Scan(&count)
+
+	return count, err
+}
+
+func (r sqliteSecretRepo) Create(secret *models.Secrets) error {
+	return sqliteInsert(r.db, "secrets", secret)
+}
+
+func (r sqliteSecretRepo) Delete(userID int64, isDecoy bool, id int64) (int, error) {
+	return sqliteDelete(r.db, "secrets", `"id" = ? AND "user_id" = ? AND "is_decoy" = ?`, id, userID, isDecoy)
+}
+
+func (r sqliteSecretRepo) DeleteAll(userID int64, isDecoy bool) (int, error) {
+	return sqliteDelete(r.db, "secrets", `"user_id" = ? AND "is_decoy" = ?`, userID, isDecoy)
+}
+
+func (r sqliteSecretRepo) Apply(userID int64, isDecoy bool, changes SecretChanges) error {
+	return sqliteTransaction(r.db, func(tx *sql.Tx) error {
+		for _, id := range changes.Delete {
+			_, err := sqliteDelete(tx, "secrets", `"id" = ? AND "user_id" = ? AND "is_decoy" = ?`, id, userID, isDecoy)
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := range changes.Update {
+			// sqliteUpdate ищет запись только по id, поэтому хранилище проверяем заранее
+			_, err := sqliteGet[models.Secrets](tx, "secrets", `"id" = ? AND "user_id" = ? AND "is_decoy" = ?`, changes.Update[i].ID, userID, isDecoy)
+			if err != nil {
+				return err
+			}
+
+			err = sqliteUpdate(tx, "secrets", &changes.Update[i], secretUpdateColumns...)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Внутри одной транзакции SQLite вставляет строки по одной почти так же быстро, как пачкой
+		for i := range changes.Create {
+			secret := &changes.Create[i]
+			secret.UserID = userID
+			secret.IsDecoy = isDecoy
+
+			err := sqliteInsert(tx, "secrets", secret)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+type sqliteSessionRepo struct {
+	db *sql.DB
+}
+
+func (r sqliteSessionRepo) GetByChat(userID, chatID int64) (*models.Sessions, error) {
+	return sqliteGet[models.Sessions](r.db, "sessions", `"user_id" = ? AND "chat_id" = ?`, userID, chatID)
+}
+
+func (r sqliteSessionRepo) ListByUser(userID int64, isDuress bool) ([]models.Sessions, error) {
+	return sqliteSelect[models.Sessions](r.db, "sessions",
+		`WHERE "user_id" = ? AND "is_duress" = ? ORDER BY "created_at" ASC, "id" ASC`, userID, isDuress)
+}
+
+func (r sqliteSessionRepo) ListExpiring(within int64) ([]models.Sessions, error) {
+	return sqliteSelect[models.Sessions](r.db, "sessions",
+		`WHERE "chat_id" != 0 AND "warning_message_id" = 0 AND "updated_at" + "reset_time_interval" - ? < ?`,
+		within, time.Now().Unix())
+}
+
+func (r sqliteSessionRepo) Create(session *models.Sessions) error {
+	return sqliteInsert(r.db, "sessions", session)
+}
+
+func (r sqliteSessionRepo) Update(session *models.Sessions, columns ...string) error {
+	return sqliteUpdate(r.db, "sessions", session, columns...)
+}
+
+func (r sqliteSessionRepo) Delete(userID, sessionID int64) ([]models.Sessions, error) {
+	return sqliteDeleteReturning[models.Sessions](r.db, "sessions", `"id" = ? AND "user_id" = ?`, sessionID, userID)
+}
+
+func (r sqliteSessionRepo) DeleteByChat(userID, chatID int64) ([]models.Sessions, error) {
+	return sqliteDeleteReturning[models.Sessions](r.db, "sessions", `"user_id" = ? AND "chat_id" = ?`, userID, chatID)
+}
+
+func (r sqliteSessionRepo) DeleteExpired() ([]models.Sessions, error) {
+	return sqliteDeleteReturning[models.Sessions](r.db, "sessions",
+		`"updated_at" + "reset_time_interval" < ?`, time.Now().Unix())
+}
+
+type sqliteSettingsRepo struct {
+	db *sql.DB
+}
+
+func (r sqliteSettingsRepo) Get(telegramID int64) (*models.UserSettings, error) {
+	return sqliteGet[models.UserSettings](r.db, "user_settings", `"telegram_id" = ?`, telegramID)
+}
+
+func (r sqliteSettingsRepo) Save(settings *models.UserSettings) error {
+	if settings.ID == 0 {
+		return sqliteInsert(r.db, "user_settings", settings)
+	}
+
+	return sqliteUpdate(r.db, "user_settings", settings)
+}
+
+type sqliteLoginAttemptRepo struct {
+	db *sql.DB
+}
+
+func (r sqliteLoginAttemptRepo) Get(telegramID int64) (*models.LoginAttempts, error) {
+	return sqliteGet[models.LoginAttempts](r.db, "login_attempts", `"telegram_id" = ?`, telegramID)
+}
+
+func (r sqliteLoginAttemptRepo) Save(attempts *models.LoginAttempts) error {
+	if attempts.ID == 0 {
+		return sqliteInsert(r.db, "login_attempts", attempts)
+	}
+
+	return sqliteUpdate(r.db, "login_attempts", attempts)
+}
+
+func (r sqliteLoginAttemptRepo) Delete(telegramID int64) error {
+	_, err := sqliteDelete(r.db, "login_attempts", `"telegram_id" = ?`, telegramID)
+
+	return err
+}
+
+type sqliteBotMessageRepo struct {
+	db *sql.DB
+}
+
+func (r sqliteBotMessageRepo) Create(message *models.BotMessages) error {
+	return sqliteInsert(r.db, "bot_messages", message)
+}
+
+func (r sqliteBotMessageRepo) ListByChat(chatID int64) ([]models.BotMessages, error) {
+	return sqliteSelect[models.BotMessages](r.db, "bot_messages", `WHERE "chat_id" = ? ORDER BY "message_id" ASC`, chatID)
+}
+
+func (r sqliteBotMessageRepo) DeleteByChat(chatID int64) error {
+	_, err := sqliteDelete(r.db, "bot_messages", `"chat_id" = ?`, chatID)
+
+	return err
+}
+
+func (r sqliteBotMessageRepo) DeleteCreatedBefore(before int64) error {
+	_, err := sqliteDelete(r.db, "bot_messages", `"created_at" < ?`, before)
+
+	return err
+}
+
+type sqliteExpiringMessageRepo struct {
+	db *sql.DB
+}
+
+func (r sqliteExpiringMessageRepo) Create(message *models.ExpiringMessages) error {
+	return sqliteInsert(r.db, "expiring_messages", message)
+}
+
+func (r sqliteExpiringMessageRepo) ListDue(now int64) ([]models.ExpiringMessages, error) {
+	return sqliteSelect[models.ExpiringMessages](r.db, "expiring_messages", `WHERE "expires_at" <= ?`, now)
+}
+
+func (r sqliteExpiringMessageRepo) Delete(id int64) error {
+	_, err := sqliteDelete(r.db, "expiring_messages", `"id" = ?`, id)
+
+	return err
+}
+
+func (r sqliteExpiringMessageRepo) DeleteByMessage(chatID int64, messageID int) error {
+	_, err := sqliteDelete(r.db, "expiring_messages", `"chat_id" = ? AND "message_id" = ?`, chatID, messageID)
+
+	return err
+}
+
+func (r sqliteExpiringMessageRepo) DeleteByChat(chatID int64) error {
+	_, err := sqliteDelete(r.db, "expiring_messages", `"chat_id" = ?`, chatID)
+
+	return err
+}
+
+type sqliteAuditRepo struct {
+	db *sql.DB
+}
+
+// Append полагается на то, что транзакции открываются через BEGIN IMMEDIATE (_txlock=immediate):
+// пока одна запись добавляется, другие транзакции на запись ждут
+func (r sqliteAuditRepo) Append(build func(prevHash string) *models.AuditEvents) error {
+	return sqliteTransaction(r.db, func(tx *sql.Tx) error {
+		prevHash := ""
+		err := tx.QueryRow(`SELECT "hash" FROM "audit_events" ORDER BY "id" DESC LIMIT 1`).Scan(&prevHash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		return sqliteInsert(tx, "audit_events", build(prevHash))
+	})
+}
+
+func (r sqliteAuditRepo) ListByUser(userID int64, isDuress bool, offset, limit int) ([]models.AuditEvents, int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT count(*) FROM "audit_events" WHERE "user_id" = ? AND "is_duress" = ?`, userID, isDuress).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events, err := sqliteSelect[models.AuditEvents](r.db, "audit_events",
+		`WHERE "user_id" = ? AND "is_duress" = ? ORDER BY "id" DESC LIMIT ? OFFSET ?`,
+		userID, isDuress, sqliteLimit(limit), max(offset, 0))
+
+	return events, count, err
+}
+
+func (r sqliteAuditRepo) ListAll() ([]models.AuditEvents, error) {
+	return sqliteSelect[models.AuditEvents](r.db, "audit_events", `ORDER BY "id" ASC`)
+}
+
+type sqliteWipeRepo struct {
+	db *sql.DB
+}
+
+func (r sqliteWipeRepo) Wipe(wipe *models.Wipes) error {
+	return sqliteTransaction(r.db, func(tx *sql.Tx) error {
+		where, args := `"user_id" = ?`, []any{wipe.TelegramID}
+		if wipe.IsDuress {
+			where, args = where+` AND "is_decoy" = ?`, append(args, true)
+		}
+
+		secretsDeleted, err := sqliteDelete(tx, "secrets", where, args...)
+		if err != nil {
+			return err
+		}
+
+		sessionsDeleted, err := sqliteDelete(tx, "sessions", `"user_id" = ?`, wipe.TelegramID)
+		if err != nil {
+			return err
+		}
+
+		wipe.SecretsDeleted = secretsDeleted
+		wipe.SessionsDeleted = sessionsDeleted
+
+		return sqliteInsert(tx, "wipes", wipe)
+	})
+}
+
+func (r sqliteWipeRepo) Update(wipe *models.Wipes, columns ...string) error {
+	return sqliteUpdate(r.db, "wipes", wipe, columns...)
+}
+
+type sqliteShareRepo struct {
+	db *sql.DB
+}
+
+func (r sqliteShareRepo) Create(share *models.Shares) error {
+	return sqliteInsert(r.db, "shares", share)
+}
+
+func (r sqliteShareRepo) Consume(tokenHash string, now int64) (*models.Shares, error) {
+	var share *models.Shares
+	err := sqliteTransaction(r.db, func(tx *sql.Tx) error {
+		columns := sqliteColumnList(sqliteColumns(reflect.TypeOf(models.Shares{})))
+		shares, err := sqliteScanRows[models.Shares](tx.Query(
+			`UPDATE "shares" SET "views_left" = "views_left" - 1
+			WHERE "token_hash" = ? AND "views_left" > 0 AND "expires_at" > ? RETURNING `+columns,
+			tokenHash, now,
+		))
+		if err != nil {
+			return err
+		}
+		if len(shares) == 0 {
+			return ErrNotFound
+		}
+
+		share = &shares[0]
+		if share.ViewsLeft > 0 {
+			return nil
+		}
+
+		_, err = sqliteDelete(tx, "shares", `"id" = ?`, share.ID)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (r sqliteShareRepo) ListBySecret(userID int64, isDecoy bool, secretID int64, now int64) ([]models.Shares, error) {
+	return sqliteSelect[models.Shares](r.db, "shares",
+		`WHERE "user_id" = ? AND "is_decoy" = ? AND "secret_id" = ? AND "expires_at" > ? ORDER BY "id" ASC`,
+		userID, isDecoy, secretID, now)
+}
+
+func (r sqliteShareRepo) DeleteBySecret(userID int64, isDecoy bool, secretID int64) (int, error) {
+	return sqliteDelete(r.db, "shares", `"user_id" = ? AND "is_decoy" = ? AND "secret_id" = ?`, userID, isDecoy, secretID)
+}
+
+func (r sqliteShareRepo) DeleteExpired(now int64) error {
+	_, err := sqliteDelete(r.db, "shares", `"expires_at" <= ?`, now)
+
+	return err
+}
@@ -1,23 +1,31 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"log"
-	"main/actions"
+	"main/bot"
 	"main/controllers"
 	"main/database"
-	"main/handlers"
-	"main/util"
+	"main/database/migrations"
+	"main/dispatcher"
+	"main/ratelimit"
 	"os"
-	"slices"
-	"strconv"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
 )
 
+const (
+	UPDATE_WORKERS    = 8                // Сколько обновлений обрабатывается одновременно
+	UPDATE_QUEUE_SIZE = 64               // Очередь одного обработчика; когда она заполнена, прием обновлений ждет
+	SHUTDOWN_TIMEOUT  = 30 * time.Second // Сколько ждать обработки очереди при остановке
+)
+
 var (
 	currentBot *tgbotapi.BotAPI
 	botMutex   sync.Mutex
@@ -32,7 +40,8 @@ func connect(debug bool) *tgbotapi.BotAPI {
 		currentBot.StopReceivingUpdates()
 	}
 
-	bot, err := tgbotapi.NewBotAPI(os.Getenv("API_KEY"))
+	// Клиент повторяет запросы, на которые Telegram ответил 429, выждав retry_after
+	bot, err := tgbotapi.NewBotAPIWithClient(os.Getenv("API_KEY"), tgbotapi.APIEndpoint, ratelimit.NewRetryClient())
 	if err != nil {
 		panic(err)
 	}
@@ -44,68 +53,38 @@ func connect(debug bool) *tgbotapi.BotAPI {
 	return bot
 }
 
-func InActionList(update tgbotapi.Update, allowedActions []string) bool {
-	if update.CallbackQuery == nil {
-		return false
-	}
-
-	var data map[string]any
-	err := json.Unmarshal([]byte(update.CallbackQuery.Data), &data)
-
-	return err == nil && slices.Contains(allowedActions, data["a"].(string))
-}
-
-func getBotActions(bot *tgbotapi.BotAPI) handlers.ActiveHandlers {
-	startFilter := func(update tgbotapi.Update) bool { return update.Message.Command() == "start" }
-
-	adminIdStr := os.Getenv("ADMIN_ID")
-	adminId, err := strconv.ParseInt(adminIdStr, 10, 64)
-	if err != nil {
-		panic(err)
-	}
-	adminFilter := func(update tgbotapi.Update) bool { return util.GetMessage(update).Chat.ID == adminId }
-
-	mainPageCallQuery := func(update tgbotapi.Update) bool {
-		return InActionList(update, []string{"n", "p", "c"})
-	}
+func main() {
+	_ = godotenv.Load()
 
-	addSecretCallQuery := func(update tgbotapi.Update) bool {
-		return InActionList(update, []string{"a"})
+	// bot migrate up|down|status управляет схемой базы и завершается, не запуская бота
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrations.Command(database.Migrations(), os.Args[2:], os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	viewSecretCallQuery := func(update tgbotapi.Update) bool {
-		return InActionList(update, []string{"s"})
-	}
+	debug := os.Getenv("DEBUG") == "true"
 
-	deleteSecretCallQuery := func(update tgbotapi.Update) bool {
-		return InActionList(update, []string{"d"})
+	_, err := controllers.AuditKey()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Не задан AUDIT_HMAC_KEY - секретный ключ журнала доступа, без него журнал нельзя проверить. Пример настроек - в env.example.")
+		os.Exit(1)
 	}
 
-	act := handlers.ActiveHandlers{Handlers: []handlers.Handler{
-		handlers.CommandHandler.Product(actions.MainPage{Name: "main-page-cmd", Client: *bot}, []handlers.Filter{startFilter, adminFilter}),
-		handlers.CallbackQueryHandler.Product(actions.MainPage{Name: "main-page-call-query", Client: *bot}, []handlers.Filter{mainPageCallQuery, adminFilter}),
-		handlers.CallbackQueryHandler.Product(actions.AddSecret{Name: "add-secret-call-query", Client: *bot}, []handlers.Filter{addSecretCallQuery, adminFilter}),
-		handlers.CallbackQueryHandler.Product(actions.ViewSecret{Name: "view-secret-call-query", Client: *bot}, []handlers.Filter{viewSecretCallQuery, adminFilter}),
-		handlers.CallbackQueryHandler.Product(actions.DeleteSecret{Name: "delete-secret-call-query", Client: *bot}, []handlers.Filter{deleteSecretCallQuery, adminFilter}),
-	}}
-
-	return act
-}
-
-func main() {
-	_ = godotenv.Load()
-
-	debug := os.Getenv("DEBUG") == "true"
-
-	err := database.InitDb()
+	err = database.InitDb()
 	if err != nil {
 		panic(err)
 	}
 
 	log.Println("Database initialized successfully")
 
+	repos := database.GetRepos()
+
 	client := connect(debug)
-	act := getBotActions(client)
+	act := bot.GetBotActions(client, repos)
 
 	updateConfig := tgbotapi.NewUpdate(0)
 	updateConfig.Timeout = 60
@@ -113,18 +92,63 @@ func main() {
 	go func() {
 		for {
 			time.Sleep(5 * time.Second)
-			err := controllers.DeleteOldSessions()
+			err := controllers.WarnExpiringSessions(client, repos)
+			if err != nil {
+				log.Println("Error warning about expiring sessions: ", err)
+			}
+
+			err = controllers.DeleteOldSessions(client, repos)
 			if err != nil {
 				log.Println("Error deleting old sessions: ", err)
 			}
+
+			err = controllers.PruneTrackedMessages(repos.BotMessages)
+			if err != nil {
+				log.Println("Error pruning tracked messages: ", err)
+			}
+
+			err = controllers.PruneShares(repos.Shares)
+			if err != nil {
+				log.Println("Error pruning shares: ", err)
+			}
+
+			bot.MessagesLimiter.Prune()
+			bot.CallbacksLimiter.Prune()
+			controllers.PasswordLimiter.Prune()
+		}
+	}()
+
+	go func() {
+		for {
+			time.Sleep(1 * time.Second)
+			err := controllers.ExpireMessages(client, repos)
+			if err != nil {
+				log.Println("Error expiring messages: ", err)
+			}
 		}
 	}()
 
-	stepManager := controllers.GetNextStepManager()
+	pool := dispatcher.NewPool(UPDATE_WORKERS, UPDATE_QUEUE_SIZE, bot.HandleUpdate(client, act))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// По сигналу перестаем получать обновления, канал updates закроется и цикл ниже завершится
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down, waiting for queued updates...")
+		client.StopReceivingUpdates()
+	}()
 
 	updates := client.GetUpdatesChan(updateConfig)
 	for update := range updates {
-		controllers.RunStepUpdates(update, stepManager, *client)
-		_ = act.HandleAll(update)
+		pool.Submit(update)
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	if err := pool.Shutdown(drainCtx); err != nil {
+		log.Println("Error draining update queue: ", err)
 	}
 }
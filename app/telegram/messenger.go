@@ -0,0 +1,67 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxDownloadSize - больше Bot API не отдает ботам через getFile
+const MaxDownloadSize = 20 << 20
+
+// DownloadTimeout ограничивает скачивание файла целиком, чтобы зависшее соединение не держало шаг диалога
+const DownloadTimeout = time.Minute
+
+var ErrFileTooBig = errors.New("file is too big")
+
+var downloadClient = &http.Client{Timeout: DownloadTimeout}
+
+// Messenger - часть Bot API, которой пользуются действия и контроллеры.
+// *tgbotapi.BotAPI реализует его как есть, а в тестах вместо него подставляется бот,
+// направленный на поддельный сервер из пакета telegramtest.
+type Messenger interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	// GetFileDirectURL возвращает адрес, по которому можно скачать файл из сообщения
+	GetFileDirectURL(fileID string) (string, error)
+	// GetMe возвращает самого бота, его имя нужно для ссылок t.me
+	GetMe() (tgbotapi.User, error)
+}
+
+// DownloadFile скачивает файл из сообщения, но не больше MaxDownloadSize
+func DownloadFile(client Messenger, fileID string) ([]byte, error) {
+	fileURL, err := client.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := downloadClient.Get(fileURL)
+	if err != nil {
+		// В адресе файла есть токен бота, поэтому в ошибку он не попадает
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, fmt.Errorf("download file: %w", urlErr.Err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxDownloadSize {
+		return nil, ErrFileTooBig
+	}
+
+	return data, nil
+}
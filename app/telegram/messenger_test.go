@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fileMessenger отдает адрес файла на тестовом сервере
+type fileMessenger struct {
+	Messenger
+	url string
+}
+
+func (m fileMessenger) GetFileDirectURL(fileID string) (string, error) {
+	return m.url + "/file/botsecret-token/" + fileID, nil
+}
+
+func TestDownloadFileTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	timeout := downloadClient.Timeout
+	downloadClient.Timeout = 100 * time.Millisecond
+	t.Cleanup(func() { downloadClient.Timeout = timeout })
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := DownloadFile(fileMessenger{url: server.URL}, "document")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("download of a stalled file succeeded")
+		}
+		if strings.Contains(err.Error(), "secret-token") {
+			t.Fatalf("error %q contains the file URL", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("download of a stalled file did not time out")
+	}
+}
@@ -0,0 +1,593 @@
+// Package telegramtest содержит поддельный Bot API для тестов: сервер принимает запросы
+// бота, запоминает их и ведет состояние чатов, а тест от имени пользователя пишет
+// сообщения и нажимает кнопки.
+package telegramtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	Token = "123456:TEST"
+
+	// Сколько getUpdates ждет новых обновлений, если бот попросил ждать дольше
+	MaxPollWait = time.Second
+)
+
+var BotUser = tgbotapi.User{ID: 1, IsBot: true, FirstName: "Test", UserName: "test_bot"}
+
+// Call - запрос бота к Bot API
+type Call struct {
+	Method string
+	Params url.Values
+	Files  map[string]File
+}
+
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Message - сообщение в чате, каким его сейчас видит пользователь
+type Message struct {
+	ChatID      int64
+	MessageID   int
+	FromBot     bool
+	Text        string
+	ReplyMarkup *tgbotapi.InlineKeyboardMarkup
+	Document    *File
+	Deleted     bool
+}
+
+type chat struct {
+	lastMessageID int
+	messages      []*Message
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	calls        []Call
+	chats        map[int64]*chat
+	updates      []tgbotapi.Update
+	lastUpdateID int
+	lastQueryID  int
+	notify       chan struct{}
+	files        map[string]File // Документы из сообщений по FileID
+}
+
+// NewServer запускает поддельный Bot API. Остановить его нужно через Close.
+func NewServer() *Server {
+	s := &Server{
+		chats:  make(map[int64]*chat),
+		notify: make(chan struct{}, 1),
+		files:  make(map[string]File),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Endpoint возвращает шаблон адреса API для tgbotapi.NewBotAPIWithAPIEndpoint
+func (s *Server) Endpoint() string {
+	return s.URL + "/bot%s/%s"
+}
+
+// Bot - клиент поддельного сервера. Файлы он тоже скачивает с поддельного сервера, а не с api.telegram.org.
+type Bot struct {
+	*tgbotapi.BotAPI
+	server *Server
+}
+
+// NewBot создает клиента, который ходит в поддельный сервер
+func (s *Server) NewBot() (*Bot, error) {
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(Token, s.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bot{BotAPI: bot, server: s}, nil
+}
+
+func (b *Bot) GetFileDirectURL(fileID string) (string, error) {
+	file, err := b.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return "", err
+	}
+
+	return b.server.URL + "/file/bot" + Token + "/" + file.FilePath, nil
+}
+
+// Calls возвращает все запросы бота, начиная с первого
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.calls)
+}
+
+// CallsTo возвращает запросы бота к методу method
+func (s *Server) CallsTo(method string) []Call {
+	calls := []Call{}
+	for _, call := range s.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Messages возвращает неудаленные сообщения чата в порядке отправки
+func (s *Server) Messages(chatID int64) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := []Message{}
+	if c, ok := s.chats[chatID]; ok {
+		for _, m := range c.messages {
+			if !m.Deleted {
+				messages = append(messages, *m)
+			}
+		}
+	}
+
+	return messages
+}
+
+// LastBotMessage возвращает последнее неудаленное сообщение бота в чате
+func (s *Server) LastBotMessage(chatID int64) (Message, bool) {
+	messages := s.Messages(chatID)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].FromBot {
+			return messages[i], true
+		}
+	}
+
+	return Message{}, false
+}
+
+// Button ищет кнопку с текстом text в сообщении
+func (m Message) Button(text string) (tgbotapi.InlineKeyboardButton, bool) {
+	if m.ReplyMarkup == nil {
+		return tgbotapi.InlineKeyboardButton{}, false
+	}
+
+	for _, row := range m.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			if button.Text == text {
+				return button, true
+			}
+		}
+	}
+
+	return tgbotapi.InlineKeyboardButton{}, false
+}
+
+// SendText пишет сообщение от имени пользователя и ставит его в очередь getUpdates
+func (s *Server) SendText(chatID int64, from tgbotapi.User, text string) tgbotapi.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.addMessage(chatID, false)
+	m.Text = text
+
+	message := &tgbotapi.Message{
+		MessageID: m.MessageID,
+		From:      &from,
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+
+	return s.pushUpdate(tgbotapi.Update{Message: message})
+}
+
+// SendDocument отправляет файл от имени пользователя и ставит сообщение в очередь getUpdates
+func (s *Server) SendDocument(chatID int64, from tgbotapi.User, name string, data []byte) tgbotapi.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.addMessage(chatID, false)
+	m.Document = &File{Name: name, Data: data}
+	s.files[documentFileID(m)] = *m.Document
+
+	return s.pushUpdate(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: m.MessageID,
+		From:      &from,
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Document: &tgbotapi.Document{
+			FileID:       documentFileID(m),
+			FileUniqueID: documentFileID(m),
+			FileName:     name,
+			FileSize:     len(data),
+		},
+	}})
+}
+
+// PressButton нажимает кнопку сообщения бота от имени пользователя и ставит нажатие в очередь getUpdates
+func (s *Server) PressButton(message Message, from tgbotapi.User, button tgbotapi.InlineKeyboardButton) tgbotapi.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastQueryID++
+
+	data := ""
+	if button.CallbackData != nil {
+		data = *button.CallbackData
+	}
+
+	return s.pushUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   strconv.Itoa(s.lastQueryID),
+		From: &from,
+		Message: &tgbotapi.Message{
+			MessageID:   message.MessageID,
+			From:        &BotUser,
+			Chat:        &tgbotapi.Chat{ID: message.ChatID, Type: "private"},
+			Date:        int(time.Now().Unix()),
+			Text:        message.Text,
+			ReplyMarkup: message.ReplyMarkup,
+		},
+		ChatInstance: strconv.FormatInt(message.ChatID, 10),
+		Data:         data,
+	}})
+}
+
+func (s *Server) pushUpdate(update tgbotapi.Update) tgbotapi.Update {
+	s.lastUpdateID++
+	update.UpdateID = s.lastUpdateID
+	s.updates = append(s.updates, update)
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return update
+}
+
+func (s *Server) addMessage(chatID int64, fromBot bool) *Message {
+	c, ok := s.chats[chatID]
+	if !ok {
+		c = &chat{}
+		s.chats[chatID] = c
+	}
+
+	// В личных чатах номера сообщений идут подряд для обеих сторон
+	c.lastMessageID++
+	m := &Message{ChatID: chatID, MessageID: c.lastMessageID, FromBot: fromBot}
+	c.messages = append(c.messages, m)
+
+	return m
+}
+
+func (s *Server) findMessage(chatID int64, messageID int) *Message {
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil
+	}
+
+	for _, m := range c.messages {
+		if m.MessageID == messageID && !m.Deleted {
+			return m
+		}
+	}
+
+	return nil
+}
+
+type apiError struct {
+	code        int
+	description string
+}
+
+func badRequest(description string) *apiError {
+	return &apiError{code: http.StatusBadRequest, description: "Bad Request: " + description}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if path, ok := strings.CutPrefix(r.URL.Path, "/file/bot"+Token+"/"); ok {
+		s.serveFile(w, path)
+		return
+	}
+
+	prefix := "/bot" + Token + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeResponse(w, nil, &apiError{code: http.StatusUnauthorized, description: "Unauthorized"})
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, prefix)
+
+	call, err := readCall(method, r)
+	if err != nil {
+		writeResponse(w, nil, badRequest(err.Error()))
+		return
+	}
+
+	// getUpdates ждет без блокировки, остальные методы выполняются под мьютексом
+	if method == "getUpdates" {
+		writeResponse(w, s.getUpdates(r, call.Params), nil)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if method != "getMe" {
+		s.calls = append(s.calls, call)
+	}
+
+	result, apiErr := s.handle(call)
+	writeResponse(w, result, apiErr)
+}
+
+// serveFile отдает документ по пути, который вернул getFile
+func (s *Server) serveFile(w http.ResponseWriter, path string) {
+	s.mu.Lock()
+	file, ok := s.files[strings.TrimPrefix(path, "documents/")]
+	s.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	w.Write(file.Data)
+}
+
+func readCall(method string, r *http.Request) (Call, error) {
+	call := Call{Method: method, Files: make(map[string]File)}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return call, err
+		}
+
+		for field, headers := range r.MultipartForm.File {
+			f, err := headers[0].Open()
+			if err != nil {
+				return call, err
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return call, err
+			}
+
+			call.Files[field] = File{Name: headers[0].Filename, Data: data}
+		}
+	} else if err := r.ParseForm(); err != nil {
+		return call, err
+	}
+	call.Params = r.Form
+
+	return call, nil
+}
+
+func (s *Server) getUpdates(r *http.Request, params url.Values) []tgbotapi.Update {
+	offset, _ := strconv.Atoi(params.Get("offset"))
+	timeout, _ := strconv.Atoi(params.Get("timeout"))
+
+	wait := min(time.Duration(timeout)*time.Second, MaxPollWait)
+	deadline := time.After(wait)
+
+	for {
+		s.mu.Lock()
+		pending := []tgbotapi.Update{}
+		for _, update := range s.updates {
+			if update.UpdateID >= offset {
+				pending = append(pending, update)
+			}
+		}
+		s.mu.Unlock()
+
+		if len(pending) > 0 {
+			return pending
+		}
+
+		select {
+		case <-s.notify:
+		case <-deadline:
+			return pending
+		case <-r.Context().Done():
+			return pending
+		}
+	}
+}
+
+func (s *Server) handle(call Call) (any, *apiError) {
+	params := call.Params
+
+	switch call.Method {
+	case "getMe":
+		return BotUser, nil
+
+	case "sendMessage":
+		chatID, err := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+		if err != nil {
+			return nil, badRequest("chat not found")
+		}
+		if params.Get("text") == "" {
+			return nil, badRequest("message text is empty")
+		}
+
+		markup, apiErr := parseMarkup(params)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		m := s.addMessage(chatID, true)
+		m.Text = params.Get("text")
+		m.ReplyMarkup = markup
+
+		return toAPIMessage(m), nil
+
+	case "sendDocument":
+		chatID, err := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+		if err != nil {
+			return nil, badRequest("chat not found")
+		}
+
+		document, ok := call.Files["document"]
+		if !ok {
+			return nil, badRequest("there is no document in the request")
+		}
+
+		m := s.addMessage(chatID, true)
+		m.Text = params.Get("caption")
+		m.Document = &document
+		s.files[documentFileID(m)] = document
+
+		return toAPIMessage(m), nil
+
+	case "getFile":
+		fileID := params.Get("file_id")
+		file, ok := s.files[fileID]
+		if !ok {
+			return nil, badRequest("invalid file_id")
+		}
+
+		return tgbotapi.File{
+			FileID:       fileID,
+			FileUniqueID: fileID,
+			FileSize:     len(file.Data),
+			FilePath:     "documents/" + fileID,
+		}, nil
+
+	case "editMessageText":
+		m, apiErr := s.messageFromParams(params, "message to edit not found")
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		if params.Get("text") == "" {
+			return nil, badRequest("message text is empty")
+		}
+
+		markup, apiErr := parseMarkup(params)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		// Как и Telegram, без reply_markup кнопки у сообщения пропадают
+		m.Text = params.Get("text")
+		m.ReplyMarkup = markup
+
+		return toAPIMessage(m), nil
+
+	case "deleteMessage":
+		m, apiErr := s.messageFromParams(params, "message to delete not found")
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		m.Deleted = true
+
+		return true, nil
+
+	case "answerCallbackQuery":
+		if params.Get("callback_query_id") == "" {
+			return nil, badRequest("query is too old and response timeout expired or query ID is invalid")
+		}
+
+		return true, nil
+	}
+
+	return nil, &apiError{code: http.StatusNotFound, description: "Not Found: method " + call.Method + " is not supported by the fake server"}
+}
+
+func (s *Server) messageFromParams(params url.Values, notFound string) (*Message, *apiError) {
+	chatID, err := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	if err != nil {
+		return nil, badRequest("chat not found")
+	}
+
+	messageID, err := strconv.Atoi(params.Get("message_id"))
+	if err != nil {
+		return nil, badRequest(notFound)
+	}
+
+	m := s.findMessage(chatID, messageID)
+	if m == nil {
+		return nil, badRequest(notFound)
+	}
+
+	return m, nil
+}
+
+func parseMarkup(params url.Values) (*tgbotapi.InlineKeyboardMarkup, *apiError) {
+	raw := params.Get("reply_markup")
+	if raw == "" {
+		return nil, nil
+	}
+
+	markup := &tgbotapi.InlineKeyboardMarkup{}
+	if err := json.Unmarshal([]byte(raw), markup); err != nil {
+		return nil, badRequest("can't parse reply keyboard markup JSON object")
+	}
+
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil && len(*button.CallbackData) > 64 {
+				return nil, badRequest("BUTTON_DATA_INVALID")
+			}
+		}
+	}
+
+	return markup, nil
+}
+
+func toAPIMessage(m *Message) tgbotapi.Message {
+	message := tgbotapi.Message{
+		MessageID:   m.MessageID,
+		From:        &BotUser,
+		Chat:        &tgbotapi.Chat{ID: m.ChatID, Type: "private"},
+		Date:        int(time.Now().Unix()),
+		ReplyMarkup: m.ReplyMarkup,
+	}
+
+	if m.Document != nil {
+		message.Caption = m.Text
+		message.Document = &tgbotapi.Document{FileID: documentFileID(m), FileName: m.Document.Name}
+	} else {
+		message.Text = m.Text
+	}
+
+	return message
+}
+
+func documentFileID(m *Message) string {
+	return fmt.Sprintf("file-%d-%d", m.ChatID, m.MessageID)
+}
+
+func writeResponse(w http.ResponseWriter, result any, apiErr *apiError) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if apiErr != nil {
+		w.WriteHeader(apiErr.code)
+		json.NewEncoder(w).Encode(map[string]any{
+			"ok":          false,
+			"error_code":  apiErr.code,
+			"description": apiErr.description,
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
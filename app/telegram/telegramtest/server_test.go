@@ -0,0 +1,156 @@
+package telegramtest
+
+import (
+	"main/telegram"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func newTestBot(t *testing.T) (*Server, *Bot) {
+	server := NewServer()
+	t.Cleanup(server.Close)
+
+	bot, err := server.NewBot()
+	if err != nil {
+		t.Fatalf("NewBot: %v", err)
+	}
+
+	return server, bot
+}
+
+func TestSendEditDelete(t *testing.T) {
+	server, bot := newTestBot(t)
+
+	msg := tgbotapi.NewMessage(10, "hello")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Open", `{"a":"o"}`),
+	))
+
+	sent, err := bot.Send(msg)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	message, ok := server.LastBotMessage(10)
+	if !ok || message.MessageID != sent.MessageID || message.Text != "hello" {
+		t.Fatalf("LastBotMessage = %+v, %v", message, ok)
+	}
+	if _, ok := message.Button("Open"); !ok {
+		t.Fatal("button was not stored")
+	}
+
+	// Правка без клавиатуры убирает кнопки
+	if _, err := bot.Send(tgbotapi.NewEditMessageText(10, sent.MessageID, "edited")); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+
+	message, _ = server.LastBotMessage(10)
+	if message.Text != "edited" || message.ReplyMarkup != nil {
+		t.Fatalf("edited message = %+v", message)
+	}
+
+	if _, err := bot.Request(tgbotapi.NewDeleteMessage(10, sent.MessageID)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if len(server.Messages(10)) != 0 {
+		t.Fatal("deleted message is still visible")
+	}
+
+	if _, err := bot.Request(tgbotapi.NewDeleteMessage(10, sent.MessageID)); err == nil {
+		t.Fatal("deleting a missing message succeeded")
+	}
+
+	methods := []string{}
+	for _, call := range server.Calls() {
+		methods = append(methods, call.Method)
+	}
+	want := []string{"sendMessage", "editMessageText", "deleteMessage", "deleteMessage"}
+	if len(methods) != len(want) {
+		t.Fatalf("calls = %v, want %v", methods, want)
+	}
+	for i := range want {
+		if methods[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", methods, want)
+		}
+	}
+}
+
+func TestLongCallbackDataIsRejected(t *testing.T) {
+	_, bot := newTestBot(t)
+
+	msg := tgbotapi.NewMessage(10, "hello")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Open", string(make([]byte, 65))),
+	))
+
+	if _, err := bot.Send(msg); err == nil {
+		t.Fatal("callback data longer than 64 bytes was accepted")
+	}
+}
+
+func TestUpdatesAreDelivered(t *testing.T) {
+	server, bot := newTestBot(t)
+
+	config := tgbotapi.NewUpdate(0)
+	config.Timeout = 1
+	updates := bot.GetUpdatesChan(config)
+	defer bot.StopReceivingUpdates()
+
+	user := tgbotapi.User{ID: 20, FirstName: "User"}
+	server.SendText(20, user, "/start now")
+
+	select {
+	case update := <-updates:
+		if update.Message == nil || update.Message.Command() != "start" || update.Message.CommandArguments() != "now" {
+			t.Fatalf("unexpected update %+v", update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+
+	sent, err := bot.Send(tgbotapi.NewMessage(20, "menu"))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	message, _ := server.LastBotMessage(20)
+	if message.MessageID != sent.MessageID || sent.MessageID != 2 {
+		t.Fatalf("bot message id = %d, want 2 right after the user's message", sent.MessageID)
+	}
+
+	server.PressButton(message, user, tgbotapi.NewInlineKeyboardButtonData("Go", `{"a":"g"}`))
+
+	select {
+	case update := <-updates:
+		if update.CallbackQuery == nil || update.CallbackQuery.Data != `{"a":"g"}` || update.CallbackQuery.Message.MessageID != sent.MessageID {
+			t.Fatalf("unexpected update %+v", update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("button press was not delivered")
+	}
+}
+
+func TestDocumentsCanBeDownloaded(t *testing.T) {
+	server, bot := newTestBot(t)
+
+	update := server.SendDocument(30, tgbotapi.User{ID: 30}, "backup.json", []byte("from user"))
+	data, err := telegram.DownloadFile(bot, update.Message.Document.FileID)
+	if err != nil || string(data) != "from user" {
+		t.Fatalf("DownloadFile = %q, %v", data, err)
+	}
+
+	sent, err := bot.Send(tgbotapi.NewDocument(30, tgbotapi.FileBytes{Name: "export.json", Bytes: []byte("from bot")}))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	data, err = telegram.DownloadFile(bot, sent.Document.FileID)
+	if err != nil || string(data) != "from bot" {
+		t.Fatalf("DownloadFile = %q, %v", data, err)
+	}
+
+	if _, err := telegram.DownloadFile(bot, "missing"); err == nil {
+		t.Fatal("downloading a missing file succeeded")
+	}
+}
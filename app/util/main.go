@@ -1,8 +1,10 @@
 package util
 
 import (
-	"main/database"
 	"main/database/models"
+	"main/repository"
+	"os"
+	"strconv"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -25,15 +27,26 @@ func StringPtr(s string) *string {
 	return &s
 }
 
-func GetSession(update tgbotapi.Update) (models.Sessions, error) {
-	session := &models.Sessions{}
-	err := database.GetDB().Model(session).Where("user_id = ?", GetMessage(update).From.ID).Select()
-
-	return *session, err
+// GetSession возвращает сессию автора обновления в его чате
+func GetSession(sessions repository.SessionRepo, update tgbotapi.Update) (*models.Sessions, error) {
+	return sessions.GetByChat(GetMessage(update).From.ID, GetMessage(update).Chat.ID)
 }
 
-func HasActiveSession(update tgbotapi.Update) bool {
-	_, err := GetSession(update)
+func HasActiveSession(sessions repository.SessionRepo, update tgbotapi.Update) bool {
+	_, err := GetSession(sessions, update)
 
 	return err == nil
 }
+
+// GetAdminID возвращает Telegram ID администратора из переменной окружения ADMIN_ID
+func GetAdminID() (int64, error) {
+	return strconv.ParseInt(os.Getenv("ADMIN_ID"), 10, 64)
+}
+
+// GetDuressAlertChatID возвращает чат для тревожных уведомлений из DURESS_ALERT_CHAT_ID.
+// Если чат не задан, уведомления о входе под принуждением недоступны.
+func GetDuressAlertChatID() (int64, bool) {
+	chatID, err := strconv.ParseInt(os.Getenv("DURESS_ALERT_CHAT_ID"), 10, 64)
+
+	return chatID, err == nil && chatID != 0
+}